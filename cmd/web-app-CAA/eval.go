@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+)
+
+const evalUsage = `Usage:
+  web-app-caa eval [flags]            Run the golden dataset against the configured LLM backend
+  web-app-caa eval diff BASE.json RUN.json
+                                      Compare two saved runs
+
+Flags:
+`
+
+// runEval implements the eval subcommand: it runs a golden dataset of conjugation
+// and correction cases through LLMService, reports accuracy per language and
+// tense and latency percentiles, and compares runs so models and prompts can be
+// checked before switching. It returns the process exit code.
+func runEval(args []string) int {
+	if len(args) > 0 && args[0] == "diff" {
+		return runEvalDiff(args[1:])
+	}
+
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), evalUsage)
+		fs.PrintDefaults()
+	}
+	dataset := fs.String("dataset", "evals/golden.jsonl", "Golden dataset, one JSON case per line")
+	out := fs.String("out", "", "Save the run as JSON to this file, to compare later runs with it")
+	baseline := fs.String("baseline", "", "Saved run to compare this run with")
+	label := fs.String("label", "", "Name of the run in reports (default: the model, else the provider)")
+	model := fs.String("model", "", "Model to evaluate instead of LLM_MODEL")
+	templatesDir := fs.String("templates", "", "Directory of <language>/<name>.tmpl prompt templates to evaluate instead of the ones in use")
+	useDB := fs.Bool("db", false, "Load the prompt templates in use from the database instead of the embedded defaults")
+	concurrency := fs.Int("concurrency", 1, "Cases evaluated at a time; keep 1 for comparable latencies")
+	verbose := fs.Bool("v", false, "Show the service logs")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg := config.Load()
+	if *model != "" {
+		cfg.LLM.Model = *model
+	}
+	// Every case must reach the LLM for accuracy and latency to mean anything
+	cfg.LLM.Cache.Enabled = false
+
+	cases, err := services.LoadEvalDataset(*dataset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+		return 1
+	}
+	var base *models.EvalRun
+	if *baseline != "" {
+		if base, err = services.LoadEvalRun(*baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+			return 1
+		}
+	}
+
+	if *useDB {
+		database.Initialize(cfg)
+	}
+	llmService := services.NewLLMService(cfg)
+	if *templatesDir != "" {
+		names, err := llmService.LoadPromptTemplateFiles(*templatesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Using prompt templates from %s: %s\n", *templatesDir, strings.Join(names, ", "))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Evaluating %d cases from %s\n", len(cases), *dataset)
+	var progress func(models.EvalCaseResult)
+	if isTerminal(os.Stderr) {
+		done := 0
+		progress = func(result models.EvalCaseResult) {
+			done++
+			fmt.Fprintf(os.Stderr, "\r%d/%d", done, len(cases))
+		}
+	}
+	run := llmService.RunEval(ctx, cases, *concurrency, progress)
+	if progress != nil {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	run.Dataset = *dataset
+	run.Label = *label
+	if run.Label == "" {
+		run.Label = run.Model
+	}
+	if run.Label == "" {
+		run.Label = run.Provider
+	}
+
+	printEvalRun(os.Stdout, run)
+	if run.Summary.Errors > 0 {
+		fmt.Fprintf(os.Stderr, "eval: warning: %d of %d cases failed or got no answer from the LLM; check the backend configuration\n",
+			run.Summary.Errors, run.Summary.Cases)
+	}
+	if *out != "" {
+		if err := services.SaveEvalRun(*out, run); err != nil {
+			fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+			return 1
+		}
+		fmt.Printf("\nRun saved to %s\n", *out)
+	}
+	if base != nil {
+		fmt.Println()
+		printEvalDiff(os.Stdout, services.DiffEvalRuns(base, run))
+	}
+	if ctx.Err() != nil {
+		return 130
+	}
+	return 0
+}
+
+// runEvalDiff compares two saved runs
+func runEvalDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, evalUsage)
+		return 2
+	}
+
+	base, err := services.LoadEvalRun(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+		return 1
+	}
+	run, err := services.LoadEvalRun(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+		return 1
+	}
+
+	printEvalDiff(os.Stdout, services.DiffEvalRuns(base, run))
+	return 0
+}
+
+// printEvalRun writes the summary table of a run followed by its failed cases
+func printEvalRun(w io.Writer, run *models.EvalRun) {
+	fmt.Fprintf(w, "\nRun %q - provider: %s, model: %s, %d cases in %.1fs\n\n",
+		run.Label, run.Provider, run.Model, run.Summary.Cases, float64(run.DurationMs)/1000)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "GROUP\tCASES\tPASSED\tACCURACY\tITEM ACC\tMODEL\tP50 ms\tP90 ms\tP95 ms\tP99 ms\tMAX ms\tTOKENS\tERRORS\t")
+	for _, group := range append(run.Groups, run.Summary) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			group.Group, group.Cases, group.Passed, percent(group.Accuracy), percent(group.ItemAccuracy), percent(group.ModelShare),
+			group.Latency.P50, group.Latency.P90, group.Latency.P95, group.Latency.P99, group.Latency.Max, group.Tokens, group.Errors)
+	}
+	tw.Flush()
+
+	var failed []models.EvalCaseResult
+	for _, result := range run.Results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nFailed cases (%d):\n", len(failed))
+	for _, result := range failed {
+		fmt.Fprintf(w, "  %s [%s] %s\n", result.ID, result.Group, describeEvalResult(&result))
+	}
+}
+
+// printEvalDiff writes the comparison of a run with its baseline
+func printEvalDiff(w io.Writer, diff *models.EvalDiff) {
+	fmt.Fprintf(w, "Comparing %q (model: %s) -> %q (model: %s)\n", diff.BaseLabel, diff.BaseModel, diff.Label, diff.Model)
+	if len(diff.ChangedTemplates) > 0 {
+		fmt.Fprintf(w, "Changed prompt templates: %s\n", strings.Join(diff.ChangedTemplates, ", "))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tACCURACY\tITEM ACC\tP50 ms\tP95 ms\tTOKENS")
+	for _, group := range append(diff.Groups, diff.Summary) {
+		name := group.Group
+		switch {
+		case group.MissingInBaseline:
+			name += " (new)"
+		case group.MissingInRun:
+			name += " (gone)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", name,
+			compareRatio(group.BaseAccuracy, group.Accuracy), compareRatio(group.BaseItemAccuracy, group.ItemAccuracy),
+			compareInt(group.BaseP50, group.P50), compareInt(group.BaseP95, group.P95),
+			compareInt(int64(group.BaseTokens), int64(group.Tokens)))
+	}
+	tw.Flush()
+
+	if len(diff.Cases) == 0 {
+		fmt.Fprintln(w, "\nNo case changed outcome")
+		return
+	}
+	for _, change := range []string{"regressed", "fixed", "changed", "added", "removed"} {
+		var lines []string
+		for _, c := range diff.Cases {
+			if c.Change != change {
+				continue
+			}
+			switch {
+			case c.Run == nil:
+				lines = append(lines, fmt.Sprintf("  %s: %s", c.ID, describeEvalResult(c.Base)))
+			case c.Base == nil:
+				lines = append(lines, fmt.Sprintf("  %s: %s", c.ID, describeEvalResult(c.Run)))
+			default:
+				lines = append(lines, fmt.Sprintf("  %s\n      was: %s\n      now: %s",
+					c.ID, describeEvalResult(c.Base), describeEvalResult(c.Run)))
+			}
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(w, "\n%s (%d):\n%s\n", strings.ToUpper(change[:1])+change[1:], len(lines), strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// describeEvalResult summarizes the answer of a case, showing the expected
+// value next to each wrong one
+func describeEvalResult(result *models.EvalCaseResult) string {
+	if result.Error != "" {
+		return "error: " + result.Error
+	}
+	if result.Kind == models.EvalKindCorrect {
+		if result.Passed {
+			return fmt.Sprintf("ok %q", result.GotSentence)
+		}
+		return fmt.Sprintf("got %q (%s), expected %q", result.GotSentence, result.Sources["corrected_sentence"], result.ExpectedSentence)
+	}
+
+	if len(result.Wrong) == 0 {
+		return fmt.Sprintf("ok %v", result.Got)
+	}
+	wrong := make([]string, len(result.Wrong))
+	for i, verb := range result.Wrong {
+		wrong[i] = fmt.Sprintf("%s: got %q (%s), expected %q", verb, result.Got[verb], result.Sources[verb], result.Expected[verb])
+	}
+	return strings.Join(wrong, "; ")
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// percent formats a ratio as a percentage
+func percent(ratio float64) string {
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+// compareRatio formats a ratio of the baseline and of the run with the change
+// in percentage points
+func compareRatio(base, run float64) string {
+	if base == run {
+		return percent(run)
+	}
+	return fmt.Sprintf("%s -> %s (%+.1f)", percent(base), percent(run), (run-base)*100)
+}
+
+// compareInt formats a value of the baseline and of the run with the change
+func compareInt(base, run int64) string {
+	if base == run {
+		return fmt.Sprintf("%d", run)
+	}
+	return fmt.Sprintf("%d -> %d (%+d)", base, run, run-base)
+}
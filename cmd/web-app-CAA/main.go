@@ -63,6 +63,11 @@ func getSwaggerFilePath(filename string) string {
 // @externalDocs.url          https://swagger.io/resources/open-api/
 
 func main() {
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		os.Exit(runEval(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg := config.Load()
 
@@ -82,6 +87,16 @@ func main() {
 	database.Initialize(cfg)
 	db := database.GetDB()
 
+	// Seed the built-in starter grids as templates on first start
+	if err := services.SeedGridTemplates(cfg); err != nil {
+		log.Fatalf("Failed to seed grid templates: %v", err)
+	}
+
+	// Seed the default LLM prompt templates on first start
+	if err := services.SeedPromptTemplates(); err != nil {
+		log.Fatalf("Failed to seed prompt templates: %v", err)
+	}
+
 	// Initialize RBAC service
 	rbacModelPath := filepath.Join("configs", "rbac_model.conf")
 	rbacService, err := services.NewRBACService(db, rbacModelPath)
@@ -101,8 +116,9 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+		c.Header("Access-Control-Expose-Headers", "ETag, X-Grid-Revision")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -122,9 +138,12 @@ func main() {
 	log.Printf("[MIDDLEWARE] Static files served from 'web/static' directory")
 
 	// Create other handlers (keeping existing ones for now)
-	gridHandlers := handlers.NewGridHandlers(cfg)
-	aiHandlers := handlers.NewAIHandlers(cfg)
-	arasaacHandlers := handlers.NewArasaacHandlers()
+	gridEvents := services.NewMemoryGridEventBroker(cfg.Grid.EventBufferSize)
+	imageService := services.NewImageService(cfg)
+	languageService := services.NewLanguageService(database.DB, cfg)
+	gridHandlers := handlers.NewGridHandlers(cfg, gridEvents, imageService, languageService)
+	imageHandlers := handlers.NewImageHandlers(imageService)
+	arasaacHandlers := handlers.NewArasaacHandlers(languageService)
 	pageHandlers := handlers.NewPageHandlers()
 	rbacHandler := handlers.NewRBACHandler(rbacService)
 
@@ -133,9 +152,16 @@ func main() {
 	userHandler := handlers.NewUserHandler(userManagementService)
 	adminHandler := handlers.NewAdminHandler(userManagementService, rbacService, cfg)
 
-	// Initialize LLM service and RAG knowledge handler
+	// Initialize the LLM service shared by the AI and RAG knowledge handlers
 	llmService := services.NewLLMService(cfg)
+	aiHandlers := handlers.NewAIHandlers(llmService, languageService)
+	languageHandler := handlers.NewLanguageHandler(llmService, languageService)
 	ragKnowledgeHandler := handlers.NewRagKnowledgeHandler(llmService)
+	promptTemplateHandler := handlers.NewPromptTemplateHandler(llmService)
+
+	// Initialize AI usage metering and quotas
+	aiUsageService := services.NewAIUsageService(database.DB, rbacService, cfg)
+	aiUsageHandler := handlers.NewAIUsageHandler(aiUsageService)
 
 	// Page routes (serve templates for specific paths)
 	r.GET("/login", pageHandlers.ServeLogin)
@@ -154,6 +180,9 @@ func main() {
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/revoke", authHandler.RevokeToken)
 		}
+
+		// Grid templates are public so the setup page can list them during registration
+		api.GET("/grid/templates", gridHandlers.ListTemplates)
 	}
 
 	// Protected API Endpoints (authentication required)
@@ -208,6 +237,40 @@ func main() {
 			// Analytics endpoints
 			admin.GET("/analytics/users", adminHandler.GetUserAnalytics)
 			admin.GET("/analytics/grids", adminHandler.GetGridAnalytics)
+			admin.GET("/analytics/ai", aiUsageHandler.GetAIAnalytics)
+
+			// Grid structure validation and repair
+			admin.GET("/grids/validation", gridHandlers.AdminGridValidation)
+			admin.GET("/grids/:board_id/validate", gridHandlers.AdminValidateBoard)
+			admin.POST("/grids/:board_id/repair", gridHandlers.AdminRepairBoard)
+
+			// Grid templates offered during setup
+			admin.GET("/grid-templates", gridHandlers.ListTemplates)
+			admin.POST("/grid-templates", gridHandlers.AdminCreateTemplate)
+			admin.GET("/grid-templates/:id", gridHandlers.AdminGetTemplate)
+			admin.PUT("/grid-templates/:id", gridHandlers.AdminUpdateTemplate)
+			admin.DELETE("/grid-templates/:id", gridHandlers.AdminDeleteTemplate)
+			admin.POST("/grids/:board_id/template", gridHandlers.AdminSaveBoardAsTemplate)
+
+			// AI result cache
+			admin.GET("/ai/cache", aiHandlers.AdminCacheStats)
+			admin.DELETE("/ai/cache", aiHandlers.AdminPurgeCache)
+
+			// LLM prompt templates, versioned and swapped in without restart
+			admin.GET("/prompts", promptTemplateHandler.ListPromptTemplates)
+			admin.POST("/prompts/reload", promptTemplateHandler.ReloadPromptTemplates)
+			admin.GET("/prompts/:name", promptTemplateHandler.GetPromptTemplate)
+			admin.PUT("/prompts/:name", promptTemplateHandler.UpdatePromptTemplate)
+			admin.POST("/prompts/:name/validate", promptTemplateHandler.ValidatePromptTemplate)
+			admin.GET("/prompts/:name/versions", promptTemplateHandler.ListPromptTemplateVersions)
+			admin.GET("/prompts/:name/versions/:version", promptTemplateHandler.GetPromptTemplateVersion)
+			admin.POST("/prompts/:name/versions/:version/restore", promptTemplateHandler.RestorePromptTemplateVersion)
+
+			// AI quotas
+			admin.GET("/ai/quotas", aiUsageHandler.ListAIQuotas)
+			admin.PUT("/ai/quotas", aiUsageHandler.SetAIQuota)
+			admin.GET("/ai/quotas/users/:user_id", aiUsageHandler.GetUserAIQuota)
+			admin.DELETE("/ai/quotas/:scope/:subject", aiUsageHandler.DeleteAIQuota)
 		}
 
 		protected.POST("/check-editor-password", authHandler.CheckEditorPassword)
@@ -218,14 +281,38 @@ func main() {
 		protected.GET("/grid", gridHandlers.GetGrid)
 		protected.POST("/grid", gridHandlers.SaveGrid)
 
-		// Granular grid endpoints with RBAC
-		protected.POST("/grid/item", middleware.RBACMiddleware(rbacService, "grids", "create"), gridHandlers.AddItem)
-		protected.PUT("/grid/item/:id", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.UpdateItem)
-		protected.DELETE("/grid/item/:id", middleware.RBACMiddleware(rbacService, "grids", "delete"), gridHandlers.DeleteItem)
+		// Grid endpoints scoped to the default board, and the same endpoints per board
+		registerGridRoutes(protected, gridHandlers, rbacService)
 
-		// AI endpoints
-		protected.POST("/conjugate", middleware.RBACMiddleware(rbacService, "ai", "use"), aiHandlers.Conjugate)
-		protected.POST("/correct", middleware.RBACMiddleware(rbacService, "ai", "use"), aiHandlers.Correct)
+		// Board management
+		boards := protected.Group("/boards")
+		{
+			boards.GET("", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.ListBoards)
+			boards.POST("", middleware.RBACMiddleware(rbacService, "grids", "create"), gridHandlers.CreateBoard)
+			boards.GET("/active", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.GetActiveBoard)
+			boards.PUT("/active", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.SetActiveBoard)
+			boards.GET("/:board_id", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.GetBoard)
+			boards.PUT("/:board_id", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.UpdateBoard)
+			boards.DELETE("/:board_id", middleware.RBACMiddleware(rbacService, "grids", "delete"), gridHandlers.DeleteBoard)
+
+			board := boards.Group("/:board_id")
+			board.GET("/grid", gridHandlers.GetGrid)
+			board.POST("/grid", gridHandlers.SaveGrid)
+			registerGridRoutes(board, gridHandlers, rbacService)
+		}
+
+		// AI endpoints; calls reaching the LLM are metered and subject to the daily quota
+		aiUsage := middleware.AIUsageMiddleware(aiUsageService)
+		protected.POST("/conjugate", middleware.RBACMiddleware(rbacService, "ai", "use"), aiUsage, aiHandlers.Conjugate)
+		protected.POST("/conjugate/stream", middleware.RBACMiddleware(rbacService, "ai", "use"), aiUsage, aiHandlers.ConjugateStream)
+		protected.GET("/tenses", middleware.RBACMiddleware(rbacService, "ai", "use"), aiHandlers.Tenses)
+		protected.POST("/correct", middleware.RBACMiddleware(rbacService, "ai", "use"), aiUsage, aiHandlers.Correct)
+		protected.POST("/correct/stream", middleware.RBACMiddleware(rbacService, "ai", "use"), aiUsage, aiHandlers.CorrectStream)
+		protected.GET("/ai/usage", middleware.RBACMiddleware(rbacService, "ai", "use"), aiUsageHandler.GetMyAIUsage)
+
+		// Languages of the AI endpoints and ARASAAC searches, and the user's preferred one
+		protected.GET("/languages", languageHandler.ListLanguages)
+		protected.PUT("/languages/preferred", languageHandler.SetPreferredLanguage)
 
 		// RAG Knowledge management endpoints (admin only)
 		ragKnowledge := protected.Group("/rag-knowledge")
@@ -233,11 +320,16 @@ func main() {
 		{
 			ragKnowledge.GET("", ragKnowledgeHandler.GetRagKnowledge)
 			ragKnowledge.PUT("", ragKnowledgeHandler.UpdateRagKnowledge)
+			ragKnowledge.PATCH("", ragKnowledgeHandler.PatchRagKnowledge)
+			ragKnowledge.POST("/validate", ragKnowledgeHandler.ValidateRagKnowledge)
+			ragKnowledge.GET("/schema", ragKnowledgeHandler.GetRagKnowledgeSchema)
+			ragKnowledge.GET("/diff", ragKnowledgeHandler.DiffRagKnowledge)
+			ragKnowledge.GET("/history", ragKnowledgeHandler.GetRagKnowledgeHistory)
 			ragKnowledge.POST("/reload", ragKnowledgeHandler.ReloadRagKnowledge)
 			ragKnowledge.POST("/backup", ragKnowledgeHandler.BackupRagKnowledge)
 			ragKnowledge.GET("/backups", ragKnowledgeHandler.ListRagKnowledgeBackups)
-			ragKnowledge.POST("/restore/:backup_key", ragKnowledgeHandler.RestoreRagKnowledgeFromBackup)
-			ragKnowledge.GET("/health", ragKnowledgeHandler.CheckS3Health)
+			ragKnowledge.POST("/restore/*backup_key", ragKnowledgeHandler.RestoreRagKnowledgeFromBackup)
+			ragKnowledge.GET("/health", ragKnowledgeHandler.CheckRagStorageHealth)
 		}
 
 		// ARASAAC endpoints (moved from AI, requires basic authentication but no special AI permissions)
@@ -247,6 +339,9 @@ func main() {
 	// Public ARASAAC icon endpoint (no auth required for image serving)
 	r.GET("/api/arasaac/icon/:id", arasaacHandlers.GetIcon)
 
+	// Public uploaded image endpoint (content-addressed URLs stored in grid items)
+	r.GET("/api/images/*key", imageHandlers.GetImage)
+
 	// Chrome DevTools endpoint (to avoid 404 logs)
 	r.GET("/.well-known/appspecific/com.chrome.devtools.json", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{})
@@ -460,3 +555,36 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// registerGridRoutes registers the granular grid endpoints on a route group. They act
+// on the board named by the group's :board_id parameter, or on the default board.
+func registerGridRoutes(group *gin.RouterGroup, gridHandlers *handlers.GridHandlers, rbacService *services.RBACService) {
+	// Granular grid endpoints with RBAC
+	group.POST("/grid/item", middleware.RBACMiddleware(rbacService, "grids", "create"), gridHandlers.AddItem)
+	group.PUT("/grid/item/:id", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.UpdateItem)
+	group.DELETE("/grid/item/:id", middleware.RBACMiddleware(rbacService, "grids", "delete"), gridHandlers.DeleteItem)
+	group.POST("/grid/item/:id/move", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.MoveItem)
+	group.PUT("/grid/order", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.ReorderItems)
+
+	// Open Board Format interchange
+	group.GET("/grid/export/obz", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.ExportOBZ)
+	group.POST("/grid/import", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.ImportOpenBoard)
+
+	// Real-time grid change notifications (Server-Sent Events)
+	group.GET("/grid/events", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.GridEvents)
+
+	// Grid version history
+	group.GET("/grid/versions", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.ListVersions)
+	group.GET("/grid/versions/diff", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.DiffVersions)
+	group.GET("/grid/versions/:version", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.GetVersion)
+	group.POST("/grid/versions/:version/restore", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.RestoreVersion)
+
+	// Trash of deleted items
+	group.GET("/grid/trash", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.ListTrash)
+	group.POST("/grid/trash/:id/restore", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.RestoreTrash)
+	group.DELETE("/grid/trash/:id", middleware.RBACMiddleware(rbacService, "grids", "delete"), gridHandlers.DeleteTrash)
+
+	// Grid structure validation and repair
+	group.GET("/grid/validate", middleware.RBACMiddleware(rbacService, "grids", "read"), gridHandlers.ValidateGrid)
+	group.POST("/grid/repair", middleware.RBACMiddleware(rbacService, "grids", "update"), gridHandlers.RepairGrid)
+}
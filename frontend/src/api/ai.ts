@@ -1,29 +1,61 @@
 import { apiRequest } from './client'
-import { ConjugationRequest, CorrectionRequest, CorrectionResponse, TenseType, ApiResponse } from '../types'
+import { AIUsageStatus, ConjugationRequest, CorrectionRequest, CorrectionResponse, LanguageCode, LanguagesResponse, TensesResponse, TenseType, ApiResponse } from '../types'
 
 export const aiApi = {
   /**
-   * Correct a sentence using AI
+   * Correct a sentence using AI, in the user's preferred language unless one is given
    */
-  correctSentence: async (sentence: string): Promise<ApiResponse<CorrectionResponse>> => {
-    const request: CorrectionRequest = { sentence }
+  correctSentence: async (sentence: string, language?: LanguageCode): Promise<ApiResponse<CorrectionResponse>> => {
+    const request: CorrectionRequest = { sentence, language }
     return apiRequest<CorrectionResponse>('POST', '/api/correct', request)
   },
 
   /**
-   * Conjugate verbs based on context
+   * Conjugate verbs based on context, in the user's preferred language unless one is given
    */
   conjugateVerbs: async (
     sentence: string, 
     baseForms: string[], 
-    tense: TenseType
+    tense: TenseType | string,
+    language?: LanguageCode
   ): Promise<ApiResponse<Record<string, string>>> => {
     const request: ConjugationRequest = {
       sentence,
       base_forms: baseForms,
-      tense
+      tense,
+      language
     }
     return apiRequest<Record<string, string>>('POST', '/api/conjugate', request)
+  },
+
+  /**
+   * List the tenses the conjugation endpoint supports in a language
+   * (the user's preferred one by default)
+   */
+  getTenses: async (language?: LanguageCode): Promise<ApiResponse<TensesResponse>> => {
+    const query = language ? `?language=${encodeURIComponent(language)}` : ''
+    return apiRequest<TensesResponse>('GET', `/api/tenses${query}`)
+  },
+
+  /**
+   * List the supported languages with the user's preferred one
+   */
+  getLanguages: async (): Promise<ApiResponse<LanguagesResponse>> => {
+    return apiRequest<LanguagesResponse>('GET', '/api/languages')
+  },
+
+  /**
+   * Set the user's preferred language; an empty one goes back to the default
+   */
+  setPreferredLanguage: async (language: LanguageCode | ''): Promise<ApiResponse<LanguagesResponse>> => {
+    return apiRequest<LanguagesResponse>('PUT', '/api/languages/preferred', { language })
+  },
+
+  /**
+   * Get today's AI usage and daily quota of the current user
+   */
+  getUsage: async (): Promise<ApiResponse<AIUsageStatus>> => {
+    return apiRequest<AIUsageStatus>('GET', '/api/ai/usage')
   }
 }
 
@@ -1,5 +1,5 @@
 import { apiRequest } from './client'
-import { ApiResponse, ArasaacIcon, ArasaacSearchResponse } from '../types'
+import { ApiResponse, ArasaacIcon, ArasaacSearchResponse, LanguageCode } from '../types'
 
 export type { ArasaacIcon, ArasaacSearchResponse } from '../types'
 
@@ -11,11 +11,12 @@ export interface EnhancedArasaacSearchResponse extends ArasaacSearchResponse {
 
 export const arasaacApi = {
   /**
-   * Search ARASAAC icons by query with optional preloading
+   * Search ARASAAC icons by query with optional preloading, in the user's
+   * preferred language unless one is given
    */
   searchIcons: async (
     query: string, 
-    options?: { preload?: boolean; limit?: number }
+    options?: { preload?: boolean; limit?: number; language?: LanguageCode }
   ): Promise<ApiResponse<EnhancedArasaacSearchResponse>> => {
     if (!query || query.trim().length === 0) {
       return {
@@ -31,6 +32,9 @@ export const arasaacApi = {
     if (options?.limit && options.limit > 0 && options.limit <= 20) {
       params.append('limit', options.limit.toString())
     }
+    if (options?.language) {
+      params.append('language', options.language)
+    }
 
     return apiRequest<EnhancedArasaacSearchResponse>(
       'GET', 
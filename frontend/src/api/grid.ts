@@ -1,6 +1,49 @@
-import { apiRequest } from './client'
+import { apiClient, apiRequest, API_BASE_URL } from './client'
 import { Categories, GridItem, ApiResponse } from '../types'
 
+// Latest grid revision (ETag) received from the server. Writes send it back in
+// If-Match so the server can reject edits based on a stale grid (412/409).
+let gridRevision: string | null = null
+
+apiClient.interceptors.response.use(
+  (response) => {
+    const etag = response.headers?.etag
+    if (etag && response.config.url?.startsWith('/api/grid')) {
+      gridRevision = etag
+    }
+    return response
+  },
+  (error) => Promise.reject(error)
+)
+
+/**
+ * Grid change pushed by the server over /api/grid/events
+ */
+export interface GridEvent {
+  action?: string
+  summary?: string
+  revision: number
+  timestamp?: string
+}
+
+/**
+ * Deleted item that can be restored from the trash
+ */
+export interface GridTrashEntry {
+  id: string
+  item_id: string
+  label: string
+  type: string
+  parent_category: string
+  item_count: number
+  deleted_at: string
+  expires_at?: string | null
+}
+
+const revisionNumber = (etag: string | null): number => (etag ? parseInt(etag.replace(/\D/g, ''), 10) || 0 : 0)
+
+const withRevision = () => (gridRevision ? { headers: { 'If-Match': gridRevision } } : undefined)
+
 export const gridApi = {
   /**
    * Get user's grid data
@@ -9,31 +52,94 @@ export const gridApi = {
     return apiRequest<Categories>('GET', '/api/grid')
   },
 
+  /**
+   * Current grid revision, as last returned by the server
+   */
+  getRevision: (): string | null => gridRevision,
+
+  /**
+   * Subscribe to grid changes made on other devices. onChange runs when the server
+   * reports a revision newer than the one this client holds. Returns an unsubscribe function.
+   */
+  subscribeToEvents: (onChange: (event: GridEvent) => void): (() => void) => {
+    const token = localStorage.getItem('jwt_token')
+    if (!token || typeof EventSource === 'undefined') {
+      return () => {}
+    }
+
+    const source = new EventSource(`${API_BASE_URL}/api/grid/events?token=${encodeURIComponent(token)}`)
+    const handleEvent = (message: MessageEvent) => {
+      const event = JSON.parse(message.data) as GridEvent
+      // Before the first grid load there is nothing stale to refresh
+      if (gridRevision !== null && event.revision > revisionNumber(gridRevision)) {
+        onChange(event)
+      }
+    }
+    source.addEventListener('ready', handleEvent)
+    source.addEventListener('grid', handleEvent)
+    return () => source.close()
+  },
+
   /**
    * Save complete grid data
    */
   saveGrid: async (categories: Categories): Promise<ApiResponse<void>> => {
-    return apiRequest<void>('POST', '/api/grid', categories)
+    return apiRequest<void>('POST', '/api/grid', categories, withRevision())
   },
 
   /**
    * Add a new item to the grid
    */
   addItem: async (item: Omit<GridItem, 'id'>, parentCategory: string): Promise<ApiResponse<GridItem>> => {
-    return apiRequest<GridItem>('POST', '/api/grid/item', { item, parentCategory })
+    return apiRequest<GridItem>('POST', '/api/grid/item', { item, parentCategory }, withRevision())
   },
 
   /**
    * Update an existing item
    */
   updateItem: async (itemId: string, updates: Partial<GridItem>): Promise<ApiResponse<{ updatedIcon?: string }>> => {
-    return apiRequest<{ updatedIcon?: string }>('PUT', `/api/grid/item/${itemId}`, updates)
+    return apiRequest<{ updatedIcon?: string }>('PUT', `/api/grid/item/${itemId}`, updates, withRevision())
   },
 
   /**
-   * Delete an item
+   * Move an item to a position on the same or another page
+   */
+  moveItem: async (itemId: string, parentCategory: string, position?: number): Promise<ApiResponse<void>> => {
+    return apiRequest<void>('POST', `/api/grid/item/${itemId}/move`, { parentCategory, position }, withRevision())
+  },
+
+  /**
+   * Reorder the items of one or more pages; each page lists all its item IDs
+   */
+  reorderItems: async (categories: Record<string, string[]>): Promise<ApiResponse<void>> => {
+    return apiRequest<void>('PUT', '/api/grid/order', { categories }, withRevision())
+  },
+
+  /**
+   * Move an item to the trash; categories take their pages with them
    */
   deleteItem: async (itemId: string, categoryTarget?: string): Promise<ApiResponse<void>> => {
-    return apiRequest<void>('DELETE', `/api/grid/item/${itemId}`, { categoryTarget })
+    return apiRequest<void>('DELETE', `/api/grid/item/${itemId}`, { categoryTarget }, withRevision())
+  },
+
+  /**
+   * List deleted items that can still be restored
+   */
+  listTrash: async (): Promise<ApiResponse<{ entries: GridTrashEntry[] }>> => {
+    return apiRequest<{ entries: GridTrashEntry[] }>('GET', '/api/grid/trash')
+  },
+
+  /**
+   * Restore a trash entry with its nested items
+   */
+  restoreTrash: async (entryId: string): Promise<ApiResponse<{ parent_category: string; items_restored: number }>> => {
+    return apiRequest<{ parent_category: string; items_restored: number }>('POST', `/api/grid/trash/${entryId}/restore`, undefined, withRevision())
+  },
+
+  /**
+   * Permanently delete a trash entry
+   */
+  deleteTrash: async (entryId: string): Promise<ApiResponse<void>> => {
+    return apiRequest<void>('DELETE', `/api/grid/trash/${entryId}`)
   },
 }
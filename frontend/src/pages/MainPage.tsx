@@ -1,6 +1,7 @@
 import React, { useEffect, useState } from 'react'
 import { useAuthStore } from '../stores/authStore'
 import { useGridStore } from '../stores/gridStore'
+import { gridApi } from '../api/grid'
 import SymbolGrid from '../components/SymbolGrid'
 import TextBar from '../components/TextBar'
 import TenseButtons from '../components/TenseButtons'
@@ -83,6 +84,12 @@ const MainPage: React.FC = () => {
     return () => document.removeEventListener('click', handleClickOutside)
   }, [currentSize, user, token]) // Removed loadGrid from dependencies to prevent infinite loop
 
+  // Reload the grid when it is changed on another device
+  useEffect(() => {
+    if (!user || !token) return
+    return gridApi.subscribeToEvents(() => loadGrid())
+  }, [user, token])
+
   // Don't render the main page if there's no authenticated user
   if (!user) {
     console.log('MainPage: no user, returning null')
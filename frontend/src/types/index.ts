@@ -44,7 +44,7 @@ export interface RefreshTokenResponse {
 // Symbol and Grid types
 export type SymbolType = 'nome' | 'verbo' | 'aggettivo' | 'altro'
 export type ItemType = 'symbol' | 'category' | 'system'
-export type TenseType = 'presente' | 'passato' | 'futuro'
+export type TenseType = 'presente' | 'passato' | 'imperfetto' | 'futuro'
 export type SizeType = 'small' | 'medium' | 'big'
 
 export interface BaseItem {
@@ -96,19 +96,77 @@ export interface ApiResponse<T = any> {
   error?: string
 }
 
+// Languages of the AI services and ARASAAC searches
+export type LanguageCode = 'it' | 'es' | 'en'
+
 export interface ConjugationRequest {
   sentence: string
   base_forms: string[]
-  tense: TenseType
+  // Tenses of other languages are listed by /api/tenses?language=
+  tense: TenseType | string
+  language?: LanguageCode
+  detailed?: boolean
+}
+
+export type AIOutputSource = 'model' | 'rules' | 'input'
+
+export interface DetailedConjugationResponse {
+  language: LanguageCode
+  conjugations: Record<string, string>
+  sources: Record<string, AIOutputSource>
+  attempts: number
+  // RAG entries injected into the prompt
+  rag_entries?: string[]
+}
+
+export interface TenseInfo {
+  id: TenseType | string
+  name: string
+  description?: string
+}
+
+export interface TensesResponse {
+  language: LanguageCode
+  tenses: TenseInfo[]
+}
+
+export interface LanguageInfo {
+  code: LanguageCode
+  name: string
+  tenses: TenseInfo[]
+}
+
+export interface LanguagesResponse {
+  languages: LanguageInfo[]
+  default: LanguageCode
+  // Empty when the user uses the default language
+  preferred: LanguageCode | ''
+  language: LanguageCode
+}
+
+export interface AIUsageStatus {
+  user_id: string
+  day: string
+  requests: number
+  request_limit: number
+  tokens: number
+  token_limit: number
+  limit_source: string
+  exceeded: boolean
+  resets_at: string
 }
 
 export interface CorrectionRequest {
   sentence: string
+  language?: LanguageCode
 }
 
 export interface CorrectionResponse {
+  language?: LanguageCode
   corrected_sentence: string
   original_sentence: string
+  source?: AIOutputSource
+  attempts?: number
 }
 
 // ARASAAC API types
@@ -125,6 +183,7 @@ export interface ArasaacIcon {
 
 export interface ArasaacSearchResponse {
   icons: ArasaacIcon[]
+  language?: LanguageCode
 }
 
 // Modal and UI types
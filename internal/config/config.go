@@ -26,11 +26,24 @@ type Config struct {
 	// Database configuration
 	Database DatabaseConfig
 
+	// Grid configuration
+	Grid GridConfig
+
+	// Uploaded image configuration
+	Images ImageConfig
+
+	// DefaultLanguage is the language of the AI language services and of
+	// ARASAAC searches for users without a preference
+	DefaultLanguage string
+
 	// External services configuration
 	Ollama OllamaConfig
 	LLM    LLMConfig
 	APIs   APIConfig
 	S3     S3Config
+
+	// RAG knowledge storage configuration
+	RagStorage RagStorageConfig
 }
 
 // RSAKeyConfig holds RSA signing key configuration
@@ -59,6 +72,23 @@ type DatabaseConfig struct {
 	MaxLifetime  time.Duration
 }
 
+// GridConfig holds grid management configuration
+type GridConfig struct {
+	VersionRetention int           // Number of grid versions kept per board (0 keeps all)
+	TrashRetention   time.Duration // How long deleted items can be restored from the trash (0 keeps them until emptied)
+	RequireIfMatch   bool          // Reject grid writes that do not send an If-Match revision
+	EventHeartbeat   time.Duration // Interval between keep-alive comments on the grid event stream
+	EventBufferSize  int           // Events buffered per subscriber before new ones are dropped
+}
+
+// ImageConfig holds configuration for images uploaded as grid symbols
+type ImageConfig struct {
+	MaxUploadBytes int    // Largest accepted upload, in bytes of decoded image data
+	MaxDimension   int    // Longest side of a stored image; larger images are scaled down
+	JPEGQuality    int    // Quality used when re-encoding opaque images as JPEG
+	LocalDir       string // Directory used to store images when S3 is disabled
+}
+
 // OllamaConfig holds Ollama client configuration
 type OllamaConfig struct {
 	BaseURL string
@@ -71,6 +101,56 @@ type LLMConfig struct {
 	BackendType string
 	Model       string
 	OpenAIKey   string
+	Fallbacks   []LLMProviderConfig // Providers tried in order when the primary backend fails
+	// RequestTimeout bounds one conjugation or correction across the whole
+	// fallback chain; when it expires the rule-based answer is used
+	RequestTimeout time.Duration
+	// RepairAttempts is how many times an answer that does not match the
+	// expected JSON is sent back to the LLM with the problems found
+	RepairAttempts int
+	Cache          LLMCacheConfig
+	Quota          LLMQuotaConfig
+	RAG            RAGConfig
+}
+
+// RAGConfig holds configuration of the retrieval of the RAG knowledge injected
+// into conjugation prompts
+type RAGConfig struct {
+	// Retrieval injects only the entries relevant to the requested verbs
+	// instead of the tense's whole section
+	Retrieval bool
+	// Embeddings selects the backend matching verbs to entries they have no
+	// exact or lemma match for: "ollama", "local" or "" for none
+	Embeddings        string
+	EmbeddingsHost    string
+	EmbeddingsModel   string
+	EmbeddingsTimeout time.Duration
+	TopK              int     // Entries added by embeddings for each verb without a match
+	MinScore          float64 // Cosine similarity an entry needs to be added by embeddings
+}
+
+// LLMQuotaConfig holds the default daily AI quota of a user, applied when neither
+// the user nor any of their roles has a quota of its own. 0 means unlimited.
+type LLMQuotaConfig struct {
+	DailyRequests int // Calls of the AI endpoints per day
+	DailyTokens   int // Prompt and completion tokens per day
+}
+
+// LLMCacheConfig holds configuration of the conjugation and correction result cache
+type LLMCacheConfig struct {
+	Enabled bool
+	Size    int           // Results kept in memory, least recently used are evicted first
+	TTL     time.Duration // How long a result stays valid (0 keeps it until invalidated)
+	Persist bool          // Also store results in the database so they survive restarts
+}
+
+// LLMProviderConfig configures one provider of the LLM fallback chain
+type LLMProviderConfig struct {
+	BackendType string
+	Host        string
+	Model       string
+	APIKey      string
+	Timeout     time.Duration
 }
 
 // APIConfig holds external API configuration
@@ -90,6 +170,15 @@ type S3Config struct {
 	ForcePathStyle  bool   // For S3-compatible services
 }
 
+// RagStorageConfig holds the storage of the RAG knowledge files and their backups
+type RagStorageConfig struct {
+	// Backend is "s3", "local" or "none" (edits only change memory); empty
+	// selects s3 when S3 is enabled, local otherwise
+	Backend         string
+	LocalDir        string // Directory of the local backend
+	BackupRetention int    // Backups kept per language, the oldest pruned first; 0 keeps all
+}
+
 // Load loads the application configuration
 func Load() *Config {
 	// Load .env file
@@ -127,6 +216,25 @@ func Load() *Config {
 			MaxLifetime:  time.Hour,
 		},
 
+		// Grid configuration
+		Grid: GridConfig{
+			VersionRetention: getEnvInt("GRID_VERSION_RETENTION", 50),
+			TrashRetention:   getEnvDuration("GRID_TRASH_RETENTION", 30*24*time.Hour),
+			RequireIfMatch:   getEnvBool("GRID_REQUIRE_IF_MATCH", true),
+			EventHeartbeat:   getEnvDuration("GRID_EVENT_HEARTBEAT", 25*time.Second),
+			EventBufferSize:  getEnvInt("GRID_EVENT_BUFFER", 16),
+		},
+
+		// Uploaded image configuration
+		Images: ImageConfig{
+			MaxUploadBytes: getEnvInt("IMAGE_MAX_UPLOAD_BYTES", 10<<20),
+			MaxDimension:   getEnvInt("IMAGE_MAX_DIMENSION", 512),
+			JPEGQuality:    getEnvInt("IMAGE_JPEG_QUALITY", 85),
+			LocalDir:       getEnv("IMAGE_LOCAL_DIR", "./data/images"),
+		},
+
+		DefaultLanguage: getEnv("DEFAULT_LANGUAGE", "it"),
+
 		// External services configuration
 		Ollama: OllamaConfig{
 			BaseURL: getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
@@ -138,6 +246,29 @@ func Load() *Config {
 			BackendType: getEnv("BACKEND_TYPE", "ollama"),
 			Model:       getEnv("LLM_MODEL", ""),
 			OpenAIKey:   getEnv("OPENAI_API_KEY", ""),
+			Fallbacks:   loadLLMFallbacks(),
+
+			RequestTimeout: getEnvDuration("LLM_REQUEST_TIMEOUT", 2*time.Minute),
+			RepairAttempts: getEnvInt("LLM_REPAIR_ATTEMPTS", 1),
+			Cache: LLMCacheConfig{
+				Enabled: getEnvBool("LLM_CACHE_ENABLED", true),
+				Size:    getEnvInt("LLM_CACHE_SIZE", 1000),
+				TTL:     getEnvDuration("LLM_CACHE_TTL", 7*24*time.Hour),
+				Persist: getEnvBool("LLM_CACHE_PERSIST", false),
+			},
+			Quota: LLMQuotaConfig{
+				DailyRequests: getEnvInt("AI_QUOTA_DAILY_REQUESTS", 0),
+				DailyTokens:   getEnvInt("AI_QUOTA_DAILY_TOKENS", 0),
+			},
+			RAG: RAGConfig{
+				Retrieval:         getEnvBool("RAG_RETRIEVAL_ENABLED", true),
+				Embeddings:        strings.ToLower(getEnv("RAG_EMBEDDINGS", "")),
+				EmbeddingsHost:    getEnv("RAG_EMBEDDINGS_HOST", getEnv("OLLAMA_BASE_URL", "http://localhost:11434")),
+				EmbeddingsModel:   getEnv("RAG_EMBEDDINGS_MODEL", "nomic-embed-text"),
+				EmbeddingsTimeout: getEnvDuration("RAG_EMBEDDINGS_TIMEOUT", 10*time.Second),
+				TopK:              getEnvInt("RAG_RETRIEVAL_TOP_K", 2),
+				MinScore:          getEnvFloat("RAG_RETRIEVAL_MIN_SCORE", 0.6),
+			},
 		},
 
 		APIs: APIConfig{
@@ -154,6 +285,13 @@ func Load() *Config {
 			KeyPrefix:       getEnv("S3_KEY_PREFIX", "caa"),
 			ForcePathStyle:  getEnvBool("S3_FORCE_PATH_STYLE", true),
 		},
+
+		// RAG knowledge storage configuration
+		RagStorage: RagStorageConfig{
+			Backend:         strings.ToLower(getEnv("RAG_STORAGE", "")),
+			LocalDir:        getEnv("RAG_LOCAL_DIR", "./data/rag"),
+			BackupRetention: getEnvInt("RAG_BACKUP_RETENTION", 0),
+		},
 	}
 }
 
@@ -192,6 +330,19 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return boolVal
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("[CONFIG] Warning: Invalid float value for %s: %s, using default: %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return floatVal
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
@@ -230,6 +381,31 @@ func parseTrustedProxies() []string {
 	return result
 }
 
+// loadLLMFallbacks reads the LLM fallback chain from LLM_FALLBACK_* and, for
+// further providers, LLM_FALLBACK_2_*, LLM_FALLBACK_3_* and so on. The chain
+// ends at the first prefix without a BACKEND_TYPE.
+func loadLLMFallbacks() []LLMProviderConfig {
+	var fallbacks []LLMProviderConfig
+	for i := 1; ; i++ {
+		prefix := "LLM_FALLBACK_"
+		if i > 1 {
+			prefix = "LLM_FALLBACK_" + strconv.Itoa(i) + "_"
+		}
+
+		backendType := getEnv(prefix+"BACKEND_TYPE", "")
+		if backendType == "" {
+			return fallbacks
+		}
+		fallbacks = append(fallbacks, LLMProviderConfig{
+			BackendType: backendType,
+			Host:        getEnv(prefix+"HOST", ""),
+			Model:       getEnv(prefix+"MODEL", ""),
+			APIKey:      getEnv(prefix+"API_KEY", ""),
+			Timeout:     getEnvDuration(prefix+"TIMEOUT", 60*time.Second),
+		})
+	}
+}
+
 func loadRSAKeyConfig() RSAKeyConfig {
 	rotationDays := getEnvInt("RSA_KEY_ROTATION_DAYS", 30) // Default: rotate every 30 days
 	return RSAKeyConfig{
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"log"
+
+	"github.com/daniele/web-app-caa/internal/models"
+	"gorm.io/gorm"
+)
+
+// EnsureDefaultBoard returns the user's default board, creating it if the user has none
+func EnsureDefaultBoard(db *gorm.DB, userID string) (*models.Board, error) {
+	var board models.Board
+	err := db.Where("user_id = ? AND is_default = ?", userID, true).
+		Order("created_at ASC").
+		First(&board).Error
+	if err == nil {
+		return &board, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	board = models.Board{
+		UserID:    userID,
+		Name:      models.DefaultBoardName,
+		IsDefault: true,
+	}
+	if err := db.Create(&board).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DATABASE] Created default board %s for user %s", board.ID, userID)
+	return &board, nil
+}
+
+// MigrateDefaultBoards assigns grid items and versions created before boards
+// existed to their owner's default board.
+// This function is idempotent - it can be run multiple times safely
+func MigrateDefaultBoards(db *gorm.DB) error {
+	// Versions used to be numbered per user; they are now numbered per board
+	if db.Migrator().HasIndex(&models.GridVersion{}, "idx_grid_versions_user_version") {
+		log.Printf("[DATABASE] Dropping per-user grid version index")
+		if err := db.Migrator().DropIndex(&models.GridVersion{}, "idx_grid_versions_user_version"); err != nil {
+			return err
+		}
+	}
+
+	var userIDs []string
+	for _, model := range []interface{}{&models.GridItem{}, &models.GridVersion{}} {
+		var ids []string
+		if err := db.Model(model).
+			Where("board_id IS NULL OR board_id = ''").
+			Distinct().
+			Pluck("user_id", &ids).Error; err != nil {
+			return err
+		}
+		userIDs = append(userIDs, ids...)
+	}
+
+	migrated := make(map[string]bool)
+	for _, userID := range userIDs {
+		if migrated[userID] {
+			continue
+		}
+		migrated[userID] = true
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			board, err := EnsureDefaultBoard(tx, userID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.GridItem{}).
+				Where("user_id = ? AND (board_id IS NULL OR board_id = '')", userID).
+				Update("board_id", board.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.GridVersion{}).
+				Where("user_id = ? AND (board_id IS NULL OR board_id = '')", userID).
+				Update("board_id", board.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(migrated) > 0 {
+		log.Printf("[DATABASE] Moved grids of %d users to their default board", len(migrated))
+	}
+	return nil
+}
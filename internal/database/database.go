@@ -6,7 +6,7 @@
 // 1. AUTOMATIC SCHEMA MIGRATION (GORM AutoMigrate):
 //   - All table creation, column addition/modification, index creation
 //   - Handled automatically by GORM based on struct tags in models
-//   - Includes: User, GridItem, Role, Permission, UserRole, RolePermission, RefreshToken, SigningKey
+//   - Includes: User, GridItem, Role, Permission, UserRole, RolePermission, RefreshToken, SigningKey, GridVersion, Board, GridTrashEntry, GridTemplate, LLMCacheEntry, AIUsage, AIQuota, PromptTemplate
 //   - Benefits: No manual migration files needed, automatic schema updates, reduced errors
 //
 // 2. AUTOMATIC DATA SEEDING (database seeding functions):
@@ -70,10 +70,29 @@ func Initialize(cfg *config.Config) {
 		&models.RefreshToken{},
 		&models.SigningKey{},
 		&models.UserActivity{},
+		&models.GridVersion{},
+		&models.Board{},
+		&models.GridTrashEntry{},
+		&models.GridTemplate{},
+		&models.LLMCacheEntry{},
+		&models.AIUsage{},
+		&models.AIQuota{},
+		&models.PromptTemplate{},
+		&models.RagKnowledgeChange{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Move grids created before multiple boards existed onto a default board
+	if err := MigrateDefaultBoards(DB); err != nil {
+		log.Fatalf("Failed to migrate grids to boards: %v", err)
+	}
+
+	// Number prompt template versions per language
+	if err := MigratePromptTemplateLanguages(DB); err != nil {
+		log.Fatalf("Failed to migrate prompt template languages: %v", err)
+	}
+
 	// Automatically seed RBAC data (roles, permissions, default users)
 	if err := SeedRBACData(DB); err != nil {
 		log.Fatalf("Failed to seed RBAC data: %v", err)
@@ -109,7 +128,8 @@ func initializeMySQL(dbConfig config.DatabaseConfig, logLevel logger.LogLevel) (
 	log.Printf("[DATABASE] Connecting to MySQL at %s:%s/%s", dbConfig.Host, dbConfig.Port, dbConfig.Name)
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger:         logger.Default.LogMode(logLevel),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MySQL: %w", err)
@@ -145,7 +165,8 @@ func initializeSQLite(dbConfig config.DatabaseConfig, logLevel logger.LogLevel)
 
 	// Open database connection
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger:         logger.Default.LogMode(logLevel),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SQLite: %w", err)
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"log"
+
+	"github.com/daniele/web-app-caa/internal/models"
+	"gorm.io/gorm"
+)
+
+// MigratePromptTemplateLanguages drops the index that numbered prompt template
+// versions per name. Versions are now numbered per language and name, and the
+// templates stored before languages existed are the Italian ones.
+// This function is idempotent - it can be run multiple times safely
+func MigratePromptTemplateLanguages(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&models.PromptTemplate{}, "idx_prompt_templates_name_version") {
+		log.Printf("[DATABASE] Dropping per-name prompt template version index")
+		if err := db.Migrator().DropIndex(&models.PromptTemplate{}, "idx_prompt_templates_name_version"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
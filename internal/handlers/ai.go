@@ -1,32 +1,58 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/daniele/web-app-caa/internal/auth"
-	"github.com/daniele/web-app-caa/internal/config"
 	"github.com/daniele/web-app-caa/internal/models"
 	"github.com/daniele/web-app-caa/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// statusClientClosedRequest is the non-standard status recorded when the client
+// disconnects before the AI answer is ready
+const statusClientClosedRequest = 499
+
 // AIHandlers handles AI-related requests
 type AIHandlers struct {
-	aiService *services.AIService
+	aiService       *services.AIService
+	languageService *services.LanguageService
 }
 
 // NewAIHandlers creates a new AIHandlers instance
-func NewAIHandlers(cfg *config.Config) *AIHandlers {
+func NewAIHandlers(llmService *services.LLMService, languageService *services.LanguageService) *AIHandlers {
 	return &AIHandlers{
-		aiService: services.NewAIService(cfg),
+		aiService:       services.NewAIService(llmService),
+		languageService: languageService,
+	}
+}
+
+// resolveLanguage sets the language of a request: the one it names, else the
+// user's preference, else the default. It answers the request itself and
+// returns false when the language cannot be resolved.
+func (h *AIHandlers) resolveLanguage(c *gin.Context, logPrefix, userID string, language *string) bool {
+	resolved, err := h.languageService.ResolveLanguage(userID, *language)
+	if errors.Is(err, services.ErrUnsupportedLanguage) {
+		log.Printf("[%s] %v", logPrefix, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		log.Printf("[%s] Error resolving language: %v", logPrefix, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error resolving language."})
+		return false
 	}
+	*language = resolved
+	return true
 }
 
 // Conjugate handles conjugation requests and proxies them to the Python AI service
 // @Summary Conjugate verbs
-// @Description Conjugate verbs in Italian based on tense and context
+// @Description Conjugate verbs based on tense and context, in the request's language or else the user's preferred one. The tense must be one listed by /tenses for that language; it defaults to the language's first tense (presente in Italian) when omitted. The response maps each verb to its form; with "detailed": true it is a models.ConjugateResponse telling which forms came from the model and which from the rule-based fallback.
 // @Tags AI
 // @Accept json
 // @Produce json
@@ -53,12 +79,25 @@ func (h *AIHandlers) Conjugate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request payload."})
 		return
 	}
+	if !h.resolveLanguage(c, "CONJUGATE", userID, &req.Language) {
+		return
+	}
 
-	log.Printf("[CONJUGATE] Request data - Sentence: '%s', Words: %v, BaseForms: %v, Tense: %s",
-		req.Sentence, req.Words, req.BaseForms, req.Tense)
+	log.Printf("[CONJUGATE] Request data - Sentence: '%s', Words: %v, BaseForms: %v, Language: %s, Tense: %s",
+		req.Sentence, req.Words, req.BaseForms, req.Language, req.Tense)
 
 	// Forward request to AI service
-	conjugations, err := h.aiService.Conjugate(req)
+	conjugations, err := h.aiService.Conjugate(c.Request.Context(), req)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("[CONJUGATE] Client disconnected, request cancelled")
+		c.Status(statusClientClosedRequest)
+		return
+	}
+	if errors.Is(err, services.ErrUnsupportedTense) {
+		log.Printf("[CONJUGATE] %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("[CONJUGATE] Error proxying to AI service: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -68,12 +107,21 @@ func (h *AIHandlers) Conjugate(c *gin.Context) {
 	}
 
 	log.Printf("[CONJUGATE] AI service response received, returning conjugations to client")
-	c.JSON(http.StatusOK, conjugations)
+	c.JSON(http.StatusOK, conjugateResult(req, conjugations))
+}
+
+// conjugateResult shapes the conjugations as the request asked: the detailed
+// response, or only the verb to form map that clients used before
+func conjugateResult(req models.ConjugateRequest, resp *models.ConjugateResponse) interface{} {
+	if resp == nil || req.Detailed {
+		return resp
+	}
+	return resp.Conjugations
 }
 
 // Correct handles correction requests and proxies them to the Python AI service
 // @Summary Correct sentences
-// @Description Correct Italian sentences using AI language processing
+// @Description Correct sentences using AI language processing, in the request's language or else the user's preferred one. Source tells whether the sentence was corrected by the model or returned unchanged.
 // @Tags AI
 // @Accept json
 // @Produce json
@@ -100,11 +148,19 @@ func (h *AIHandlers) Correct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request payload."})
 		return
 	}
+	if !h.resolveLanguage(c, "CORRECT", userID, &req.Language) {
+		return
+	}
 
-	log.Printf("[CORRECT] Sentence to correct: '%s'", req.Sentence)
+	log.Printf("[CORRECT] Sentence to correct: '%s', Language: %s", req.Sentence, req.Language)
 
 	// Forward request to AI service
-	correctedData, err := h.aiService.Correct(req)
+	correctedData, err := h.aiService.Correct(c.Request.Context(), req)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("[CORRECT] Client disconnected, request cancelled")
+		c.Status(statusClientClosedRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("[CORRECT] Error proxying to AI service for correction: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -116,3 +172,61 @@ func (h *AIHandlers) Correct(c *gin.Context) {
 	log.Printf("[CORRECT] AI service response received, returning corrections to client")
 	c.JSON(http.StatusOK, correctedData)
 }
+
+// Tenses lists the tenses supported by the conjugation endpoint in a language
+// @Summary List supported tenses
+// @Description Get the tenses that can be sent to /conjugate in a language, by default the user's preferred one
+// @Tags AI
+// @Produce json
+// @Security BearerAuth
+// @Param language query string false "Language code (it, es, en)"
+// @Success 200 {object} models.TensesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tenses [get]
+func (h *AIHandlers) Tenses(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	language := c.Query("language")
+	if !h.resolveLanguage(c, "TENSES", userID, &language) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TensesResponse{Language: language, Tenses: h.aiService.Tenses(language)})
+}
+
+// AdminCacheStats returns the hit and miss statistics of the AI result cache
+// @Summary AI result cache statistics
+// @Description Get the size and hit/miss counters of the conjugation and correction cache (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.LLMCacheStatsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/ai/cache [get]
+func (h *AIHandlers) AdminCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.aiService.CacheStats())
+}
+
+// AdminPurgeCache drops all cached AI results
+// @Summary Purge AI result cache
+// @Description Drop all cached conjugation and correction results, in memory and in the database (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/ai/cache [delete]
+func (h *AIHandlers) AdminPurgeCache(c *gin.Context) {
+	h.aiService.PurgeCache()
+	log.Printf("[ADMIN] AI result cache purged by userId: %s", auth.GetUserID(c))
+	c.JSON(http.StatusOK, gin.H{"message": "AI result cache purged successfully"})
+}
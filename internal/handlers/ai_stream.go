@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseStream writes Server-Sent Events, sending the stream headers with the first
+// event so that errors occurring before any output can still be plain JSON
+type sseStream struct {
+	c       *gin.Context
+	started bool
+}
+
+// send writes one event and flushes it to the client
+func (s *sseStream) send(event string, data interface{}) error {
+	if err := s.c.Request.Context().Err(); err != nil {
+		return err
+	}
+	if !s.started {
+		s.c.Header("Content-Type", "text/event-stream")
+		s.c.Header("Cache-Control", "no-cache")
+		s.c.Header("Connection", "keep-alive")
+		s.c.Header("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+		s.c.Status(http.StatusOK)
+		s.started = true
+	}
+	s.c.SSEvent(event, data)
+	s.c.Writer.Flush()
+	return nil
+}
+
+// delta streams a piece of the LLM's answer
+func (s *sseStream) delta(content string) error {
+	return s.send("delta", gin.H{"content": content})
+}
+
+// finish sends the final result, or the error when the request failed. Errors
+// before the stream started are answered with a regular JSON response.
+func (s *sseStream) finish(logPrefix string, result interface{}, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Printf("[%s] Client disconnected, request cancelled", logPrefix)
+		if !s.started {
+			s.c.Status(statusClientClosedRequest)
+		}
+	case (errors.Is(err, services.ErrUnsupportedTense) || errors.Is(err, services.ErrUnsupportedLanguage)) && !s.started:
+		log.Printf("[%s] %v", logPrefix, err)
+		s.c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("[%s] Error streaming from AI service: %v", logPrefix, err)
+		if !s.started {
+			s.c.JSON(http.StatusInternalServerError, gin.H{"error": "Error communicating with the AI service."})
+			return
+		}
+		s.send("error", gin.H{"error": "Error communicating with the AI service."})
+	default:
+		log.Printf("[%s] AI service stream completed, returning result to client", logPrefix)
+		s.send("result", result)
+	}
+}
+
+// ConjugateStream handles conjugation requests, streaming the answer as it is generated
+// @Summary Conjugate verbs (streaming)
+// @Description Conjugate verbs like /conjugate, answering with Server-Sent Events: "delta" events carry pieces of the raw LLM answer as {"content": "..."}, then a single "result" event carries the validated conjugations, shaped like the /conjugate response (or an "error" event). Only the first LLM answer is streamed; repairs of an invalid answer are not. Closing the connection cancels the LLM request.
+// @Tags AI
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param request body models.ConjugateRequest true "Conjugation request"
+// @Success 200 {string} string "Server-Sent Events stream"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /conjugate/stream [post]
+func (h *AIHandlers) ConjugateStream(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	log.Printf("[CONJUGATE] Streaming conjugation request from userId: %s", userID)
+
+	var req models.ConjugateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CONJUGATE] Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request payload."})
+		return
+	}
+	if !h.resolveLanguage(c, "CONJUGATE", userID, &req.Language) {
+		return
+	}
+
+	stream := &sseStream{c: c}
+	conjugations, err := h.aiService.ConjugateStream(c.Request.Context(), req, stream.delta)
+	stream.finish("CONJUGATE", conjugateResult(req, conjugations), err)
+}
+
+// CorrectStream handles correction requests, streaming the answer as it is generated
+// @Summary Correct sentences (streaming)
+// @Description Correct a sentence like /correct, answering with Server-Sent Events: "delta" events carry pieces of the raw LLM answer as {"content": "..."}, then a single "result" event carries the corrected sentence (or an "error" event). Only the first LLM answer is streamed; repairs of an invalid answer are not. Closing the connection cancels the LLM request.
+// @Tags AI
+// @Accept json
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param request body models.CorrectRequest true "Correction request"
+// @Success 200 {string} string "Server-Sent Events stream"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /correct/stream [post]
+func (h *AIHandlers) CorrectStream(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	log.Printf("[CORRECT] Streaming correction request from userId: %s", userID)
+
+	var req models.CorrectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[CORRECT] Invalid request payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request payload."})
+		return
+	}
+	if !h.resolveLanguage(c, "CORRECT", userID, &req.Language) {
+		return
+	}
+
+	stream := &sseStream{c: c}
+	corrected, err := h.aiService.CorrectStream(c.Request.Context(), req, stream.delta)
+	stream.finish("CORRECT", corrected, err)
+}
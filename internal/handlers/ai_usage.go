@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds of the days covered by the AI usage report
+const (
+	defaultAIUsageDays = 7
+	maxAIUsageDays     = 366
+)
+
+// AIUsageHandler handles AI usage reports and quotas
+type AIUsageHandler struct {
+	usageService *services.AIUsageService
+}
+
+// NewAIUsageHandler creates a new AI usage handler
+func NewAIUsageHandler(usageService *services.AIUsageService) *AIUsageHandler {
+	return &AIUsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetMyAIUsage returns the current user's AI usage of the day against their quota
+// @Summary Get my AI usage
+// @Description Get the AI usage of the current day and the daily quota of the authenticated user. Limits of 0 are unlimited.
+// @Tags AI
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.AIQuotaStatusResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /ai/usage [get]
+func (h *AIUsageHandler) GetMyAIUsage(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	status, err := h.usageService.QuotaStatus(userID)
+	if err != nil {
+		log.Printf("[AI-USAGE] Error getting quota status for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetAIAnalytics returns AI usage analytics
+// @Summary Get AI usage analytics
+// @Description Get AI usage totals per day, endpoint, model and user over the last days, today included
+// @Tags Admin
+// @Produce json
+// @Param days query int false "Days covered (default 7, max 366)"
+// @Param user_id query string false "Only report the usage of this user"
+// @Success 200 {object} models.AIUsageReportResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/analytics/ai [get]
+func (h *AIUsageHandler) GetAIAnalytics(c *gin.Context) {
+	days := defaultAIUsageDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= maxAIUsageDays {
+			days = d
+		}
+	}
+
+	report, err := h.usageService.Report(days, c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetUserAIQuota returns a user's AI usage of the day against their quota
+// @Summary Get user AI quota
+// @Description Get the AI usage of the current day and the effective daily quota of a user
+// @Tags Admin
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.AIQuotaStatusResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/ai/quotas/users/{user_id} [get]
+func (h *AIUsageHandler) GetUserAIQuota(c *gin.Context) {
+	status, err := h.usageService.QuotaStatus(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// ListAIQuotas returns the AI quotas of users and roles
+// @Summary List AI quotas
+// @Description List the daily AI quotas set for users and roles. Users without one get the most generous quota of their roles, else the configured default.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.AIQuota
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/ai/quotas [get]
+func (h *AIUsageHandler) ListAIQuotas(c *gin.Context) {
+	quotas, err := h.usageService.ListQuotas()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quotas)
+}
+
+// SetAIQuota creates or replaces the AI quota of a user or role
+// @Summary Set AI quota
+// @Description Set the daily AI request and token limits of a user or role; 0 means unlimited
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body models.SetAIQuotaRequest true "Quota"
+// @Success 200 {object} models.AIQuota
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/ai/quotas [put]
+func (h *AIUsageHandler) SetAIQuota(c *gin.Context) {
+	var req models.SetAIQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quota, err := h.usageService.SetQuota(req)
+	if err != nil {
+		if err.Error() == "user not found" || err.Error() == "role not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
+
+// DeleteAIQuota removes the AI quota of a user or role
+// @Summary Delete AI quota
+// @Description Remove the daily AI quota of a user or role
+// @Tags Admin
+// @Produce json
+// @Param scope path string true "Quota scope (user or role)"
+// @Param subject path string true "User ID or role name"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/ai/quotas/{scope}/{subject} [delete]
+func (h *AIUsageHandler) DeleteAIQuota(c *gin.Context) {
+	if err := h.usageService.DeleteQuota(c.Param("scope"), c.Param("subject")); err != nil {
+		if err.Error() == "quota not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota deleted successfully"})
+}
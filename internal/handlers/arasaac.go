@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/services"
 	"github.com/daniele/web-app-caa/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -29,13 +31,14 @@ type ArasaacCacheEntry struct {
 
 // ArasaacHandlers handles ARASAAC icon search and caching
 type ArasaacHandlers struct {
-	cacheDir   string
-	cacheMutex sync.RWMutex
-	httpClient *http.Client
+	cacheDir        string
+	cacheMutex      sync.RWMutex
+	httpClient      *http.Client
+	languageService *services.LanguageService // Resolves the locale of searches
 }
 
 // NewArasaacHandlers creates a new ArasaacHandlers instance
-func NewArasaacHandlers() *ArasaacHandlers {
+func NewArasaacHandlers(languageService *services.LanguageService) *ArasaacHandlers {
 	cacheDir := "cache"
 
 	// Create cache directory if it doesn't exist
@@ -49,6 +52,7 @@ func NewArasaacHandlers() *ArasaacHandlers {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		languageService: languageService,
 	}
 }
 
@@ -202,7 +206,7 @@ func (h *ArasaacHandlers) fetchIconFromAPI(ctx context.Context, iconID string) (
 
 // SearchArasaac handles ARASAAC icon search requests with optional parallel icon preloading
 // @Summary Search ARASAAC icons
-// @Description Search for ARASAAC icons by keyword with optional parallel preloading
+// @Description Search for ARASAAC icons by keyword, in the given language or else the user's preferred one, with optional parallel preloading
 // @Tags ARASAAC
 // @Accept json
 // @Produce json
@@ -210,6 +214,7 @@ func (h *ArasaacHandlers) fetchIconFromAPI(ctx context.Context, iconID string) (
 // @Param query query string true "Search query"
 // @Param preload query boolean false "Whether to preload icon data in parallel"
 // @Param limit query integer false "Limit number of icons for preloading (max 20)"
+// @Param language query string false "Language of the keyword (it, es, en)"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 401 {object} map[string]interface{}
@@ -239,10 +244,21 @@ func (h *ArasaacHandlers) SearchArasaac(c *gin.Context) {
 		}
 	}
 
-	log.Printf("[ARASAAC-SEARCH] Search request from userId: %s, query: '%s', preload: %t, limit: %d", userID, query, preload, limit)
+	language, err := h.languageService.ResolveLanguage(userID, c.Query("language"))
+	if errors.Is(err, services.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[ARASAAC-SEARCH] Error resolving language: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search icons"})
+		return
+	}
+
+	log.Printf("[ARASAAC-SEARCH] Search request from userId: %s, query: '%s', language: %s, preload: %t, limit: %d", userID, query, language, preload, limit)
 
-	// Call ARASAAC API directly with Italian language parameter
-	arasaacURL := fmt.Sprintf("https://api.arasaac.org/api/pictograms/it/search/%s", url.QueryEscape(query))
+	// Call ARASAAC API directly with the language of the keyword
+	arasaacURL := fmt.Sprintf("https://api.arasaac.org/api/pictograms/%s/search/%s", language, url.QueryEscape(query))
 
 	resp, err := h.httpClient.Get(arasaacURL)
 	if err != nil {
@@ -281,6 +297,7 @@ func (h *ArasaacHandlers) SearchArasaac(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"icons":     icons,
+		"language":  language,
 		"preloaded": preload,
 		"total":     len(icons),
 	})
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveBoard returns the board addressed by the :board_id route parameter, or the
+// user's default board on the board-less grid routes. It writes the error response
+// itself and returns false on failure.
+func (h *GridHandlers) resolveBoard(c *gin.Context, userID string) (*models.Board, bool) {
+	boardID := c.Param("board_id")
+
+	var board *models.Board
+	var err error
+	if boardID == "" {
+		board, err = h.gridService.DefaultBoard(userID)
+	} else {
+		board, err = h.gridService.GetBoard(userID, boardID)
+	}
+	if err != nil {
+		respondBoardError(c, err)
+		return nil, false
+	}
+	return board, true
+}
+
+// respondBoardError maps board service errors to HTTP responses
+func respondBoardError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBoardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Board not found"})
+	case errors.Is(err, services.ErrDefaultBoard), errors.Is(err, services.ErrBoardNameRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[BOARDS] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing board."})
+	}
+}
+
+// ListBoards lists the user's boards
+// @Summary List boards
+// @Description Get all boards of the current user, default board first
+// @Tags Boards
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.BoardListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards [get]
+func (h *GridHandlers) ListBoards(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	boards, err := h.gridService.ListBoards(userID)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.BoardListResponse{Boards: boards})
+}
+
+// CreateBoard creates a new board
+// @Summary Create board
+// @Description Create a new board with an empty home page and the standard system controls
+// @Tags Boards
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.BoardRequest true "Board name"
+// @Success 201 {object} models.BoardResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards [post]
+func (h *GridHandlers) CreateBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	var req models.BoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Board name is required."})
+		return
+	}
+
+	log.Printf("[BOARDS] Creating board %q for userId: %s", req.Name, userID)
+
+	board, err := h.gridService.CreateBoard(userID, req.Name)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	response, err := h.gridService.DescribeBoard(board)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetBoard returns a single board
+// @Summary Get board
+// @Description Get one of the current user's boards
+// @Tags Boards
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Success 200 {object} models.BoardResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards/{board_id} [get]
+func (h *GridHandlers) GetBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	response, err := h.gridService.DescribeBoard(board)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateBoard renames a board
+// @Summary Rename board
+// @Description Change the name of one of the current user's boards
+// @Tags Boards
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Param request body models.BoardRequest true "New board name"
+// @Success 200 {object} models.BoardResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards/{board_id} [put]
+func (h *GridHandlers) UpdateBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	var req models.BoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Board name is required."})
+		return
+	}
+
+	if err := h.gridService.RenameBoard(board, req.Name); err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	response, err := h.gridService.DescribeBoard(board)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteBoard deletes a board
+// @Summary Delete board
+// @Description Delete one of the current user's boards with all its items and versions. The default board cannot be deleted.
+// @Tags Boards
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards/{board_id} [delete]
+func (h *GridHandlers) DeleteBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := h.gridService.DeleteBoard(board); err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	log.Printf("[BOARDS] Board %s deleted for userId: %s", board.ID, userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Board deleted successfully"})
+}
+
+// GetActiveBoard returns the user's active board
+// @Summary Get active board
+// @Description Get the board the current user last switched to (the default board if none)
+// @Tags Boards
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.BoardResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards/active [get]
+func (h *GridHandlers) GetActiveBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, err := h.gridService.ActiveBoard(userID)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	response, err := h.gridService.DescribeBoard(board)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetActiveBoard switches the user's active board
+// @Summary Set active board
+// @Description Switch the current user's active board
+// @Tags Boards
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SetActiveBoardRequest true "Board to activate"
+// @Success 200 {object} models.BoardResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /boards/active [put]
+func (h *GridHandlers) SetActiveBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	var req models.SetActiveBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "board_id is required."})
+		return
+	}
+
+	board, err := h.gridService.SetActiveBoard(userID, req.BoardID)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	response, err := h.gridService.DescribeBoard(board)
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -14,23 +15,25 @@ import (
 
 // GridHandlers handles grid-related requests
 type GridHandlers struct {
-	gridService *services.GridService
-	userService *services.UserService
-	cfg         *config.Config
+	gridService     *services.GridService
+	userService     *services.UserService
+	cfg             *config.Config
+	languageService *services.LanguageService // Resolves the locale of exported boards
 }
 
 // NewGridHandlers creates a new GridHandlers instance
-func NewGridHandlers(cfg *config.Config) *GridHandlers {
+func NewGridHandlers(cfg *config.Config, gridEvents services.GridEventBroker, imageService *services.ImageService, languageService *services.LanguageService) *GridHandlers {
 	return &GridHandlers{
-		gridService: services.NewGridService(),
-		userService: services.NewUserService(),
-		cfg:         cfg,
+		gridService:     services.NewGridService(cfg, gridEvents, imageService),
+		userService:     services.NewUserService(),
+		cfg:             cfg,
+		languageService: languageService,
 	}
 }
 
 // Setup handles grid setup request
 // @Summary Setup grid
-// @Description Initialize a grid with the specified type (simplified, empty, or default)
+// @Description Initialize the default board from a grid template. gridType is a template ID or slug; the built-in slugs are simplified, default and empty. The template is copied with fresh item IDs.
 // @Tags Grid
 // @Accept json
 // @Produce json
@@ -60,28 +63,36 @@ func (h *GridHandlers) Setup(c *gin.Context) {
 
 	log.Printf("[SETUP] Grid type requested: %s for userId: %s", req.GridType, userID)
 
-	// Determine the grid structure
-	var selectedGrid map[string][]models.GridItemResponse
-	switch req.GridType {
-	case "simplified":
-		selectedGrid = services.GetSimplifiedGrid(h.cfg)
-		log.Printf("[SETUP] Selected simplified grid")
-	case "empty":
-		defaultGrid := services.GetDefaultGrid(h.cfg)
-		selectedGrid = map[string][]models.GridItemResponse{
-			"home":           {},
-			"systemControls": defaultGrid["systemControls"],
+	// Instantiate the template; the legacy grid types are the slugs of the built-in templates
+	selectedGrid, err := h.gridService.InstantiateTemplate(req.GridType)
+	if err != nil {
+		if errors.Is(err, services.ErrGridTemplateNotFound) {
+			log.Printf("[SETUP] Unknown grid template %s for userId: %s", req.GridType, userID)
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown grid template."})
+			return
 		}
-		log.Printf("[SETUP] Selected empty grid with system controls")
-	case "default":
-	default:
-		selectedGrid = services.GetDefaultGrid(h.cfg)
-		log.Printf("[SETUP] Selected default grid")
+		log.Printf("[SETUP] Error loading grid template: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error saving setup.",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Save grid to the default board
+	board, err := h.gridService.DefaultBoard(userID)
+	if err != nil {
+		log.Printf("[SETUP] Error loading default board: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Error saving setup.",
+			"error":   err.Error(),
+		})
+		return
 	}
 
-	// Save grid
 	log.Printf("[SETUP] Saving grid to database for userId: %s", userID)
-	if err := h.gridService.SaveGrid(selectedGrid, userID); err != nil {
+	revision, err := h.gridService.SaveGrid(selectedGrid, board, services.AnyRevision)
+	if err != nil {
 		log.Printf("[SETUP] Error saving grid: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error saving setup.",
@@ -102,6 +113,7 @@ func (h *GridHandlers) Setup(c *gin.Context) {
 	}
 
 	log.Printf("[SETUP] Setup completed successfully for userId: %s", userID)
+	setGridRevision(c, revision)
 	c.JSON(http.StatusOK, gin.H{"message": "Setup complete. Grid saved."})
 }
 
@@ -139,11 +151,12 @@ func (h *GridHandlers) CompleteSetup(c *gin.Context) {
 
 // GetGrid retrieves the entire grid for a user
 // @Summary Get grid
-// @Description Retrieve the complete grid configuration for the current user
+// @Description Retrieve the complete grid configuration for the current user. The grid revision is returned in the ETag and X-Grid-Revision headers.
 // @Tags Grid
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} models.GridResponse
+// @Header 200 {string} ETag "Grid revision"
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /grid [get]
@@ -155,9 +168,14 @@ func (h *GridHandlers) GetGrid(c *gin.Context) {
 		return
 	}
 
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
 	log.Printf("[GET-GRID] Retrieving grid for userId: %s", userID)
 
-	gridData, err := h.gridService.GetGrid(userID)
+	gridData, revision, err := h.gridService.GetGridWithRevision(board)
 	if err != nil {
 		log.Printf("[GET-GRID] Error reading from database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
@@ -168,23 +186,29 @@ func (h *GridHandlers) GetGrid(c *gin.Context) {
 		gridData = make(map[string][]models.GridItemResponse)
 	}
 
-	log.Printf("[GET-GRID] Grid retrieved successfully for userId: %s, has data: %t",
-		userID, len(gridData) > 0)
+	log.Printf("[GET-GRID] Grid retrieved successfully for userId: %s, has data: %t, revision: %d",
+		userID, len(gridData) > 0, revision)
 
+	setGridRevision(c, revision)
 	c.JSON(http.StatusOK, gridData)
 }
 
 // SaveGrid saves a full grid (updates from client)
 // @Summary Save grid
-// @Description Save the complete grid configuration
+// @Description Save the complete grid configuration. Requires the revision being edited in If-Match.
 // @Tags Grid
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
 // @Param grid body models.GridResponse true "Grid data to save"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /grid [post]
 func (h *GridHandlers) SaveGrid(c *gin.Context) {
@@ -195,8 +219,18 @@ func (h *GridHandlers) SaveGrid(c *gin.Context) {
 		return
 	}
 
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
 	log.Printf("[SAVE-GRID] Saving grid for userId: %s", userID)
 
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
+	}
+
 	var gridData map[string][]models.GridItemResponse
 	if err := c.ShouldBindJSON(&gridData); err != nil {
 		log.Printf("[SAVE-GRID] Invalid grid data: %v", err)
@@ -204,27 +238,46 @@ func (h *GridHandlers) SaveGrid(c *gin.Context) {
 		return
 	}
 
-	if err := h.gridService.SaveGrid(gridData, userID); err != nil {
+	revision, err := h.gridService.SaveGrid(gridData, board, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) {
+			log.Printf("[SAVE-GRID] Rejected stale save for userId: %s: %v", userID, err)
+			return
+		}
+		if respondGridValidationError(c, err) {
+			log.Printf("[SAVE-GRID] Rejected invalid grid for userId: %s: %v", userID, err)
+			return
+		}
+		if respondImageError(c, err) {
+			log.Printf("[SAVE-GRID] Rejected image for userId: %s: %v", userID, err)
+			return
+		}
 		log.Printf("[SAVE-GRID] Error writing to database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving to database."})
 		return
 	}
 
-	log.Printf("[SAVE-GRID] Grid saved successfully for userId: %s", userID)
+	log.Printf("[SAVE-GRID] Grid saved successfully for userId: %s, revision: %d", userID, revision)
+	setGridRevision(c, revision)
 	c.JSON(http.StatusOK, gin.H{"message": "Grid saved successfully!"})
 }
 
 // AddItem adds a new item to the grid
 // @Summary Add grid item
-// @Description Add a new item to a specific category in the grid
+// @Description Add a new item to a specific category in the grid. Requires the revision being edited in If-Match.
 // @Tags Grid
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
 // @Param request body models.AddItemRequest true "Add item request"
 // @Success 201 {object} models.GridItemResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /grid/item [post]
 func (h *GridHandlers) AddItem(c *gin.Context) {
@@ -235,8 +288,18 @@ func (h *GridHandlers) AddItem(c *gin.Context) {
 		return
 	}
 
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
 	log.Printf("[ADD-ITEM] Adding new item for userId: %s", userID)
 
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
+	}
+
 	var req models.AddItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Printf("[ADD-ITEM] Invalid request payload: %v", err)
@@ -249,8 +312,20 @@ func (h *GridHandlers) AddItem(c *gin.Context) {
 	log.Printf("[ADD-ITEM] Item data: ID=%s, ParentCategory=%s",
 		req.Item.ID, req.ParentCategory)
 
-	newItem, err := h.gridService.AddItem(req.Item, req.ParentCategory, userID)
+	newItem, revision, err := h.gridService.AddItem(req.Item, req.ParentCategory, board, expectedRevision)
 	if err != nil {
+		if respondRevisionError(c, err) {
+			log.Printf("[ADD-ITEM] Rejected stale add for userId: %s: %v", userID, err)
+			return
+		}
+		if respondGridValidationError(c, err) {
+			log.Printf("[ADD-ITEM] Rejected invalid grid for userId: %s: %v", userID, err)
+			return
+		}
+		if respondImageError(c, err) {
+			log.Printf("[ADD-ITEM] Rejected image for userId: %s: %v", userID, err)
+			return
+		}
 		log.Printf("[ADD-ITEM] Error adding item to database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error adding item.",
@@ -260,22 +335,28 @@ func (h *GridHandlers) AddItem(c *gin.Context) {
 	}
 
 	log.Printf("[ADD-ITEM] Item added successfully for userId: %s: %s", userID, newItem.ID)
+	setGridRevision(c, revision)
 	c.JSON(http.StatusCreated, newItem)
 }
 
 // UpdateItem updates an existing item
 // @Summary Update grid item
-// @Description Update an existing item in the grid
+// @Description Update an existing item in the grid. Requires the revision being edited in If-Match.
 // @Tags Grid
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
 // @Param id path string true "Item ID"
 // @Param item body models.GridItemResponse true "Updated item data"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /grid/item/{id} [put]
 func (h *GridHandlers) UpdateItem(c *gin.Context) {
@@ -286,8 +367,18 @@ func (h *GridHandlers) UpdateItem(c *gin.Context) {
 		return
 	}
 
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
 	itemID := c.Param("id")
 
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
+	}
+
 	var updateData models.GridItemResponse
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		log.Printf("[UPDATE-ITEM] Invalid update data: %v", err)
@@ -297,7 +388,20 @@ func (h *GridHandlers) UpdateItem(c *gin.Context) {
 
 	log.Printf("[UPDATE-ITEM] Update data for item %s", itemID)
 
-	if err := h.gridService.UpdateItem(itemID, updateData, userID); err != nil {
+	revision, err := h.gridService.UpdateItem(itemID, updateData, board, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) {
+			log.Printf("[UPDATE-ITEM] Rejected stale update for userId: %s: %v", userID, err)
+			return
+		}
+		if respondGridValidationError(c, err) {
+			log.Printf("[UPDATE-ITEM] Rejected invalid grid for userId: %s: %v", userID, err)
+			return
+		}
+		if respondImageError(c, err) {
+			log.Printf("[UPDATE-ITEM] Rejected image for userId: %s: %v", userID, err)
+			return
+		}
 		log.Printf("[UPDATE-ITEM] Error updating item in database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error updating item.",
@@ -307,19 +411,24 @@ func (h *GridHandlers) UpdateItem(c *gin.Context) {
 	}
 
 	log.Printf("[UPDATE-ITEM] Item updated successfully: %s for userId: %s", itemID, userID)
+	setGridRevision(c, revision)
 	c.JSON(http.StatusOK, gin.H{"message": "Item updated successfully!"})
 }
 
-// DeleteItem deletes an item
+// DeleteItem moves an item to the trash
 // @Summary Delete grid item
-// @Description Delete an existing item from the grid
+// @Description Move an item to the board's trash. Deleting a category also removes the page it opens and, recursively, the pages of the categories on it. Trashed items can be restored until the trash retention expires. Requires the revision being edited in If-Match.
 // @Tags Grid
 // @Produce json
 // @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
 // @Param id path string true "Item ID"
 // @Success 200 {object} models.SuccessResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /grid/item/{id} [delete]
 func (h *GridHandlers) DeleteItem(c *gin.Context) {
@@ -330,23 +439,32 @@ func (h *GridHandlers) DeleteItem(c *gin.Context) {
 		return
 	}
 
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
 	itemID := c.Param("id")
 
 	log.Printf("[DELETE-ITEM] Deleting item %s for userId: %s", itemID, userID)
 
-	var req struct {
-		CategoryTarget string `json:"categoryTarget"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("[DELETE-ITEM] Warning: failed to parse optional categoryTarget: %v", err)
-	}
-
-	if req.CategoryTarget != "" {
-		log.Printf("[DELETE-ITEM] Category target specified: %s", req.CategoryTarget)
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
 	}
 
-	// Delete the item
-	if err := h.gridService.DeleteItem(itemID, userID); err != nil {
+	// Category contents are always deleted with the category; the categoryTarget
+	// body older clients send is no longer needed
+	revision, err := h.gridService.DeleteItem(itemID, board, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) {
+			log.Printf("[DELETE-ITEM] Rejected stale delete for userId: %s: %v", userID, err)
+			return
+		}
+		if errors.Is(err, services.ErrGridItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
 		log.Printf("[DELETE-ITEM] Error deleting item from database: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Error deleting item.",
@@ -357,16 +475,6 @@ func (h *GridHandlers) DeleteItem(c *gin.Context) {
 
 	log.Printf("[DELETE-ITEM] Item deleted: %s for userId: %s", itemID, userID)
 
-	// Delete category contents if specified
-	if req.CategoryTarget != "" {
-		log.Printf("[DELETE-ITEM] Deleting category contents for: %s", req.CategoryTarget)
-		if err := h.gridService.DeleteCategoryContents(req.CategoryTarget, userID); err != nil {
-			log.Printf("[DELETE-ITEM] Error deleting category contents: %v", err)
-			// Don't return error here as the main item was already deleted
-		} else {
-			log.Printf("[DELETE-ITEM] Category contents deleted for: %s", req.CategoryTarget)
-		}
-	}
-
+	setGridRevision(c, revision)
 	c.JSON(http.StatusOK, gin.H{"message": "Item deleted successfully!"})
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GridEvents streams grid change events to the client
+// @Summary Subscribe to grid changes
+// @Description Server-Sent Events stream of changes to the board's grid. A "ready" event carries the current revision on connect; each change is sent as a "grid" event with its action, summary and new revision. Clients that see a revision newer than the one they hold should reload the grid. EventSource clients can pass the access token in the token query parameter.
+// @Tags Grid
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Success 200 {object} models.GridEvent
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/events [get]
+func (h *GridHandlers) GridEvents(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	// Subscribe before reading the revision so no change falls in between
+	events, unsubscribe := h.gridService.SubscribeEvents(userID)
+	defer unsubscribe()
+
+	revision, err := h.gridService.Revision(board)
+	if err != nil {
+		log.Printf("[GRID-EVENTS] Error reading revision: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
+		return
+	}
+
+	log.Printf("[GRID-EVENTS] Client subscribed for userId: %s to board %s at revision %d", userID, board.ID, revision)
+	defer log.Printf("[GRID-EVENTS] Client unsubscribed for userId: %s", userID)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("ready", gin.H{"revision": revision})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.Grid.EventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if event.BoardID == board.ID {
+				c.SSEvent("grid", event)
+			}
+			return true
+		case <-heartbeat.C:
+			// SSE comment line keeps proxies from closing an idle connection
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		}
+	})
+}
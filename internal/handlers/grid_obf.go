@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxOpenBoardUploadSize limits the size of uploaded .obf/.obz files
+const maxOpenBoardUploadSize = 50 << 20
+
+// ExportOBZ exports the grid as an Open Board Zip package
+// @Summary Export grid as OBZ
+// @Description Export the user's grid as an Open Board Format package (.obz), one board per category. The boards are tagged with the user's language unless the request names one.
+// @Tags Grid
+// @Produce application/zip
+// @Security BearerAuth
+// @Param language query string false "Locale of the boards (it, es, en); defaults to the user's language"
+// @Success 200 {file} binary "OBZ package"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/export/obz [get]
+func (h *GridHandlers) ExportOBZ(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	language, err := h.languageService.ResolveLanguage(userID, c.Query("language"))
+	if errors.Is(err, services.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[EXPORT-OBZ] Error resolving language: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting grid."})
+		return
+	}
+
+	log.Printf("[EXPORT-OBZ] Exporting grid for userId: %s, language: %s", userID, language)
+
+	content, err := h.gridService.ExportOBZ(board, language)
+	if err != nil {
+		log.Printf("[EXPORT-OBZ] Error exporting grid: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting grid."})
+		return
+	}
+
+	log.Printf("[EXPORT-OBZ] Grid exported for userId: %s (%d bytes)", userID, len(content))
+	c.Header("Content-Disposition", `attachment; filename="grid.obz"`)
+	c.Data(http.StatusOK, "application/zip", content)
+}
+
+// ImportOpenBoard imports an .obf or .obz file into the grid
+// @Summary Import OBF/OBZ
+// @Description Replace the user's grid with the boards from an Open Board Format file (.obf) or package (.obz)
+// @Tags Grid
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string false "Grid revision (ETag) being replaced"
+// @Param file formData file true "OBF or OBZ file"
+// @Success 200 {object} models.OBFImportResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/import [post]
+func (h *GridHandlers) ImportOpenBoard(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, false)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOpenBoardUploadSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.Printf("[IMPORT-OBF] Missing upload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "An .obf or .obz file is required."})
+		return
+	}
+
+	log.Printf("[IMPORT-OBF] Importing %s (%d bytes) for userId: %s", fileHeader.Filename, fileHeader.Size, userID)
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Printf("[IMPORT-OBF] Error opening upload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Could not read uploaded file."})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("[IMPORT-OBF] Error reading upload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Could not read uploaded file."})
+		return
+	}
+
+	result, err := h.gridService.ImportOpenBoard(data, fileHeader.Filename, board, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) || respondGridValidationError(c, err) {
+			return
+		}
+		log.Printf("[IMPORT-OBF] Error importing file: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Error importing Open Board file.",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	log.Printf("[IMPORT-OBF] Imported %d boards, %d items for userId: %s", result.Boards, result.Items, userID)
+	setGridRevision(c, result.Revision)
+	c.JSON(http.StatusOK, result)
+}
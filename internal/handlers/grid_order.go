@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MoveItem moves an item to a position on the same or another page
+// @Summary Move grid item
+// @Description Move an item to a zero-based position on its page or on another page (parentCategory). Sibling items are renumbered in the same transaction. An omitted or out of range position appends the item. Requires the revision being edited in If-Match.
+// @Tags Grid
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
+// @Param id path string true "Item ID"
+// @Param request body models.MoveItemRequest true "Destination page and position"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/item/{id}/move [post]
+func (h *GridHandlers) MoveItem(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	itemID := c.Param("id")
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	var req models.MoveItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[MOVE-ITEM] Invalid move data: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid move data."})
+		return
+	}
+
+	log.Printf("[MOVE-ITEM] Moving item %s to %q for userId: %s", itemID, req.ParentCategory, userID)
+
+	revision, err := h.gridService.MoveItem(itemID, req.ParentCategory, req.Position, board, expectedRevision)
+	if err != nil {
+		respondOrderError(c, "[MOVE-ITEM]", userID, err)
+		return
+	}
+
+	setGridRevision(c, revision)
+	c.JSON(http.StatusOK, gin.H{"message": "Item moved successfully!"})
+}
+
+// ReorderItems applies new item orders to one or more pages
+// @Summary Reorder grid items
+// @Description Batch reorder for drag and drop. Each page lists the IDs of all its items in their new order; an item listed under a page other than its own is moved there. Requires the revision being edited in If-Match.
+// @Tags Grid
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "Grid revision (ETag) being edited"
+// @Param request body models.ReorderItemsRequest true "New item order per page"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 428 {object} models.GridRevisionErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/order [put]
+func (h *GridHandlers) ReorderItems(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, h.cfg.Grid.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	var req models.ReorderItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[REORDER-ITEMS] Invalid order data: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid order data."})
+		return
+	}
+
+	log.Printf("[REORDER-ITEMS] Reordering %d pages for userId: %s", len(req.Categories), userID)
+
+	revision, err := h.gridService.ReorderItems(req.Categories, board, expectedRevision)
+	if err != nil {
+		respondOrderError(c, "[REORDER-ITEMS]", userID, err)
+		return
+	}
+
+	setGridRevision(c, revision)
+	c.JSON(http.StatusOK, gin.H{"message": "Items reordered successfully!"})
+}
+
+// respondOrderError maps move and reorder errors to HTTP responses
+func respondOrderError(c *gin.Context, logPrefix, userID string, err error) {
+	switch {
+	case respondRevisionError(c, err):
+		log.Printf("%s Rejected stale write for userId: %s: %v", logPrefix, userID, err)
+	case respondGridValidationError(c, err):
+		log.Printf("%s Rejected invalid grid for userId: %s: %v", logPrefix, userID, err)
+	case errors.Is(err, services.ErrGridItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	case errors.Is(err, services.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s Error writing to database: %v", logPrefix, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving to database."})
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setGridRevision exposes the grid revision as an ETag and an X-Grid-Revision header
+func setGridRevision(c *gin.Context, revision int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(revision)))
+	c.Header("X-Grid-Revision", strconv.Itoa(revision))
+}
+
+// ifMatchRevision reads the revision a write is based on from the If-Match header.
+// A missing header is rejected with 428 when required, otherwise the write is
+// unconditional. It writes the error response itself and returns false on failure.
+func (h *GridHandlers) ifMatchRevision(c *gin.Context, board *models.Board, required bool) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if !required {
+			return services.AnyRevision, true
+		}
+
+		log.Printf("[GRID-REVISION] Missing If-Match header for board: %s", board.ID)
+		current, err := h.gridService.Revision(board)
+		if err != nil {
+			log.Printf("[GRID-REVISION] Error reading revision: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
+			return 0, false
+		}
+		setGridRevision(c, current)
+		c.JSON(http.StatusPreconditionRequired, models.GridRevisionErrorResponse{
+			Error:    "If-Match header required",
+			Message:  "Send the grid revision (ETag) you are editing in the If-Match header.",
+			Revision: current,
+		})
+		return 0, false
+	}
+
+	if header == "*" {
+		return services.AnyRevision, true
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 0 {
+		log.Printf("[GRID-REVISION] Invalid If-Match header %q for board: %s", header, board.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return 0, false
+	}
+	return revision, true
+}
+
+// respondRevisionError answers a failed revision check with 412 (stale revision) or
+// 409 (concurrent write) and the current revision. It reports whether err was handled.
+func respondRevisionError(c *gin.Context, err error) bool {
+	var revisionErr *services.GridRevisionError
+	if !errors.As(err, &revisionErr) {
+		return false
+	}
+
+	status := http.StatusPreconditionFailed
+	message := "The grid was changed in another session. Reload it and apply your changes again."
+	if errors.Is(err, services.ErrRevisionConflict) {
+		status = http.StatusConflict
+		message = "The grid was changed in another session at the same time. Reload it and apply your changes again."
+	}
+
+	setGridRevision(c, revisionErr.Current)
+	c.JSON(status, models.GridRevisionErrorResponse{
+		Error:    revisionErr.Err.Error(),
+		Message:  message,
+		Revision: revisionErr.Current,
+	})
+	return true
+}
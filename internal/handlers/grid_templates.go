@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTemplates lists the grid templates offered during setup
+// @Summary List grid templates
+// @Description Get the starter grids users can choose from during setup, without their items. Send a template's id or slug as gridType to /setup.
+// @Tags Grid
+// @Produce json
+// @Success 200 {object} models.GridTemplateListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/templates [get]
+func (h *GridHandlers) ListTemplates(c *gin.Context) {
+	templates, err := h.gridService.ListTemplates()
+	if err != nil {
+		log.Printf("[GRID-TEMPLATES] Error listing templates: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching grid templates."})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GridTemplateListResponse{Templates: templates})
+}
+
+// AdminGetTemplate returns a grid template with its grid
+// @Summary Get grid template
+// @Description Get a grid template including all its items (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Template ID"
+// @Success 200 {object} models.GridTemplateResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grid-templates/{id} [get]
+func (h *GridHandlers) AdminGetTemplate(c *gin.Context) {
+	template, err := h.gridService.GetTemplate(c.Param("id"))
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// AdminCreateTemplate creates a grid template
+// @Summary Create grid template
+// @Description Publish a new starter grid. The grid is validated like a saved grid; uploaded images are stored. An optional slug can be used as gridType on setup (admin only).
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.GridTemplateRequest true "Template"
+// @Success 201 {object} models.GridTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grid-templates [post]
+func (h *GridHandlers) AdminCreateTemplate(c *gin.Context) {
+	var req models.GridTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data"})
+		return
+	}
+
+	template, err := h.gridService.CreateTemplate(req, auth.GetUserID(c))
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// AdminUpdateTemplate updates a grid template
+// @Summary Update grid template
+// @Description Change a template's name, description and icon, and its slug or grid when given. Grids already set up from the template are not changed (admin only).
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Template ID"
+// @Param request body models.GridTemplateRequest true "Template"
+// @Success 200 {object} models.GridTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grid-templates/{id} [put]
+func (h *GridHandlers) AdminUpdateTemplate(c *gin.Context) {
+	var req models.GridTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data"})
+		return
+	}
+
+	template, err := h.gridService.UpdateTemplate(c.Param("id"), req, auth.GetUserID(c))
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// AdminDeleteTemplate deletes a grid template
+// @Summary Delete grid template
+// @Description Delete a grid template. Grids already set up from it are not changed (admin only).
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Template ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grid-templates/{id} [delete]
+func (h *GridHandlers) AdminDeleteTemplate(c *gin.Context) {
+	if err := h.gridService.DeleteTemplate(c.Param("id")); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Grid template deleted successfully"})
+}
+
+// AdminSaveBoardAsTemplate publishes a copy of a user's board as a grid template
+// @Summary Save board as template
+// @Description Store a copy of any board's current grid as a new grid template (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Param request body models.GridTemplateFromBoardRequest true "Template details"
+// @Success 201 {object} models.GridTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grids/{board_id}/template [post]
+func (h *GridHandlers) AdminSaveBoardAsTemplate(c *gin.Context) {
+	board, err := h.gridService.GetBoardByID(c.Param("board_id"))
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	var req models.GridTemplateFromBoardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data"})
+		return
+	}
+
+	template, err := h.gridService.SaveBoardAsTemplate(board, req, auth.GetUserID(c))
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	log.Printf("[GRID-TEMPLATES] Saved board %s (userId: %s) as template %s", board.ID, board.UserID, template.ID)
+	c.JSON(http.StatusCreated, template)
+}
+
+// respondTemplateError maps grid template errors to HTTP responses
+func respondTemplateError(c *gin.Context, err error) {
+	switch {
+	case respondGridValidationError(c, err), respondImageError(c, err):
+	case errors.Is(err, services.ErrGridTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grid template not found"})
+	case errors.Is(err, services.ErrGridTemplateSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "A grid template with this slug already exists"})
+	case errors.Is(err, services.ErrInvalidGridTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[GRID-TEMPLATES] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing grid template."})
+	}
+}
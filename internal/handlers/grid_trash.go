@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash lists the deleted items that can still be restored
+// @Summary List grid trash
+// @Description Get the board's trash, newest first. Each entry is one delete and counts the nested items removed with it. Entries expire after the trash retention.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.GridTrashListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/trash [get]
+func (h *GridHandlers) ListTrash(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	entries, err := h.gridService.ListTrash(board)
+	if err != nil {
+		log.Printf("[GRID-TRASH] Error listing trash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching grid trash."})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GridTrashListResponse{Entries: entries})
+}
+
+// RestoreTrash puts the items of a trash entry back on the grid
+// @Summary Restore deleted items
+// @Description Restore a trash entry. The deleted item returns to its former position on its former page, or is appended to home when that page no longer exists, together with the nested items deleted with it. The restore is recorded as a new version.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string false "Grid revision (ETag) being edited"
+// @Param id path string true "Trash entry ID"
+// @Success 200 {object} models.GridTrashRestoreResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/trash/{id}/restore [post]
+func (h *GridHandlers) RestoreTrash(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, false)
+	if !ok {
+		return
+	}
+
+	entryID := c.Param("id")
+	log.Printf("[GRID-TRASH] Restoring trash entry %s for userId: %s", entryID, userID)
+
+	parent, restored, revision, err := h.gridService.RestoreTrash(board, entryID, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) || respondGridValidationError(c, err) {
+			return
+		}
+		respondTrashError(c, err)
+		return
+	}
+
+	setGridRevision(c, revision)
+	c.JSON(http.StatusOK, models.GridTrashRestoreResponse{
+		Message:        "Items restored successfully",
+		ParentCategory: parent,
+		ItemsRestored:  restored,
+		Revision:       revision,
+	})
+}
+
+// DeleteTrash permanently removes a trash entry
+// @Summary Delete trash entry
+// @Description Permanently delete a trash entry. Its items can no longer be restored, except from an older grid version.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Trash entry ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/trash/{id} [delete]
+func (h *GridHandlers) DeleteTrash(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := h.gridService.DeleteTrash(board, c.Param("id")); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trash entry deleted successfully"})
+}
+
+// respondTrashError maps trash service errors to HTTP responses
+func respondTrashError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTrashEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash entry not found"})
+	case errors.Is(err, services.ErrTrashConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "The deleted items are already on the grid again"})
+	default:
+		log.Printf("[GRID-TRASH] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing grid trash."})
+	}
+}
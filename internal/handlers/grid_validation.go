@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondGridValidationError answers a write rejected by grid validation with 422
+// and the problems found. It reports whether err was handled.
+func respondGridValidationError(c *gin.Context, err error) bool {
+	var validationErr *services.GridValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, models.GridValidationErrorResponse{
+		Error:    "Invalid grid",
+		Problems: validationErr.Problems,
+	})
+	return true
+}
+
+// ValidateGrid reports structural problems of the grid
+// @Summary Validate grid
+// @Description Check the grid for structural problems. Errors (unknown item types or actions, duplicate IDs, category loops) are rejected on save; warnings (pages no category opens, categories opening empty pages) can be cleaned up with the repair endpoint.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.GridValidationReport
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/validate [get]
+func (h *GridHandlers) ValidateGrid(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	report, err := h.gridService.ValidateGrid(board)
+	if err != nil {
+		log.Printf("[VALIDATE-GRID] Error validating grid: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
+		return
+	}
+
+	setGridRevision(c, report.Revision)
+	c.JSON(http.StatusOK, report)
+}
+
+// RepairGrid cleans up orphaned pages and, optionally, dangling category links
+// @Summary Repair grid
+// @Description Remove pages that no category opens and, with remove_dangling_links, categories whose page has no items. The repair is recorded as a grid version. Errors such as loops are reported but left for the user to fix.
+// @Tags Grid
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string false "Grid revision (ETag) being edited"
+// @Param request body models.GridRepairRequest false "Repair options"
+// @Success 200 {object} models.GridRepairResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/repair [post]
+func (h *GridHandlers) RepairGrid(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	var req models.GridRepairRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid repair options"})
+			return
+		}
+	}
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("[REPAIR-GRID] Repairing grid of board %s for userId: %s", board.ID, userID)
+	h.repairGrid(c, board, req, expectedRevision)
+}
+
+// AdminGridValidation lists the boards of all users that have grid problems
+// @Summary Grid validation overview
+// @Description Validate the grids of all boards and list those with errors or warnings (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.GridValidationOverviewResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grids/validation [get]
+func (h *GridHandlers) AdminGridValidation(c *gin.Context) {
+	overview, err := h.gridService.ValidationOverview()
+	if err != nil {
+		log.Printf("[ADMIN-GRIDS] Error validating grids: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// AdminValidateBoard reports structural problems of any user's board
+// @Summary Validate a user's grid
+// @Description Check the grid of any board for structural problems (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Success 200 {object} models.GridValidationReport
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grids/{board_id}/validate [get]
+func (h *GridHandlers) AdminValidateBoard(c *gin.Context) {
+	board, err := h.gridService.GetBoardByID(c.Param("board_id"))
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	report, err := h.gridService.ValidateGrid(board)
+	if err != nil {
+		log.Printf("[ADMIN-GRIDS] Error validating grid: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading from database."})
+		return
+	}
+
+	setGridRevision(c, report.Revision)
+	c.JSON(http.StatusOK, report)
+}
+
+// AdminRepairBoard repairs any user's board
+// @Summary Repair a user's grid
+// @Description Remove orphaned pages and, with remove_dangling_links, categories whose page has no items from any board (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param board_id path string true "Board ID"
+// @Param request body models.GridRepairRequest false "Repair options"
+// @Success 200 {object} models.GridRepairResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/grids/{board_id}/repair [post]
+func (h *GridHandlers) AdminRepairBoard(c *gin.Context) {
+	board, err := h.gridService.GetBoardByID(c.Param("board_id"))
+	if err != nil {
+		respondBoardError(c, err)
+		return
+	}
+
+	var req models.GridRepairRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid repair options"})
+			return
+		}
+	}
+
+	log.Printf("[ADMIN-GRIDS] Repairing grid of board %s (userId: %s)", board.ID, board.UserID)
+	h.repairGrid(c, board, req, services.AnyRevision)
+}
+
+// repairGrid runs a repair and writes the response
+func (h *GridHandlers) repairGrid(c *gin.Context, board *models.Board, req models.GridRepairRequest, expectedRevision int) {
+	result, err := h.gridService.RepairGrid(board, req, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) {
+			return
+		}
+		log.Printf("[REPAIR-GRID] Error repairing grid: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error repairing grid."})
+		return
+	}
+
+	setGridRevision(c, result.Revision)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListVersions lists the user's grid versions
+// @Summary List grid versions
+// @Description Get the version history of the user's grid, newest first
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.GridVersionListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/versions [get]
+func (h *GridHandlers) ListVersions(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	versions, err := h.gridService.ListVersions(board)
+	if err != nil {
+		log.Printf("[GRID-VERSIONS] Error listing versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching grid versions."})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GridVersionListResponse{Versions: versions})
+}
+
+// GetVersion returns a single grid version
+// @Summary Get grid version
+// @Description Get a grid version including the full grid snapshot
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Param version path int true "Version number"
+// @Success 200 {object} models.GridVersionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/versions/{version} [get]
+func (h *GridHandlers) GetVersion(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	version, ok := parseVersionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	result, err := h.gridService.GetVersion(board, version)
+	if err != nil {
+		respondVersionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DiffVersions compares two grid versions
+// @Summary Diff grid versions
+// @Description List the items added, removed and modified between two grid versions. Modified items list their changed fields, including parent_category and position (the index on the page) when they moved.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Param from query int true "Base version"
+// @Param to query int true "Target version"
+// @Success 200 {object} models.GridDiffResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/versions/diff [get]
+func (h *GridHandlers) DiffVersions(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	from, ok := parseVersionParam(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := parseVersionParam(c, c.Query("to"))
+	if !ok {
+		return
+	}
+
+	diff, err := h.gridService.DiffVersions(board, from, to)
+	if err != nil {
+		respondVersionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreVersion restores the grid to a previous version
+// @Summary Restore grid version
+// @Description Replace the user's grid with a previous version. The restore is recorded as a new version.
+// @Tags Grid
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string false "Grid revision (ETag) being edited"
+// @Param version path int true "Version number"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.GridRevisionErrorResponse
+// @Failure 412 {object} models.GridRevisionErrorResponse
+// @Failure 422 {object} models.GridValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /grid/versions/{version}/restore [post]
+func (h *GridHandlers) RestoreVersion(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	board, ok := h.resolveBoard(c, userID)
+	if !ok {
+		return
+	}
+
+	version, ok := parseVersionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	expectedRevision, ok := h.ifMatchRevision(c, board, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("[RESTORE-GRID] Restoring version %d for userId: %s", version, userID)
+
+	revision, err := h.gridService.RestoreVersion(board, version, expectedRevision)
+	if err != nil {
+		if respondRevisionError(c, err) || respondGridValidationError(c, err) {
+			return
+		}
+		respondVersionError(c, err)
+		return
+	}
+
+	setGridRevision(c, revision)
+	c.JSON(http.StatusOK, gin.H{"message": "Grid restored successfully"})
+}
+
+// parseVersionParam parses a positive version number, writing a 400 response on failure
+func parseVersionParam(c *gin.Context, value string) (int, bool) {
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version number"})
+		return 0, false
+	}
+	return version, true
+}
+
+// respondVersionError maps version service errors to HTTP responses
+func respondVersionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrGridVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grid version not found"})
+		return
+	}
+	log.Printf("[GRID-VERSIONS] Error: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing grid version."})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImageHandlers serves uploaded symbol images
+type ImageHandlers struct {
+	imageService *services.ImageService
+}
+
+// NewImageHandlers creates a new ImageHandlers instance
+func NewImageHandlers(imageService *services.ImageService) *ImageHandlers {
+	return &ImageHandlers{imageService: imageService}
+}
+
+// GetImage serves a stored symbol image
+// @Summary Get uploaded image
+// @Description Serve a symbol image uploaded with a grid item. Image URLs are content-addressed, so responses can be cached indefinitely (public endpoint).
+// @Tags Images
+// @Produce image/jpeg
+// @Produce image/png
+// @Param key path string true "Image key"
+// @Success 200 {file} binary "Image"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /images/{key} [get]
+func (h *ImageHandlers) GetImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	content, contentType, err := h.imageService.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, services.ErrImageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		log.Printf("[IMAGES] Error reading image %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading image."})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// respondImageError answers a rejected image upload with 400. It reports whether err was handled.
+func respondImageError(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrInvalidImage) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "The image could not be used. Upload a PNG, JPEG, GIF or WebP image.",
+		"error":   err.Error(),
+	})
+	return true
+}
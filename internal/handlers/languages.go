@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LanguageHandler handles the languages of the AI language services and the
+// users' preferred language
+type LanguageHandler struct {
+	llmService      *services.LLMService
+	languageService *services.LanguageService
+}
+
+// NewLanguageHandler creates a new language handler
+func NewLanguageHandler(llmService *services.LLMService, languageService *services.LanguageService) *LanguageHandler {
+	return &LanguageHandler{
+		llmService:      llmService,
+		languageService: languageService,
+	}
+}
+
+// ListLanguages lists the supported languages with the user's preference
+// @Summary List supported languages
+// @Description Get the languages of the AI endpoints and ARASAAC searches, each with its tenses, the default language and the user's preferred one
+// @Tags AI
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.LanguagesResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /languages [get]
+func (h *LanguageHandler) ListLanguages(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	preferred, err := h.languageService.PreferredLanguage(userID)
+	if err != nil {
+		log.Printf("[LANGUAGES] Error loading preferred language: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	language, _ := h.languageService.ResolveLanguage(userID, preferred)
+
+	c.JSON(http.StatusOK, models.LanguagesResponse{
+		Languages: h.llmService.SupportedLanguages(),
+		Default:   h.languageService.DefaultLanguage(),
+		Preferred: preferred,
+		Language:  language,
+	})
+}
+
+// SetPreferredLanguage sets the language of the user's requests
+// @Summary Set preferred language
+// @Description Set the language used by the user's AI requests and ARASAAC searches when they do not name one. An empty language goes back to the default.
+// @Tags AI
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SetLanguageRequest true "Preferred language"
+// @Success 200 {object} models.LanguagesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /languages/preferred [put]
+func (h *LanguageHandler) SetPreferredLanguage(c *gin.Context) {
+	userID := auth.GetUserID(c)
+	if userID == "" {
+		log.Printf("[ERROR] Error extracting user ID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication"})
+		return
+	}
+
+	var req models.SetLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language data"})
+		return
+	}
+
+	preferred, err := h.languageService.SetPreferredLanguage(userID, req.Language)
+	if errors.Is(err, services.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[LANGUAGES] Error saving preferred language: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[LANGUAGES] Preferred language of userId %s set to %q", userID, preferred)
+
+	language, _ := h.languageService.ResolveLanguage(userID, preferred)
+	c.JSON(http.StatusOK, models.LanguagesResponse{
+		Languages: h.llmService.SupportedLanguages(),
+		Default:   h.languageService.DefaultLanguage(),
+		Preferred: preferred,
+		Language:  language,
+	})
+}
+
+// languageQuery reads the language query parameter of admin endpoints, the
+// default language when it is missing. It answers the request itself and
+// returns false when the language is not supported.
+func languageQuery(c *gin.Context, llmService *services.LLMService) (string, bool) {
+	language, err := llmService.NormalizeLanguage(c.Query("language"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return language, true
+}
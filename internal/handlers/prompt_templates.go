@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptTemplateHandler handles the admin management of LLM prompt templates
+type PromptTemplateHandler struct {
+	llmService *services.LLMService
+}
+
+// NewPromptTemplateHandler creates a new prompt template handler
+func NewPromptTemplateHandler(llmService *services.LLMService) *PromptTemplateHandler {
+	return &PromptTemplateHandler{
+		llmService: llmService,
+	}
+}
+
+// ListPromptTemplates lists the prompt templates in use
+// @Summary List prompt templates
+// @Description List the prompt templates of the AI endpoints with the version in use of each: correct_sentence, repair_output and one per tense of each language (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param language query string false "Only the templates of this language (it, es, en)"
+// @Success 200 {object} models.PromptTemplateListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts [get]
+func (h *PromptTemplateHandler) ListPromptTemplates(c *gin.Context) {
+	// Without a language the templates of every language are listed
+	language := ""
+	if c.Query("language") != "" {
+		var ok bool
+		if language, ok = languageQuery(c, h.llmService); !ok {
+			return
+		}
+	}
+
+	templates, err := h.llmService.ListPromptTemplates(language)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PromptTemplateListResponse{Templates: templates})
+}
+
+// GetPromptTemplate returns the version in use of a prompt template
+// @Summary Get prompt template
+// @Description Get the version in use of a prompt template, with its source (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Success 200 {object} models.PromptTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/{name} [get]
+func (h *PromptTemplateHandler) GetPromptTemplate(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	template, err := h.llmService.GetPromptTemplate(language, c.Param("name"))
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdatePromptTemplate saves a new version of a prompt template and puts it in use
+// @Summary Update prompt template
+// @Description Validate a template source like /validate, save it as the template's new version and use it right away, without restart. Cached AI results are purged (admin only).
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Param request body models.UpdatePromptTemplateRequest true "Template source"
+// @Success 200 {object} models.PromptTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/{name} [put]
+func (h *PromptTemplateHandler) UpdatePromptTemplate(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	var req models.UpdatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data"})
+		return
+	}
+
+	userID := auth.GetUserID(c)
+	log.Printf("[PROMPTS] Updating template %s/%s by userId: %s", language, c.Param("name"), userID)
+
+	template, err := h.llmService.UpdatePromptTemplate(language, c.Param("name"), req.Source, req.Comment, userID)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// ValidatePromptTemplate checks a prompt template source without saving it
+// @Summary Validate prompt template
+// @Description Parse a template source and render it with sample data (or the sentence, verbs and problems given), returning the rendered prompt and warnings about request data it leaves out (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Param request body models.ValidatePromptTemplateRequest true "Template source and sample data"
+// @Success 200 {object} models.PromptTemplateValidationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /admin/prompts/{name}/validate [post]
+func (h *PromptTemplateHandler) ValidatePromptTemplate(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	var req models.ValidatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template data"})
+		return
+	}
+
+	validation, err := h.llmService.ValidatePromptTemplate(c.Request.Context(), language, c.Param("name"), req)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, validation)
+}
+
+// ListPromptTemplateVersions lists the versions of a prompt template
+// @Summary List prompt template versions
+// @Description Get the version history of a prompt template, newest first (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Success 200 {object} models.PromptTemplateVersionListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/{name}/versions [get]
+func (h *PromptTemplateHandler) ListPromptTemplateVersions(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	versions, err := h.llmService.ListPromptTemplateVersions(language, name)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PromptTemplateVersionListResponse{Language: language, Name: name, Versions: versions})
+}
+
+// GetPromptTemplateVersion returns a single version of a prompt template
+// @Summary Get prompt template version
+// @Description Get a version of a prompt template, with its source (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Param version path int true "Version number"
+// @Success 200 {object} models.PromptTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/{name}/versions/{version} [get]
+func (h *PromptTemplateHandler) GetPromptTemplateVersion(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	version, ok := parseVersionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	template, err := h.llmService.GetPromptTemplateVersion(language, c.Param("name"), version)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// RestorePromptTemplateVersion puts a previous version of a prompt template back in use
+// @Summary Restore prompt template version
+// @Description Roll a prompt template back to a previous version. The restore is recorded as a new version, so it can be undone (admin only).
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Template name"
+// @Param language query string false "Template language (it, es, en); defaults to the default language"
+// @Param version path int true "Version number"
+// @Success 200 {object} models.PromptTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/{name}/versions/{version}/restore [post]
+func (h *PromptTemplateHandler) RestorePromptTemplateVersion(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	version, ok := parseVersionParam(c, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	userID := auth.GetUserID(c)
+	log.Printf("[PROMPTS] Restoring template %s/%s version %d by userId: %s", language, c.Param("name"), version, userID)
+
+	template, err := h.llmService.RestorePromptTemplateVersion(language, c.Param("name"), version, userID)
+	if err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// ReloadPromptTemplates reloads the prompt templates in use from the database
+// @Summary Reload prompt templates
+// @Description Reload the prompt templates in use from the database, e.g. after another instance saved a new version (admin only)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /admin/prompts/reload [post]
+func (h *PromptTemplateHandler) ReloadPromptTemplates(c *gin.Context) {
+	if err := h.llmService.ReloadPromptTemplates(); err != nil {
+		respondPromptTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Prompt templates reloaded successfully"})
+}
+
+// respondPromptTemplateError maps prompt template service errors to HTTP responses
+func respondPromptTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromptTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+	case errors.Is(err, services.ErrPromptTemplateVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt template version not found"})
+	case errors.Is(err, services.ErrInvalidPromptTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[PROMPTS] Error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error processing prompt template."})
+	}
+}
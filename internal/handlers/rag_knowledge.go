@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/daniele/web-app-caa/internal/auth"
+	"github.com/daniele/web-app-caa/internal/models"
 	"github.com/daniele/web-app-caa/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -23,16 +27,23 @@ func NewRagKnowledgeHandler(llmService *services.LLMService) *RagKnowledgeHandle
 
 // GetRagKnowledge godoc
 // @Summary Get RAG knowledge
-// @Description Retrieve the current RAG knowledge data used by the AI system for language processing
+// @Description Retrieve the current RAG knowledge data of a language used by the AI system for language processing
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
 // @Success 200 {object} map[string]interface{} "RAG knowledge data"
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Security BearerAuth
 // @Router /rag-knowledge [get]
 func (h *RagKnowledgeHandler) GetRagKnowledge(c *gin.Context) {
-	knowledge := h.llmService.GetRagKnowledge()
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	knowledge := h.llmService.GetRagKnowledge(language)
 	if knowledge == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "No RAG knowledge available",
@@ -45,18 +56,26 @@ func (h *RagKnowledgeHandler) GetRagKnowledge(c *gin.Context) {
 
 // UpdateRagKnowledge godoc
 // @Summary Update RAG knowledge
-// @Description Update the RAG knowledge data and optionally save to S3 storage. Requires admin privileges.
+// @Description Replace the RAG knowledge data of a language and save it to the RAG storage (S3 or a local directory, see RAG_STORAGE) when one is configured, unless save=false. The document must match the schema of /rag-knowledge/schema; problems are reported with the JSON Pointer of each offending value. The change is recorded in /rag-knowledge/history. Requires admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
 // @Param knowledge body map[string]interface{} true "RAG knowledge data structure"
-// @Param save_to_s3 query bool false "Whether to save the updated knowledge to S3 storage"
-// @Success 200 {object} map[string]interface{} "Success message with S3 save status"
-// @Failure 400 {object} map[string]interface{} "Bad request - invalid JSON format"
+// @Param save query bool false "Whether to save the updated knowledge to the RAG storage; defaults to true when a storage is configured"
+// @Param save_to_s3 query bool false "Older name of save, for S3 and local storage alike"
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Success 200 {object} map[string]interface{} "Success message with save status and storage"
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid JSON format, save flag or unsupported language"
+// @Failure 422 {object} models.RagValidationErrorResponse "The knowledge does not match the schema"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Security BearerAuth
 // @Router /rag-knowledge [put]
 func (h *RagKnowledgeHandler) UpdateRagKnowledge(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
 	var knowledge map[string]interface{}
 	if err := c.ShouldBindJSON(&knowledge); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -65,42 +84,151 @@ func (h *RagKnowledgeHandler) UpdateRagKnowledge(c *gin.Context) {
 		return
 	}
 
-	// Check if we should save to S3
-	saveToS3 := false
-	if saveToS3Param := c.Query("save_to_s3"); saveToS3Param != "" {
-		if parsed, err := strconv.ParseBool(saveToS3Param); err == nil {
-			saveToS3 = parsed
-		}
+	save, ok := h.saveQuery(c)
+	if !ok {
+		return
 	}
 
 	// Update RAG knowledge
-	if err := h.llmService.UpdateRagKnowledge(knowledge, saveToS3); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to update RAG knowledge: %v", err),
-		})
+	if err := h.llmService.UpdateRagKnowledge(language, knowledge, save, auth.GetUserID(c)); err != nil {
+		respondRagKnowledgeError(c, "update RAG knowledge", err)
 		return
 	}
 
 	response := gin.H{
 		"message":     "RAG knowledge updated successfully",
-		"saved_to_s3": saveToS3,
+		"language":    language,
+		"saved":       save,
+		"saved_to_s3": save,
+		"storage":     h.llmService.RagStorageName(),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// PatchRagKnowledge godoc
+// @Summary Patch RAG knowledge
+// @Description Apply a JSON Patch (RFC 6902) to the RAG knowledge of a language, e.g. [{"op":"add","path":"/passato_prossimo/irregular_participles/correre","value":"corso"}] to add one irregular verb without resending the whole document. Operations apply in order and the patch is all or nothing; a test operation guards against concurrent edits. The result must match the schema. The change is recorded in /rag-knowledge/history. Requires admin privileges.
+// @Tags rag-knowledge
+// @Accept json
+// @Produce json
+// @Param patch body []models.RagPatchOperation true "JSON Patch operations"
+// @Param save query bool false "Whether to save the patched knowledge to the RAG storage; defaults to true when a storage is configured"
+// @Param save_to_s3 query bool false "Older name of save"
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Success 200 {object} map[string]interface{} "The patched RAG knowledge"
+// @Failure 400 {object} map[string]interface{} "Bad request - malformed patch, missing path, invalid save flag or unsupported language"
+// @Failure 409 {object} map[string]interface{} "A test operation failed"
+// @Failure 422 {object} models.RagValidationErrorResponse "The patched knowledge does not match the schema"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Security BearerAuth
+// @Router /rag-knowledge [patch]
+func (h *RagKnowledgeHandler) PatchRagKnowledge(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	var operations []models.RagPatchOperation
+	if err := c.ShouldBindJSON(&operations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON Patch format",
+		})
+		return
+	}
+	if len(operations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "The patch has no operations",
+		})
+		return
+	}
+
+	save, ok := h.saveQuery(c)
+	if !ok {
+		return
+	}
+
+	knowledge, err := h.llmService.PatchRagKnowledge(language, operations, save, auth.GetUserID(c))
+	if err != nil {
+		respondRagKnowledgeError(c, "patch RAG knowledge", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, knowledge)
+}
+
+// ValidateRagKnowledge godoc
+// @Summary Validate RAG knowledge
+// @Description Check a RAG knowledge document of a language against the schema without applying it. Requires admin privileges.
+// @Tags rag-knowledge
+// @Accept json
+// @Produce json
+// @Param knowledge body map[string]interface{} true "RAG knowledge data structure"
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Success 200 {object} models.RagValidationResponse
+// @Failure 400 {object} map[string]interface{} "Bad request - invalid JSON format or unsupported language"
+// @Security BearerAuth
+// @Router /rag-knowledge/validate [post]
+func (h *RagKnowledgeHandler) ValidateRagKnowledge(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	var knowledge map[string]interface{}
+	if err := c.ShouldBindJSON(&knowledge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	problems := h.llmService.ValidateRagKnowledge(language, knowledge)
+	c.JSON(http.StatusOK, models.RagValidationResponse{
+		Language: language,
+		Valid:    len(problems) == 0,
+		Problems: problems,
+	})
+}
+
+// GetRagKnowledgeSchema godoc
+// @Summary Get RAG knowledge schema
+// @Description Describe the RAG knowledge document of a language: its tense sections and the groups they are made of. Requires admin privileges.
+// @Tags rag-knowledge
+// @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Success 200 {object} models.RagSchemaResponse
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
+// @Security BearerAuth
+// @Router /rag-knowledge/schema [get]
+func (h *RagKnowledgeHandler) GetRagKnowledgeSchema(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.llmService.RagKnowledgeSchema(language))
+}
+
 // ReloadRagKnowledge godoc
 // @Summary Reload RAG knowledge
-// @Description Reload RAG knowledge from S3 storage or fall back to local file if S3 is unavailable. Requires admin privileges.
+// @Description Reload the RAG knowledge of a language from the RAG storage (S3 or a local directory) or fall back to the bundled file if it has none. The change is recorded in /rag-knowledge/history. Requires admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
 // @Success 200 {object} map[string]interface{} "Success message"
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Security BearerAuth
 // @Router /rag-knowledge/reload [post]
 func (h *RagKnowledgeHandler) ReloadRagKnowledge(c *gin.Context) {
-	if err := h.llmService.ReloadRagKnowledge(); err != nil {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	if err := h.llmService.ReloadRagKnowledge(language, auth.GetUserID(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to reload RAG knowledge: %v", err),
 		})
@@ -114,16 +242,23 @@ func (h *RagKnowledgeHandler) ReloadRagKnowledge(c *gin.Context) {
 
 // BackupRagKnowledge godoc
 // @Summary Create RAG knowledge backup
-// @Description Create a timestamped backup of the current RAG knowledge in S3 storage. Requires S3 to be enabled and admin privileges.
+// @Description Create a timestamped backup of the current RAG knowledge of a language in the RAG storage (S3 or a local directory); with RAG_BACKUP_RETENTION set the oldest backups beyond it are deleted. Requires a RAG storage and admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
 // @Success 200 {object} map[string]interface{} "Success message"
-// @Failure 500 {object} map[string]interface{} "Internal server error - S3 not enabled or backup failed"
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
+// @Failure 500 {object} map[string]interface{} "Internal server error - no RAG storage or backup failed"
 // @Security BearerAuth
 // @Router /rag-knowledge/backup [post]
 func (h *RagKnowledgeHandler) BackupRagKnowledge(c *gin.Context) {
-	if err := h.llmService.BackupRagKnowledge(); err != nil {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	if err := h.llmService.BackupRagKnowledge(language); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to create backup: %v", err),
 		})
@@ -137,16 +272,23 @@ func (h *RagKnowledgeHandler) BackupRagKnowledge(c *gin.Context) {
 
 // ListRagKnowledgeBackups godoc
 // @Summary List RAG knowledge backups
-// @Description List all available RAG knowledge backups in S3 storage with timestamps and metadata. Requires S3 to be enabled and admin privileges.
+// @Description List the RAG knowledge file of a language in the RAG storage and its backups, with timestamps and metadata. Requires a RAG storage and admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
 // @Success 200 {object} map[string]interface{} "Object containing backups array"
-// @Failure 500 {object} map[string]interface{} "Internal server error - S3 not enabled or list failed"
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
+// @Failure 500 {object} map[string]interface{} "Internal server error - no RAG storage or list failed"
 // @Security BearerAuth
 // @Router /rag-knowledge/backups [get]
 func (h *RagKnowledgeHandler) ListRagKnowledgeBackups(c *gin.Context) {
-	backups, err := h.llmService.ListRagKnowledgeBackups()
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	backups, err := h.llmService.ListRagKnowledgeBackups(language)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to list backups: %v", err),
@@ -155,24 +297,33 @@ func (h *RagKnowledgeHandler) ListRagKnowledgeBackups(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"backups": backups,
+		"language": language,
+		"backups":  backups,
 	})
 }
 
 // RestoreRagKnowledgeFromBackup godoc
 // @Summary Restore RAG knowledge from backup
-// @Description Restore RAG knowledge from a specific timestamped backup stored in S3. This will replace the current knowledge. Requires S3 to be enabled and admin privileges.
+// @Description Restore the RAG knowledge of a language from one of its timestamped backups in the RAG storage. This will replace the current knowledge, once the backup is checked against the schema, and save it as the knowledge file of the storage so it survives reloads and restarts. The restore is recorded in /rag-knowledge/history; /rag-knowledge/diff shows what it would change. Requires a RAG storage and admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
-// @Param backup_key path string true "S3 backup key (e.g., 'caa/backups/rag_knowledge_20240829_143052.json')"
+// @Param backup_key path string true "Backup key from /rag-knowledge/backups, slashes included (e.g., 'caa/backups/rag_knowledge_20240829_143052.123456.json' in S3, 'backups/rag_knowledge_20240829_143052.123456.json' locally)"
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
 // @Success 200 {object} map[string]interface{} "Success message with backup key"
-// @Failure 400 {object} map[string]interface{} "Bad request - backup key required"
+// @Failure 400 {object} map[string]interface{} "Bad request - backup key required, not a backup of the language or unsupported language"
+// @Failure 422 {object} models.RagValidationErrorResponse "The backup does not match the schema"
 // @Failure 500 {object} map[string]interface{} "Internal server error - restore failed"
 // @Security BearerAuth
 // @Router /rag-knowledge/restore/{backup_key} [post]
 func (h *RagKnowledgeHandler) RestoreRagKnowledgeFromBackup(c *gin.Context) {
-	backupKey := c.Param("backup_key")
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	// The key is matched by a wildcard, as backup keys hold slashes
+	backupKey := strings.TrimPrefix(c.Param("backup_key"), "/")
 	if backupKey == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Backup key is required",
@@ -180,10 +331,8 @@ func (h *RagKnowledgeHandler) RestoreRagKnowledgeFromBackup(c *gin.Context) {
 		return
 	}
 
-	if err := h.llmService.RestoreRagKnowledgeFromBackup(backupKey); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to restore from backup: %v", err),
-		})
+	if err := h.llmService.RestoreRagKnowledgeFromBackup(language, backupKey, auth.GetUserID(c)); err != nil {
+		respondRagKnowledgeError(c, "restore from backup", err)
 		return
 	}
 
@@ -192,26 +341,150 @@ func (h *RagKnowledgeHandler) RestoreRagKnowledgeFromBackup(c *gin.Context) {
 	})
 }
 
-// CheckS3Health godoc
-// @Summary Check S3 health
-// @Description Check the health and connectivity of S3 storage service. Verifies bucket access and connectivity. Requires admin privileges.
+// DiffRagKnowledge godoc
+// @Summary Diff RAG knowledge versions
+// @Description Compare two versions of the RAG knowledge of a language, e.g. a backup with the live knowledge before restoring it. A version is live (the knowledge in use), file (the local file) or a backup key from /rag-knowledge/backups. Changes list the JSON Pointer of each value added, removed or changed. Requires admin privileges.
+// @Tags rag-knowledge
+// @Produce json
+// @Param from query string true "Version to compare from: live, file or a backup key"
+// @Param to query string false "Version to compare to: live, file or a backup key (default live)"
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Success 200 {object} models.RagDiffResponse
+// @Failure 400 {object} map[string]interface{} "Bad request - unknown version or unsupported language"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Security BearerAuth
+// @Router /rag-knowledge/diff [get]
+func (h *RagKnowledgeHandler) DiffRagKnowledge(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	diff, err := h.llmService.DiffRagKnowledge(language, c.Query("from"), c.DefaultQuery("to", services.RagVersionLive))
+	if err != nil {
+		respondRagKnowledgeError(c, "diff RAG knowledge", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// GetRagKnowledgeHistory godoc
+// @Summary Get RAG knowledge history
+// @Description List the changes admins made to the RAG knowledge of a language, newest first: updates, patches, restores and reloads, each with the acting admin, the time and a summary of what changed. Requires admin privileges.
+// @Tags rag-knowledge
+// @Produce json
+// @Param language query string false "Language code (it, es, en); defaults to the default language"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 20)"
+// @Success 200 {object} map[string]interface{} "Object containing changes array and pagination"
+// @Failure 400 {object} map[string]interface{} "Bad request - unsupported language"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Security BearerAuth
+// @Router /rag-knowledge/history [get]
+func (h *RagKnowledgeHandler) GetRagKnowledgeHistory(c *gin.Context) {
+	language, ok := languageQuery(c, h.llmService)
+	if !ok {
+		return
+	}
+
+	page := 1
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	changes, total, err := h.llmService.ListRagKnowledgeChanges(language, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit != 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"language":    language,
+		"changes":     changes,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": totalPages,
+	})
+}
+
+// CheckRagStorageHealth godoc
+// @Summary Check RAG storage health
+// @Description Check the health of the RAG storage: bucket access and connectivity for S3, that the directory is writable for local storage. Requires admin privileges.
 // @Tags rag-knowledge
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]interface{} "S3 health status with connection details"
-// @Failure 500 {object} map[string]interface{} "S3 health check failed or S3 not enabled"
+// @Success 200 {object} map[string]interface{} "Storage health status"
+// @Failure 500 {object} map[string]interface{} "Storage health check failed or no RAG storage"
 // @Security BearerAuth
 // @Router /rag-knowledge/health [get]
-func (h *RagKnowledgeHandler) CheckS3Health(c *gin.Context) {
-	if err := h.llmService.CheckS3Health(); err != nil {
+func (h *RagKnowledgeHandler) CheckRagStorageHealth(c *gin.Context) {
+	if err := h.llmService.CheckRagStorageHealth(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("S3 health check failed: %v", err),
+			"error": fmt.Sprintf("RAG storage health check failed: %v", err),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "S3 storage is healthy",
+		"message": "RAG storage is healthy",
+		"storage": h.llmService.RagStorageName(),
 		"status":  "ok",
 	})
 }
+
+// respondRagKnowledgeError maps RAG knowledge edit errors to HTTP responses
+func respondRagKnowledgeError(c *gin.Context, action string, err error) {
+	var validationErr *services.RagValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, models.RagValidationErrorResponse{
+			Error:    "Invalid RAG knowledge",
+			Problems: validationErr.Problems,
+		})
+	case errors.Is(err, services.ErrInvalidRagPatch), errors.Is(err, services.ErrInvalidRagVersion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRagPatchTestFailed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to %s: %v", action, err),
+		})
+	}
+}
+
+// saveQuery reads whether an edit is saved to the RAG storage from the save query
+// parameter, or save_to_s3, its older name. Without either, edits are saved
+// whenever a storage is configured. It answers the request itself and returns
+// false when the flag is not a boolean.
+func (h *RagKnowledgeHandler) saveQuery(c *gin.Context) (bool, bool) {
+	param := c.Query("save")
+	if param == "" {
+		param = c.Query("save_to_s3")
+	}
+	if param == "" {
+		return h.llmService.RagStorageName() != "", true
+	}
+
+	save, err := strconv.ParseBool(param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid save flag %q, use true or false", param)})
+		return false, false
+	}
+	return save, true
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AIUsageMiddleware creates a middleware that enforces the user's daily AI quota
+// and records the usage of the request. It must run after RBACMiddleware.
+func AIUsageMiddleware(usageService *services.AIUsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			log.Printf("[AI-USAGE] User ID not found in context - RequireAuth middleware not executed?")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		quota, err := usageService.QuotaStatus(userID)
+		if err != nil {
+			log.Printf("[AI-USAGE] Error checking quota for user %s: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Quota check failed"})
+			c.Abort()
+			return
+		}
+
+		if quota.Exceeded {
+			log.Printf("[AI-USAGE] User %s exceeded daily AI quota - Requests: %d/%d, Tokens: %d/%d",
+				userID, quota.Requests, quota.RequestLimit, quota.Tokens, quota.TokenLimit)
+			if resetsAt, err := time.Parse(time.RFC3339, quota.ResetsAt); err == nil {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(resetsAt).Seconds())+1))
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Daily AI quota exceeded", "quota": quota})
+			c.Abort()
+			return
+		}
+
+		// Meter the LLM calls made while handling the request
+		usage := &services.LLMUsage{}
+		c.Request = c.Request.WithContext(services.WithLLMUsage(c.Request.Context(), usage))
+		start := time.Now()
+
+		c.Next()
+
+		// Rejected requests that never reached the LLM do not count
+		status := c.Writer.Status()
+		if status >= http.StatusBadRequest && usage.Calls == 0 && !usage.Cached {
+			return
+		}
+		usageService.Record(userID, c.FullPath(), usage, time.Since(start), status)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -57,8 +58,9 @@ func NewOllamaClient(baseURL string) *OllamaClient {
 	}
 }
 
-// Chat sends a chat request to Ollama and returns the response
-func (c *OllamaClient) Chat(model, prompt string, options ...ChatOption) (*OllamaResponse, error) {
+// Chat sends a chat request to Ollama and returns the response. The request is
+// cancelled when ctx is done.
+func (c *OllamaClient) Chat(ctx context.Context, model, prompt string, options ...ChatOption) (*OllamaResponse, error) {
 	// Build the request with default values
 	req := OllamaRequest{
 		Model: model,
@@ -84,7 +86,7 @@ func (c *OllamaClient) Chat(model, prompt string, options ...ChatOption) (*Ollam
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/api/chat", c.BaseURL)
-	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating HTTP request: %w", err)
 	}
@@ -153,8 +155,8 @@ func WithSystemMessage(systemPrompt string) ChatOption {
 }
 
 // SimpleChat is a convenience function for basic chat interactions
-func (c *OllamaClient) SimpleChat(model, prompt string) (string, error) {
-	resp, err := c.Chat(model, prompt, WithJSONFormat())
+func (c *OllamaClient) SimpleChat(ctx context.Context, model, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, model, prompt, WithJSONFormat())
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Scopes of an AI quota
+const (
+	AIQuotaScopeUser = "user"
+	AIQuotaScopeRole = "role"
+)
+
+// AIUsage records one call of an AI endpoint with the LLM work it caused
+type AIUsage struct {
+	ID               string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID           string    `json:"user_id" gorm:"type:varchar(36);not null;index:idx_ai_usage_user_day,priority:1"`
+	Day              string    `json:"day" gorm:"type:varchar(10);not null;index:idx_ai_usage_user_day,priority:2;index"` // Server local date, YYYY-MM-DD
+	Endpoint         string    `json:"endpoint" gorm:"type:varchar(64);not null"`
+	Provider         string    `json:"provider,omitempty"` // Provider of the last LLM answer
+	Model            string    `json:"model,omitempty"`
+	LLMCalls         int       `json:"llm_calls"` // LLM answers received, repairs included
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cached           bool      `json:"cached"` // Served from the result cache
+	LatencyMs        int64     `json:"latency_ms"`
+	Status           int       `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for AIUsage
+func (AIUsage) TableName() string {
+	return "ai_usage"
+}
+
+// BeforeCreate generates a UUID for the usage record before creating it
+func (u *AIUsage) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AIQuota limits the daily AI usage of a user or of the users holding a role.
+// A limit of 0 means unlimited.
+type AIQuota struct {
+	Scope         string    `json:"scope" gorm:"primaryKey;type:varchar(8)"`    // "user" or "role"
+	Subject       string    `json:"subject" gorm:"primaryKey;type:varchar(64)"` // User ID or role name
+	DailyRequests int       `json:"daily_requests"`
+	DailyTokens   int       `json:"daily_tokens"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for AIQuota
+func (AIQuota) TableName() string {
+	return "ai_quotas"
+}
+
+// SetAIQuotaRequest represents the request to set the quota of a user or role
+type SetAIQuotaRequest struct {
+	Scope         string `json:"scope" binding:"required,oneof=user role"`
+	Subject       string `json:"subject" binding:"required,max=64"`
+	DailyRequests int    `json:"daily_requests" binding:"min=0"`
+	DailyTokens   int    `json:"daily_tokens" binding:"min=0"`
+}
+
+// AIQuotaStatusResponse reports a user's AI usage of the current day against
+// their quota. Requests counts the calls that reached the LLM. Limits of 0 are
+// unlimited; LimitSource tells where they come from ("default", "role:<name>" or "user").
+type AIQuotaStatusResponse struct {
+	UserID       string `json:"user_id"`
+	Day          string `json:"day"`
+	Requests     int64  `json:"requests"`
+	RequestLimit int    `json:"request_limit"`
+	Tokens       int64  `json:"tokens"`
+	TokenLimit   int    `json:"token_limit"`
+	LimitSource  string `json:"limit_source"`
+	Exceeded     bool   `json:"exceeded"`
+	ResetsAt     string `json:"resets_at"`
+}
+
+// AIUsageTotals sums the AI usage of a group of calls
+type AIUsageTotals struct {
+	Requests         int64   `json:"requests"`
+	CachedRequests   int64   `json:"cached_requests"`
+	LLMCalls         int64   `json:"llm_calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+// AIUsageGroup is the AI usage of one day, endpoint, model or user
+type AIUsageGroup struct {
+	Key      string `json:"key"`
+	Username string `json:"username,omitempty"` // Set when grouping by user
+	AIUsageTotals
+}
+
+// AIUsageReportResponse represents the AI usage analytics over a range of days
+type AIUsageReportResponse struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	UserID     string         `json:"user_id,omitempty"` // Set when the report covers one user
+	Totals     AIUsageTotals  `json:"totals"`
+	ByDay      []AIUsageGroup `json:"by_day"`
+	ByEndpoint []AIUsageGroup `json:"by_endpoint"`
+	ByModel    []AIUsageGroup `json:"by_model"`
+	TopUsers   []AIUsageGroup `json:"top_users,omitempty"`
+}
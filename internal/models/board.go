@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultBoardName is the name given to the board created for every user
+const DefaultBoardName = "Default"
+
+// Board is a named grid owned by a user. Every user has exactly one default
+// board, which the board-less grid endpoints operate on.
+type Board struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID    string    `json:"user_id" gorm:"not null;type:varchar(36);index"`
+	Name      string    `json:"name" gorm:"not null"`
+	IsDefault bool      `json:"is_default" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Reference to User
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName specifies the table name for Board
+func (Board) TableName() string {
+	return "boards"
+}
+
+// BeforeCreate generates a UUID for the board before creating it
+func (b *Board) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BoardRequest represents the request to create or rename a board
+type BoardRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// SetActiveBoardRequest represents the request to switch the active board
+type SetActiveBoardRequest struct {
+	BoardID string `json:"board_id" binding:"required"`
+}
+
+// BoardResponse represents a board in API responses
+type BoardResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	IsActive  bool      `json:"is_active"`
+	ItemCount int       `json:"item_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BoardListResponse represents the list of a user's boards
+type BoardListResponse struct {
+	Boards []BoardResponse `json:"boards"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// GridEvent is pushed to a user's connected clients after their grid changes
+type GridEvent struct {
+	UserID    string    `json:"-"`
+	BoardID   string    `json:"board_id"`
+	Action    string    `json:"action"`
+	Summary   string    `json:"summary"`
+	Revision  int       `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+}
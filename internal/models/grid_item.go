@@ -9,6 +9,7 @@ import (
 type GridItem struct {
 	ID             string `json:"id" gorm:"primaryKey;type:varchar(36)"`
 	UserID         string `json:"user_id" gorm:"not null;type:varchar(36);index"`
+	BoardID        string `json:"board_id" gorm:"type:varchar(36);index"`
 	ParentCategory string `json:"parent_category" gorm:"not null;index"`
 	ItemOrder      int    `json:"item_order"`
 	Type           string `json:"type" gorm:"not null"`
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GridTemplate represents a starter grid users can choose during setup.
+// Built-in templates are seeded with a slug (default, simplified, empty) so
+// clients that send those grid types keep working.
+type GridTemplate struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Slug        *string   `json:"slug" gorm:"type:varchar(64);uniqueIndex"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Icon        string    `json:"icon" gorm:"type:text"`
+	Grid        string    `json:"-" gorm:"type:longtext"`
+	ItemCount   int       `json:"item_count"`
+	CreatedBy   string    `json:"created_by" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GridTemplate
+func (GridTemplate) TableName() string {
+	return "grid_templates"
+}
+
+// BeforeCreate generates a UUID for the template before creating it
+func (t *GridTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// GridTemplateRequest represents the payload to create or update a grid template.
+// On update, an omitted slug or grid keeps the template's current one.
+type GridTemplateRequest struct {
+	Name        string       `json:"name" binding:"required,min=1,max=100"`
+	Slug        string       `json:"slug" binding:"omitempty,max=64"`
+	Description string       `json:"description"`
+	Icon        string       `json:"icon"`
+	Grid        GridResponse `json:"grid"`
+}
+
+// GridTemplateFromBoardRequest represents the payload to save a board's grid as a template
+type GridTemplateFromBoardRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Slug        string `json:"slug" binding:"omitempty,max=64"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// GridTemplateResponse represents a grid template; the grid is only included
+// when a single template is requested
+type GridTemplateResponse struct {
+	ID          string       `json:"id"`
+	Slug        string       `json:"slug,omitempty"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Icon        string       `json:"icon"`
+	ItemCount   int          `json:"item_count"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Grid        GridResponse `json:"grid,omitempty"`
+}
+
+// GridTemplateListResponse represents the list of grid templates
+type GridTemplateListResponse struct {
+	Templates []GridTemplateResponse `json:"templates"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GridTrashEntry holds the items removed by one delete so they can be restored
+// until the entry expires. Items contains the deleted item followed by the
+// contents of the pages it opened, recursively.
+type GridTrashEntry struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID         string     `json:"user_id" gorm:"not null;type:varchar(36);index"`
+	BoardID        string     `json:"board_id" gorm:"type:varchar(36);index"`
+	ItemID         string     `json:"item_id" gorm:"type:varchar(36)"`
+	Label          string     `json:"label"`
+	Type           string     `json:"type"`
+	ParentCategory string     `json:"parent_category"`
+	ItemCount      int        `json:"item_count"`
+	Items          string     `json:"-" gorm:"type:longtext"`
+	DeletedAt      time.Time  `json:"deleted_at" gorm:"index"`
+	ExpiresAt      *time.Time `json:"expires_at" gorm:"index"`
+
+	// Reference to User
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName specifies the table name for GridTrashEntry
+func (GridTrashEntry) TableName() string {
+	return "grid_trash"
+}
+
+// BeforeCreate generates a UUID for the trash entry before creating it
+func (e *GridTrashEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// GridTrashEntryResponse represents a trash entry in list responses
+type GridTrashEntryResponse struct {
+	ID             string     `json:"id"`
+	ItemID         string     `json:"item_id"`
+	Label          string     `json:"label"`
+	Type           string     `json:"type"`
+	ParentCategory string     `json:"parent_category"`
+	ItemCount      int        `json:"item_count"`
+	DeletedAt      time.Time  `json:"deleted_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// GridTrashListResponse represents the trash of a board, newest first
+type GridTrashListResponse struct {
+	Entries []GridTrashEntryResponse `json:"entries"`
+}
+
+// GridTrashRestoreResponse represents the result of restoring a trash entry
+type GridTrashRestoreResponse struct {
+	Message        string `json:"message"`
+	ParentCategory string `json:"parent_category"`
+	ItemsRestored  int    `json:"items_restored"`
+	Revision       int    `json:"revision"`
+}
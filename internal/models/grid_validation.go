@@ -0,0 +1,70 @@
+package models
+
+// Grid problem codes reported by grid validation
+const (
+	GridProblemDuplicateID    = "duplicate_id"
+	GridProblemUnknownType    = "unknown_type"
+	GridProblemUnknownAction  = "unknown_action"
+	GridProblemMissingTarget  = "missing_target"
+	GridProblemReservedTarget = "reserved_target"
+	GridProblemCycle          = "cycle"
+	GridProblemDanglingLink   = "dangling_link"
+	GridProblemOrphanPage     = "orphan_page"
+)
+
+// GridProblem describes one structural problem of a grid
+type GridProblem struct {
+	Code     string `json:"code"`
+	Category string `json:"category"`
+	ItemID   string `json:"item_id,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Message  string `json:"message"`
+}
+
+// GridValidationReport represents the result of validating a board's grid.
+// Errors make a grid invalid and are rejected on save; warnings are structural
+// leftovers (orphaned pages, links to empty pages) that repair can clean up.
+type GridValidationReport struct {
+	BoardID  string        `json:"board_id"`
+	Valid    bool          `json:"valid"`
+	Errors   []GridProblem `json:"errors"`
+	Warnings []GridProblem `json:"warnings"`
+	Revision int           `json:"revision"`
+}
+
+// GridRepairRequest selects what a grid repair may remove
+type GridRepairRequest struct {
+	// RemoveDanglingLinks also deletes category items whose page has no items.
+	// These are indistinguishable from new, still empty categories, so it is off by default.
+	RemoveDanglingLinks bool `json:"remove_dangling_links"`
+}
+
+// GridRepairResponse represents the result of repairing a board's grid
+type GridRepairResponse struct {
+	Repaired     []GridProblem        `json:"repaired"`
+	ItemsRemoved int                  `json:"items_removed"`
+	Report       GridValidationReport `json:"report"`
+	Revision     int                  `json:"revision"`
+}
+
+// GridValidationErrorResponse represents a grid write rejected by validation
+type GridValidationErrorResponse struct {
+	Error    string        `json:"error"`
+	Problems []GridProblem `json:"problems"`
+}
+
+// BoardValidationSummary represents the validation result of one board in the admin overview
+type BoardValidationSummary struct {
+	BoardID   string `json:"board_id"`
+	BoardName string `json:"board_name"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Errors    int    `json:"errors"`
+	Warnings  int    `json:"warnings"`
+}
+
+// GridValidationOverviewResponse lists the boards that have grid problems
+type GridValidationOverviewResponse struct {
+	BoardsChecked int                      `json:"boards_checked"`
+	Boards        []BoardValidationSummary `json:"boards"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GridVersion represents a snapshot of a board's grid taken after a write
+type GridVersion struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID    string    `json:"user_id" gorm:"not null;type:varchar(36);uniqueIndex:idx_grid_versions_board_version"`
+	BoardID   string    `json:"board_id" gorm:"type:varchar(36);uniqueIndex:idx_grid_versions_board_version"`
+	Version   int       `json:"version" gorm:"not null;uniqueIndex:idx_grid_versions_board_version"`
+	Action    string    `json:"action" gorm:"not null"`
+	Summary   string    `json:"summary"`
+	ItemCount int       `json:"item_count"`
+	Snapshot  string    `json:"-" gorm:"type:longtext"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Reference to User
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName specifies the table name for GridVersion
+func (GridVersion) TableName() string {
+	return "grid_versions"
+}
+
+// BeforeCreate generates a UUID for the version before creating it
+func (v *GridVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// GridVersionResponse represents a grid version in list responses
+type GridVersionResponse struct {
+	Version   int          `json:"version"`
+	Action    string       `json:"action"`
+	Summary   string       `json:"summary"`
+	ItemCount int          `json:"item_count"`
+	CreatedAt time.Time    `json:"created_at"`
+	Grid      GridResponse `json:"grid,omitempty"`
+}
+
+// GridVersionListResponse represents the list of a user's grid versions
+type GridVersionListResponse struct {
+	Versions []GridVersionResponse `json:"versions"`
+}
+
+// GridDiffResponse represents the differences between two grid versions
+type GridDiffResponse struct {
+	From     int                `json:"from"`
+	To       int                `json:"to"`
+	Added    []GridDiffItem     `json:"added"`
+	Removed  []GridDiffItem     `json:"removed"`
+	Modified []GridDiffModified `json:"modified"`
+}
+
+// GridDiffItem represents an item added to or removed from the grid
+type GridDiffItem struct {
+	Category string           `json:"category"`
+	Item     GridItemResponse `json:"item"`
+}
+
+// GridDiffModified represents an item whose fields or position changed between versions
+type GridDiffModified struct {
+	ID       string                   `json:"id"`
+	Category string                   `json:"category"`
+	Label    string                   `json:"label"`
+	Changes  map[string]GridDiffValue `json:"changes"`
+}
+
+// GridDiffValue holds the old and new value of a changed field
+type GridDiffValue struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// GridRevisionErrorResponse is returned when a grid write fails its revision check
+type GridRevisionErrorResponse struct {
+	Error    string `json:"error"`
+	Message  string `json:"message"`
+	Revision int    `json:"revision"`
+}
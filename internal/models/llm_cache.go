@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// LLMCacheEntry stores a conjugation or correction result so repeated requests
+// do not reach the LLM again. The key hashes the normalized request together with
+// the model, prompt template and RAG knowledge versions.
+type LLMCacheEntry struct {
+	CacheKey  string     `json:"cache_key" gorm:"primaryKey;type:varchar(64)"`
+	Kind      string     `json:"kind" gorm:"type:varchar(16);index"` // conjugate or correct
+	Result    string     `json:"-" gorm:"type:text"`                 // JSON encoded result
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index"`            // Nil when the result does not expire
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for LLMCacheEntry
+func (LLMCacheEntry) TableName() string {
+	return "llm_cache"
+}
+
+// LLMCacheStatsResponse represents the statistics of the LLM result cache
+type LLMCacheStatsResponse struct {
+	Enabled    bool    `json:"enabled"`
+	Persistent bool    `json:"persistent"`
+	Entries    int     `json:"entries"`  // Results held in memory
+	Capacity   int     `json:"capacity"` // Results the memory cache can hold
+	TTL        string  `json:"ttl"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	StoreHits  uint64  `json:"store_hits"` // Hits served from the database after a memory miss
+	Evictions  uint64  `json:"evictions"`
+	HitRate    float64 `json:"hit_rate"`
+}
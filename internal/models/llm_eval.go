@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// Kinds of golden dataset cases
+const (
+	EvalKindConjugate = "conjugate"
+	EvalKindCorrect   = "correct"
+)
+
+// EvalCase is one case of the golden dataset, stored as a line of JSONL.
+// Conjugation cases name the tense and the expected form of each verb;
+// correction cases the expected sentence, plus other acceptable ones.
+type EvalCase struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`               // conjugate or correct
+	Language  string            `json:"language,omitempty"` // Defaults to it
+	Sentence  string            `json:"sentence"`
+	BaseForms []string          `json:"base_forms,omitempty"`
+	Tense     string            `json:"tense,omitempty"`
+	Expected  map[string]string `json:"expected,omitempty"`
+	// ExpectedSentence and Accepted are the right answers of a correction case
+	ExpectedSentence string   `json:"expected_sentence,omitempty"`
+	Accepted         []string `json:"accepted,omitempty"`
+}
+
+// EvalCaseResult is the outcome of one case of an evaluation run
+type EvalCaseResult struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind"`
+	Language string            `json:"language"`
+	Group    string            `json:"group"` // Language and tense of a conjugation case (it/presente), language and "correct" for corrections
+	Passed   bool              `json:"passed"`
+	Score    float64           `json:"score"` // Share of verbs conjugated right; 0 or 1 for corrections
+	Expected map[string]string `json:"expected,omitempty"`
+	Got      map[string]string `json:"got,omitempty"`
+	Wrong    []string          `json:"wrong,omitempty"` // Verbs conjugated wrong
+	// Sources tells, for each answer, whether the model gave it or the rules or
+	// input replaced it
+	Sources          map[string]string `json:"sources,omitempty"`
+	ExpectedSentence string            `json:"expected_sentence,omitempty"`
+	GotSentence      string            `json:"got_sentence,omitempty"`
+	Attempts         int               `json:"attempts"`
+	RagEntries       []string          `json:"rag_entries,omitempty"` // RAG entries injected into the prompt
+	LLMCalls         int               `json:"llm_calls"`
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	LatencyMs        int64             `json:"latency_ms"`
+	Error            string            `json:"error,omitempty"`
+}
+
+// EvalLatency holds latency percentiles in milliseconds
+type EvalLatency struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+// EvalGroupSummary aggregates the results of a tense or of the corrections of a
+// language, or of the whole run
+type EvalGroupSummary struct {
+	Group    string  `json:"group"`
+	Cases    int     `json:"cases"`
+	Passed   int     `json:"passed"`
+	Accuracy float64 `json:"accuracy"` // Share of cases passed
+	// ItemAccuracy is the share of verbs conjugated right, or of sentences
+	// corrected right
+	ItemAccuracy float64     `json:"item_accuracy"`
+	ModelShare   float64     `json:"model_share"` // Share of answers given by the model rather than rules or input
+	Errors       int         `json:"errors"`
+	Tokens       int         `json:"tokens"`
+	Latency      EvalLatency `json:"latency"`
+}
+
+// EvalRun is an evaluation run of the golden dataset, saved as JSON so later
+// runs can be compared with it
+type EvalRun struct {
+	Label      string             `json:"label"`
+	Dataset    string             `json:"dataset"`
+	Provider   string             `json:"provider"`
+	Model      string             `json:"model"`
+	Templates  map[string]string  `json:"templates"` // Checksum of each prompt template used, keyed by language/name
+	StartedAt  time.Time          `json:"started_at"`
+	DurationMs int64              `json:"duration_ms"`
+	Summary    EvalGroupSummary   `json:"summary"`
+	Groups     []EvalGroupSummary `json:"groups"`
+	Results    []EvalCaseResult   `json:"results"`
+}
+
+// EvalGroupDiff compares a group of two evaluation runs
+type EvalGroupDiff struct {
+	Group             string  `json:"group"`
+	BaseAccuracy      float64 `json:"base_accuracy"`
+	Accuracy          float64 `json:"accuracy"`
+	BaseItemAccuracy  float64 `json:"base_item_accuracy"`
+	ItemAccuracy      float64 `json:"item_accuracy"`
+	BaseP50           int64   `json:"base_p50"`
+	P50               int64   `json:"p50"`
+	BaseP95           int64   `json:"base_p95"`
+	P95               int64   `json:"p95"`
+	BaseTokens        int     `json:"base_tokens"`
+	Tokens            int     `json:"tokens"`
+	MissingInBaseline bool    `json:"missing_in_baseline,omitempty"`
+	MissingInRun      bool    `json:"missing_in_run,omitempty"`
+}
+
+// EvalCaseDiff is a case whose outcome changed between two evaluation runs
+type EvalCaseDiff struct {
+	ID     string          `json:"id"`
+	Change string          `json:"change"` // fixed, regressed, changed, added or removed
+	Base   *EvalCaseResult `json:"base,omitempty"`
+	Run    *EvalCaseResult `json:"run,omitempty"`
+}
+
+// EvalDiff compares an evaluation run with a baseline run
+type EvalDiff struct {
+	BaseLabel string `json:"base_label"`
+	Label     string `json:"label"`
+	BaseModel string `json:"base_model"`
+	Model     string `json:"model"`
+	// ChangedTemplates lists the prompt templates whose checksum differs
+	ChangedTemplates []string        `json:"changed_templates,omitempty"`
+	Summary          EvalGroupDiff   `json:"summary"`
+	Groups           []EvalGroupDiff `json:"groups"`
+	Cases            []EvalCaseDiff  `json:"cases"`
+}
@@ -0,0 +1,92 @@
+package models
+
+// OBFFormat is the Open Board Format version written on export
+const OBFFormat = "open-board-0.1"
+
+// OBFBoard represents a single Open Board Format (.obf) board
+type OBFBoard struct {
+	Format  string      `json:"format"`
+	ID      string      `json:"id"`
+	Locale  string      `json:"locale,omitempty"`
+	Name    string      `json:"name,omitempty"`
+	Buttons []OBFButton `json:"buttons"`
+	Grid    OBFGrid     `json:"grid"`
+	Images  []OBFImage  `json:"images"`
+	Sounds  []OBFSound  `json:"sounds"`
+}
+
+// OBFButton represents a button on an OBF board
+type OBFButton struct {
+	ID              string        `json:"id"`
+	Label           string        `json:"label,omitempty"`
+	Vocalization    string        `json:"vocalization,omitempty"`
+	ImageID         string        `json:"image_id,omitempty"`
+	BackgroundColor string        `json:"background_color,omitempty"`
+	BorderColor     string        `json:"border_color,omitempty"`
+	Action          string        `json:"action,omitempty"`
+	Hidden          bool          `json:"hidden,omitempty"`
+	LoadBoard       *OBFLoadBoard `json:"load_board,omitempty"`
+
+	// CAA-specific extension fields, preserved for lossless round-trips
+	ExtType       string `json:"ext_caa_type,omitempty"`
+	ExtText       string `json:"ext_caa_text,omitempty"`
+	ExtSymbolType string `json:"ext_caa_symbol_type,omitempty"`
+	ExtIsHideable *bool  `json:"ext_caa_is_hideable,omitempty"`
+}
+
+// OBFLoadBoard links a button to another board
+type OBFLoadBoard struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// OBFGrid describes the button layout of a board
+type OBFGrid struct {
+	Rows    int         `json:"rows"`
+	Columns int         `json:"columns"`
+	Order   [][]*string `json:"order"`
+}
+
+// OBFImage represents an image referenced by board buttons
+type OBFImage struct {
+	ID          string `json:"id"`
+	URL         string `json:"url,omitempty"`
+	Data        string `json:"data,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+}
+
+// OBFSound represents a sound referenced by board buttons
+type OBFSound struct {
+	ID          string `json:"id"`
+	URL         string `json:"url,omitempty"`
+	Data        string `json:"data,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// OBZManifest represents the manifest.json of an Open Board Zip (.obz) package
+type OBZManifest struct {
+	Format string       `json:"format"`
+	Root   string       `json:"root"`
+	Paths  OBZPathTable `json:"paths"`
+}
+
+// OBZPathTable maps board and image IDs to their paths inside the package
+type OBZPathTable struct {
+	Boards map[string]string `json:"boards"`
+	Images map[string]string `json:"images,omitempty"`
+	Sounds map[string]string `json:"sounds,omitempty"`
+}
+
+// OBFImportResponse represents the result of an OBF/OBZ import
+type OBFImportResponse struct {
+	Message  string `json:"message"`
+	Boards   int    `json:"boards"`
+	Items    int    `json:"items"`
+	Revision int    `json:"revision"`
+}
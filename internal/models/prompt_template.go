@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PromptTemplate represents one version of an LLM prompt template of a
+// language. The latest version of each template is the one in use; restoring
+// an older version records it again as a new version.
+type PromptTemplate struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Language  string    `json:"language" gorm:"type:varchar(8);not null;default:it;uniqueIndex:idx_prompt_templates_language_name_version"`
+	Name      string    `json:"name" gorm:"type:varchar(64);not null;uniqueIndex:idx_prompt_templates_language_name_version"`
+	Version   int       `json:"version" gorm:"not null;uniqueIndex:idx_prompt_templates_language_name_version"`
+	Source    string    `json:"source" gorm:"type:text;not null"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"created_by" gorm:"type:varchar(36)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for PromptTemplate
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
+
+// BeforeCreate generates a UUID for the template version before creating it
+func (t *PromptTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// PromptTemplateResponse represents a version of a prompt template; the source
+// is only included when a single version is requested
+type PromptTemplateResponse struct {
+	Language  string    `json:"language"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Active    bool      `json:"active"`
+	Checksum  string    `json:"checksum"` // Short hash of the source, part of the AI cache key
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"`
+}
+
+// PromptTemplateListResponse represents the list of prompt templates in use
+type PromptTemplateListResponse struct {
+	Templates []PromptTemplateResponse `json:"templates"`
+}
+
+// PromptTemplateVersionListResponse represents the version history of a prompt template
+type PromptTemplateVersionListResponse struct {
+	Language string                   `json:"language"`
+	Name     string                   `json:"name"`
+	Versions []PromptTemplateResponse `json:"versions"`
+}
+
+// UpdatePromptTemplateRequest represents the payload to save a new version of a
+// prompt template, which becomes the one in use
+type UpdatePromptTemplateRequest struct {
+	Source  string `json:"source" binding:"required"`
+	Comment string `json:"comment" binding:"max=255"`
+}
+
+// ValidatePromptTemplateRequest represents the payload to check a prompt template
+// without saving it. The optional fields replace the sample data it is rendered with.
+type ValidatePromptTemplateRequest struct {
+	Source    string   `json:"source" binding:"required"`
+	Sentence  string   `json:"sentence"`
+	BaseForms []string `json:"base_forms"`
+	Problems  []string `json:"problems"`
+}
+
+// PromptTemplateValidationResponse represents the result of a template check:
+// the parse or render error, or the rendered prompt and possible warnings.
+// RagEntries lists the RAG entries retrieved for the sample verbs.
+type PromptTemplateValidationResponse struct {
+	Valid      bool     `json:"valid"`
+	Error      string   `json:"error,omitempty"`
+	Rendered   string   `json:"rendered,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	RagEntries []string `json:"rag_entries,omitempty"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RagProblem is a problem of a RAG knowledge document. Path is the JSON Pointer
+// of the offending value, e.g. /passato_prossimo/irregular_participles/fare.
+type RagProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// RagValidationErrorResponse represents a RAG knowledge write rejected because
+// the resulting document does not match the schema
+type RagValidationErrorResponse struct {
+	Error    string       `json:"error"`
+	Problems []RagProblem `json:"problems"`
+}
+
+// RagValidationResponse represents the result of checking a RAG knowledge
+// document against the schema without saving it
+type RagValidationResponse struct {
+	Language string       `json:"language"`
+	Valid    bool         `json:"valid"`
+	Problems []RagProblem `json:"problems,omitempty"`
+}
+
+// RagPatchOperation is one operation of a JSON Patch (RFC 6902) applied to the
+// RAG knowledge: add, remove, replace, move, copy or test. From is only used
+// by move and copy, Value by add, replace and test.
+type RagPatchOperation struct {
+	Op    string      `json:"op" binding:"required"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// RagGroupSchema describes a group of a tense section: a text, an object of
+// named entries (one per verb or rule), or a text or single entry
+type RagGroupSchema struct {
+	Type  string `json:"type"`            // text, entries or text_or_entry
+	Entry string `json:"entry,omitempty"` // Shape of the entries of an entries group: text, object or text_or_object
+	// Fields are the fields of object entries, RequiredFields the ones they must have
+	Fields         []string `json:"fields,omitempty"`
+	RequiredFields []string `json:"required_fields,omitempty"`
+	Required       bool     `json:"required,omitempty"` // Every section must have the group
+	// Forms tells that the text lists the forms or endings of the six persons,
+	// comma separated, in the languages the rule-based conjugator reads
+	Forms       bool   `json:"forms,omitempty"`
+	Description string `json:"description"`
+}
+
+// RagSchemaResponse describes the RAG knowledge document of a language: one
+// section per tense, each made of the groups listed
+type RagSchemaResponse struct {
+	Language string                    `json:"language"`
+	Sections []string                  `json:"sections"`
+	Groups   map[string]RagGroupSchema `json:"groups"`
+}
+
+// RagChange is one difference between two versions of a RAG knowledge
+// document: a value added, removed or changed at Path. Objects are compared
+// member by member, so Path names the innermost value that differs.
+type RagChange struct {
+	Type string      `json:"type"` // added, removed or changed
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// RagDiffSummary counts the changes between two versions of a RAG knowledge document
+type RagDiffSummary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// RagDiffResponse represents the structural diff between two versions of the
+// RAG knowledge of a language. Versions are named live, file or a backup key.
+type RagDiffResponse struct {
+	Language string         `json:"language"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Summary  RagDiffSummary `json:"summary"`
+	Changes  []RagChange    `json:"changes"`
+}
+
+// RagKnowledgeChange records an admin change of the live RAG knowledge of a
+// language: who made it, how, and what it changed
+type RagKnowledgeChange struct {
+	ID       string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Language string `json:"language" gorm:"type:varchar(8);not null;index"`
+	Action   string `json:"action" gorm:"type:varchar(16);not null"` // update, patch, restore or reload
+	Source   string `json:"source,omitempty"`                        // Backup key of a restore
+	UserID   string `json:"user_id" gorm:"type:varchar(36);index"`
+	// FromVersion and ToVersion are the short hashes of the knowledge before and after
+	FromVersion string    `json:"from_version" gorm:"type:varchar(16)"`
+	ToVersion   string    `json:"to_version" gorm:"type:varchar(16)"`
+	Added       int       `json:"added"`
+	Removed     int       `json:"removed"`
+	Changed     int       `json:"changed"`
+	Summary     string    `json:"summary" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName specifies the table name for RagKnowledgeChange
+func (RagKnowledgeChange) TableName() string {
+	return "rag_knowledge_changes"
+}
+
+// BeforeCreate generates a UUID for the change before creating it
+func (c *RagKnowledgeChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -35,15 +35,44 @@ type AddItemRequest struct {
 	ParentCategory string           `json:"parentCategory" binding:"required"`
 }
 
-// ConjugateRequest represents the conjugation request payload
+// MoveItemRequest represents the move item request payload
+type MoveItemRequest struct {
+	// ParentCategory is the page to move the item to; empty keeps its current page
+	ParentCategory string `json:"parentCategory"`
+	// Position is the zero-based index on the page; omitted or out of range appends
+	Position *int `json:"position"`
+}
+
+// ReorderItemsRequest represents the batch reorder request payload. Each page lists
+// the IDs of all its items in their new order; an item listed under another page
+// than its current one is moved there.
+type ReorderItemsRequest struct {
+	Categories map[string][]string `json:"categories" binding:"required"`
+}
+
+// ConjugateRequest represents the conjugation request payload.
+// Language overrides the user's preferred language for this request.
+// Tense is one of the language's tenses listed by /tenses and defaults to its
+// first one (presente in Italian).
+// Detailed asks for a ConjugateResponse instead of the plain verb to form map.
 type ConjugateRequest struct {
 	Sentence  string   `json:"sentence"`
 	Words     []string `json:"words"`
 	BaseForms []string `json:"base_forms"`
+	Language  string   `json:"language"`
 	Tense     string   `json:"tense"`
+	Detailed  bool     `json:"detailed"`
 }
 
-// CorrectRequest represents the correction request payload
+// CorrectRequest represents the correction request payload. Language overrides
+// the user's preferred language for this request.
 type CorrectRequest struct {
 	Sentence string `json:"sentence"`
+	Language string `json:"language"`
+}
+
+// SetLanguageRequest represents the payload to set the user's preferred
+// language; an empty language goes back to the default
+type SetLanguageRequest struct {
+	Language string `json:"language"`
 }
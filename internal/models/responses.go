@@ -52,12 +52,54 @@ type SuccessResponse struct {
 	Message string `json:"message"`
 }
 
-// ConjugateResponse represents the conjugation response
+// ConjugateResponse represents the detailed conjugation response. Sources tells
+// for each verb whether its form came from the model ("model"), the rule-based
+// conjugator ("rules") or was left as requested ("input"); Attempts counts the
+// LLM answers, repairs included (0 when the LLM was unavailable). RagEntries
+// lists the RAG entries injected into the prompt.
 type ConjugateResponse struct {
-	Conjugations interface{} `json:"conjugations"`
+	Language     string            `json:"language"`
+	Conjugations map[string]string `json:"conjugations"`
+	Sources      map[string]string `json:"sources"`
+	Attempts     int               `json:"attempts"`
+	RagEntries   []string          `json:"rag_entries,omitempty"`
 }
 
-// CorrectResponse represents the correction response
+// CorrectResponse represents the correction response. Source is "model" when the
+// LLM corrected the sentence and "input" when it is returned unchanged.
 type CorrectResponse struct {
-	Correction string `json:"correction"`
+	Language          string `json:"language"`
+	CorrectedSentence string `json:"corrected_sentence"`
+	Source            string `json:"source"`
+	Attempts          int    `json:"attempts"`
+}
+
+// TenseInfo describes a tense the conjugation endpoint accepts
+type TenseInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// TensesResponse represents the list of tenses supported in a language
+type TensesResponse struct {
+	Language string      `json:"language"`
+	Tenses   []TenseInfo `json:"tenses"`
+}
+
+// LanguageInfo describes a language of the AI language services and ARASAAC searches
+type LanguageInfo struct {
+	Code   string      `json:"code"` // ISO 639-1 code
+	Name   string      `json:"name"`
+	Tenses []TenseInfo `json:"tenses"`
+}
+
+// LanguagesResponse represents the supported languages and the user's choice.
+// Language is the one used for the user's requests: their preference, or the
+// default when Preferred is empty.
+type LanguagesResponse struct {
+	Languages []LanguageInfo `json:"languages"`
+	Default   string         `json:"default"`
+	Preferred string         `json:"preferred"`
+	Language  string         `json:"language"`
 }
@@ -19,6 +19,8 @@ type User struct {
 	EditorPassword string    `json:"-" gorm:"column:editor_password"`
 	Status         string    `json:"status" gorm:"default:pending_setup;not null"`
 	IsActive       bool      `json:"is_active" gorm:"default:true"`
+	ActiveBoardID  *string   `json:"active_board_id,omitempty" gorm:"type:varchar(36)"`
+	Language       string    `json:"language" gorm:"type:varchar(8)"` // Preferred language; empty uses the default
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 
@@ -0,0 +1,10 @@
+// Package prompts holds the default prompt templates. They are embedded in the
+// binary and seed the prompt_templates table, where admins edit them.
+package prompts
+
+import "embed"
+
+// Files holds the default templates, one <language>/<name>.tmpl per prompt
+//
+//go:embed */*.tmpl
+var Files embed.FS
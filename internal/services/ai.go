@@ -1,9 +1,9 @@
 package services
 
 import (
+	"context"
 	"log"
 
-	"github.com/daniele/web-app-caa/internal/config"
 	"github.com/daniele/web-app-caa/internal/models"
 )
 
@@ -12,10 +12,10 @@ type AIService struct {
 	llmService *LLMService // LLM service for direct template usage
 }
 
-// NewAIService creates a new AIService
-func NewAIService(cfg *config.Config) *AIService {
+// NewAIService creates a new AIService on top of the shared LLM service
+func NewAIService(llmService *LLMService) *AIService {
 	service := &AIService{
-		llmService: NewLLMService(cfg),
+		llmService: llmService,
 	}
 
 	log.Printf("AIService initialized with direct LLM integration")
@@ -23,13 +23,40 @@ func NewAIService(cfg *config.Config) *AIService {
 }
 
 // Conjugate sends a conjugation request to the LLM service
-func (s *AIService) Conjugate(req models.ConjugateRequest) (map[string]interface{}, error) {
+func (s *AIService) Conjugate(ctx context.Context, req models.ConjugateRequest) (*models.ConjugateResponse, error) {
 	log.Printf("Using direct LLM service for conjugation")
-	return s.llmService.ConjugateWithTemplates(req)
+	return s.llmService.ConjugateWithTemplates(ctx, req)
+}
+
+// ConjugateStream sends a conjugation request to the LLM service, streaming the answer to onDelta
+func (s *AIService) ConjugateStream(ctx context.Context, req models.ConjugateRequest, onDelta func(string) error) (*models.ConjugateResponse, error) {
+	log.Printf("Using direct LLM service for streaming conjugation")
+	return s.llmService.ConjugateStream(ctx, req, onDelta)
+}
+
+// CorrectStream sends a correction request to the LLM service, streaming the answer to onDelta
+func (s *AIService) CorrectStream(ctx context.Context, req models.CorrectRequest, onDelta func(string) error) (*models.CorrectResponse, error) {
+	log.Printf("Using direct LLM service for streaming correction")
+	return s.llmService.CorrectStream(ctx, req, onDelta)
+}
+
+// CacheStats returns the statistics of the result cache
+func (s *AIService) CacheStats() models.LLMCacheStatsResponse {
+	return s.llmService.CacheStats()
+}
+
+// PurgeCache drops all cached conjugation and correction results
+func (s *AIService) PurgeCache() {
+	s.llmService.PurgeCache()
+}
+
+// Tenses lists the tenses available for conjugation in a language
+func (s *AIService) Tenses(language string) []models.TenseInfo {
+	return s.llmService.SupportedTenses(language)
 }
 
 // Correct sends a correction request to the LLM service
-func (s *AIService) Correct(req models.CorrectRequest) (map[string]interface{}, error) {
+func (s *AIService) Correct(ctx context.Context, req models.CorrectRequest) (*models.CorrectResponse, error) {
 	log.Printf("Using direct LLM service for correction")
-	return s.llmService.CorrectWithTemplate(req)
+	return s.llmService.CorrectWithTemplate(ctx, req)
 }
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// usageDayFormat is the layout of AIUsage.Day
+const usageDayFormat = "2006-01-02"
+
+// topUsersLimit is the number of users listed by the usage report
+const topUsersLimit = 20
+
+// LLMUsage accumulates the LLM work done while serving one request. It travels
+// in the request context so the LLM service can meter calls it makes deep down.
+type LLMUsage struct {
+	mu               sync.Mutex
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Provider         string
+	Model            string
+	Cached           bool
+}
+
+type llmUsageKey struct{}
+
+// WithLLMUsage returns a context whose LLM calls are metered into usage
+func WithLLMUsage(ctx context.Context, usage *LLMUsage) context.Context {
+	return context.WithValue(ctx, llmUsageKey{}, usage)
+}
+
+// llmUsageFrom returns the usage meter of ctx, nil when the request is not metered
+func llmUsageFrom(ctx context.Context) *LLMUsage {
+	usage, _ := ctx.Value(llmUsageKey{}).(*LLMUsage)
+	return usage
+}
+
+// add meters one LLM answer
+func (u *LLMUsage) add(resp *LLMResponse) {
+	if u == nil || resp == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Calls++
+	u.PromptTokens += resp.PromptTokens
+	u.CompletionTokens += resp.CompletionTokens
+	u.Provider = resp.Provider
+	u.Model = resp.Model
+}
+
+// markCached records that the result came from the cache
+func (u *LLMUsage) markCached() {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Cached = true
+}
+
+// AIUsageService records AI endpoint usage, enforces daily quotas and reports usage
+type AIUsageService struct {
+	db          *gorm.DB
+	rbacService *RBACService
+	defaults    config.LLMQuotaConfig
+}
+
+// NewAIUsageService creates a new AI usage service
+func NewAIUsageService(db *gorm.DB, rbacService *RBACService, cfg *config.Config) *AIUsageService {
+	log.Printf("[AI-USAGE] Default daily quota - Requests: %d, Tokens: %d (0 = unlimited)",
+		cfg.LLM.Quota.DailyRequests, cfg.LLM.Quota.DailyTokens)
+	return &AIUsageService{
+		db:          db,
+		rbacService: rbacService,
+		defaults:    cfg.LLM.Quota,
+	}
+}
+
+// Record stores the usage of one AI endpoint call
+func (s *AIUsageService) Record(userID, endpoint string, usage *LLMUsage, latency time.Duration, status int) {
+	usage.mu.Lock()
+	record := models.AIUsage{
+		UserID:           userID,
+		Day:              time.Now().Format(usageDayFormat),
+		Endpoint:         endpoint,
+		Provider:         usage.Provider,
+		Model:            usage.Model,
+		LLMCalls:         usage.Calls,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cached:           usage.Cached,
+		LatencyMs:        latency.Milliseconds(),
+		Status:           status,
+	}
+	usage.mu.Unlock()
+
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Printf("[AI-USAGE] Error recording usage of user %s: %v", userID, err)
+	}
+}
+
+// QuotaStatus returns the user's usage of the current day against their quota.
+// Only requests that reached the LLM count; cache hits and answers of the rules
+// alone are free.
+func (s *AIUsageService) QuotaStatus(userID string) (*models.AIQuotaStatusResponse, error) {
+	now := time.Now()
+	status := &models.AIQuotaStatusResponse{
+		UserID:   userID,
+		Day:      now.Format(usageDayFormat),
+		ResetsAt: nextDay(now).Format(time.RFC3339),
+	}
+
+	var err error
+	status.RequestLimit, status.TokenLimit, status.LimitSource, err = s.quotaLimits(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var used struct {
+		Requests int64
+		Tokens   int64
+	}
+	if err := s.db.Model(&models.AIUsage{}).
+		Select("COUNT(*) AS requests, COALESCE(SUM(prompt_tokens + completion_tokens), 0) AS tokens").
+		Where("user_id = ? AND day = ? AND llm_calls > 0", userID, status.Day).
+		Scan(&used).Error; err != nil {
+		return nil, fmt.Errorf("failed to count AI usage: %w", err)
+	}
+	status.Requests = used.Requests
+	status.Tokens = used.Tokens
+
+	status.Exceeded = (status.RequestLimit > 0 && status.Requests >= int64(status.RequestLimit)) ||
+		(status.TokenLimit > 0 && status.Tokens >= int64(status.TokenLimit))
+	return status, nil
+}
+
+// quotaLimits resolves the daily limits of a user: their own quota, else the most
+// generous quota of their roles, else the configured default
+func (s *AIUsageService) quotaLimits(userID string) (int, int, string, error) {
+	var quota models.AIQuota
+	err := s.db.Where("scope = ? AND subject = ?", models.AIQuotaScopeUser, userID).First(&quota).Error
+	if err == nil {
+		return quota.DailyRequests, quota.DailyTokens, models.AIQuotaScopeUser, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, "", fmt.Errorf("failed to get user quota: %w", err)
+	}
+
+	roles, err := s.rbacService.GetUserRoles(userID)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	roleNames := make([]string, len(roles))
+	for i, role := range roles {
+		roleNames[i] = role.Name
+	}
+
+	var roleQuotas []models.AIQuota
+	if len(roleNames) > 0 {
+		if err := s.db.Where("scope = ? AND subject IN ?", models.AIQuotaScopeRole, roleNames).
+			Order("subject").Find(&roleQuotas).Error; err != nil {
+			return 0, 0, "", fmt.Errorf("failed to get role quotas: %w", err)
+		}
+	}
+	if len(roleQuotas) == 0 {
+		return s.defaults.DailyRequests, s.defaults.DailyTokens, "default", nil
+	}
+
+	requests, tokens := roleQuotas[0].DailyRequests, roleQuotas[0].DailyTokens
+	sources := make([]string, len(roleQuotas))
+	for i, roleQuota := range roleQuotas {
+		requests = moreGenerousLimit(requests, roleQuota.DailyRequests)
+		tokens = moreGenerousLimit(tokens, roleQuota.DailyTokens)
+		sources[i] = models.AIQuotaScopeRole + ":" + roleQuota.Subject
+	}
+	return requests, tokens, strings.Join(sources, ","), nil
+}
+
+// moreGenerousLimit returns the higher of two limits, 0 being unlimited
+func moreGenerousLimit(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// nextDay returns the start of the day after t, when daily quotas reset
+func nextDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
+
+// ListQuotas returns every user and role quota
+func (s *AIUsageService) ListQuotas() ([]models.AIQuota, error) {
+	var quotas []models.AIQuota
+	if err := s.db.Order("scope, subject").Find(&quotas).Error; err != nil {
+		return nil, fmt.Errorf("failed to list AI quotas: %w", err)
+	}
+	return quotas, nil
+}
+
+// SetQuota creates or replaces the quota of a user or role
+func (s *AIUsageService) SetQuota(req models.SetAIQuotaRequest) (*models.AIQuota, error) {
+	switch req.Scope {
+	case models.AIQuotaScopeUser:
+		if err := s.db.Where("id = ?", req.Subject).First(&models.User{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("user not found")
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+	case models.AIQuotaScopeRole:
+		if err := s.db.Where("name = ?", req.Subject).First(&models.Role{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("role not found")
+			}
+			return nil, fmt.Errorf("failed to get role: %w", err)
+		}
+	}
+
+	quota := models.AIQuota{
+		Scope:         req.Scope,
+		Subject:       req.Subject,
+		DailyRequests: req.DailyRequests,
+		DailyTokens:   req.DailyTokens,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"daily_requests", "daily_tokens", "updated_at"}),
+	}).Create(&quota).Error; err != nil {
+		return nil, fmt.Errorf("failed to save AI quota: %w", err)
+	}
+
+	log.Printf("[AI-USAGE] Quota of %s %s set - Requests: %d, Tokens: %d", quota.Scope, quota.Subject, quota.DailyRequests, quota.DailyTokens)
+	return &quota, nil
+}
+
+// DeleteQuota removes the quota of a user or role
+func (s *AIUsageService) DeleteQuota(scope, subject string) error {
+	result := s.db.Where("scope = ? AND subject = ?", scope, subject).Delete(&models.AIQuota{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete AI quota: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("quota not found")
+	}
+
+	log.Printf("[AI-USAGE] Quota of %s %s deleted", scope, subject)
+	return nil
+}
+
+// usageGroupRow is one row of a grouped usage query
+type usageGroupRow struct {
+	GroupKey         string
+	Requests         int64
+	CachedRequests   int64
+	LLMCalls         int64
+	PromptTokens     int64
+	CompletionTokens int64
+	AverageLatencyMs float64
+}
+
+// usageTotalsSelect aggregates the usage columns of a group
+const usageTotalsSelect = "COUNT(*) AS requests, " +
+	"COALESCE(SUM(CASE WHEN cached THEN 1 ELSE 0 END), 0) AS cached_requests, " +
+	"COALESCE(SUM(llm_calls), 0) AS llm_calls, " +
+	"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+	"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+	"COALESCE(AVG(latency_ms), 0) AS average_latency_ms"
+
+// Report summarizes the AI usage of the last days, today included, for every
+// user or for one user when userID is set
+func (s *AIUsageService) Report(days int, userID string) (*models.AIUsageReportResponse, error) {
+	now := time.Now()
+	report := &models.AIUsageReportResponse{
+		From:   now.AddDate(0, 0, 1-days).Format(usageDayFormat),
+		To:     now.Format(usageDayFormat),
+		UserID: userID,
+	}
+
+	scope := func() *gorm.DB {
+		query := s.db.Model(&models.AIUsage{}).Where("day >= ?", report.From)
+		if userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		return query
+	}
+
+	var totals usageGroupRow
+	if err := scope().Select(usageTotalsSelect).Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum AI usage: %w", err)
+	}
+	report.Totals = totals.toTotals()
+
+	var err error
+	if report.ByDay, err = s.groupUsage(scope(), "day", "day"); err != nil {
+		return nil, err
+	}
+	if report.ByEndpoint, err = s.groupUsage(scope(), "endpoint", "requests DESC"); err != nil {
+		return nil, err
+	}
+	if report.ByModel, err = s.groupUsage(scope(), "model", "requests DESC"); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		if report.TopUsers, err = s.groupUsage(scope().Limit(topUsersLimit), "user_id",
+			"SUM(prompt_tokens + completion_tokens) DESC, requests DESC"); err != nil {
+			return nil, err
+		}
+		if err := s.addUsernames(report.TopUsers); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// groupUsage sums the usage of query grouped by a column
+func (s *AIUsageService) groupUsage(query *gorm.DB, column, order string) ([]models.AIUsageGroup, error) {
+	var rows []usageGroupRow
+	if err := query.Select(column + " AS group_key, " + usageTotalsSelect).
+		Group(column).Order(order).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to group AI usage by %s: %w", column, err)
+	}
+
+	groups := make([]models.AIUsageGroup, len(rows))
+	for i, row := range rows {
+		groups[i] = models.AIUsageGroup{Key: row.GroupKey, AIUsageTotals: row.toTotals()}
+	}
+	return groups, nil
+}
+
+// addUsernames fills in the usernames of usage grouped by user
+func (s *AIUsageService) addUsernames(groups []models.AIUsageGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	ids := make([]string, len(groups))
+	for i, group := range groups {
+		ids[i] = group.Key
+	}
+
+	var users []models.User
+	if err := s.db.Select("id, username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to get usernames: %w", err)
+	}
+	usernames := make(map[string]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+	for i := range groups {
+		groups[i].Username = usernames[groups[i].Key]
+	}
+	return nil
+}
+
+// toTotals converts a grouped row to usage totals
+func (r usageGroupRow) toTotals() models.AIUsageTotals {
+	return models.AIUsageTotals{
+		Requests:         r.Requests,
+		CachedRequests:   r.CachedRequests,
+		LLMCalls:         r.LLMCalls,
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		TotalTokens:      r.PromptTokens + r.CompletionTokens,
+		AverageLatencyMs: r.AverageLatencyMs,
+	}
+}
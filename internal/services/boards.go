@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrBoardNotFound is returned when a board does not exist or belongs to another user
+	ErrBoardNotFound = errors.New("board not found")
+	// ErrDefaultBoard is returned when trying to delete the user's default board
+	ErrDefaultBoard = errors.New("the default board cannot be deleted")
+	// ErrBoardNameRequired is returned when a board is given an empty name
+	ErrBoardNameRequired = errors.New("board name is required")
+)
+
+// DefaultBoard returns the user's default board, creating it on first use
+func (s *GridService) DefaultBoard(userID string) (*models.Board, error) {
+	return database.EnsureDefaultBoard(database.DB, userID)
+}
+
+// GetBoard returns one of the user's boards
+func (s *GridService) GetBoard(userID, boardID string) (*models.Board, error) {
+	var board models.Board
+	if err := database.DB.Where("id = ? AND user_id = ?", boardID, userID).First(&board).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBoardNotFound
+		}
+		return nil, err
+	}
+	return &board, nil
+}
+
+// ListBoards returns the user's boards, default board first
+func (s *GridService) ListBoards(userID string) ([]models.BoardResponse, error) {
+	log.Printf("Listing boards for user ID: %s", userID)
+
+	active, err := s.ActiveBoard(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var boards []models.Board
+	if err := database.DB.Where("user_id = ?", userID).
+		Order("is_default DESC, created_at ASC").
+		Find(&boards).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		BoardID string
+		Count   int
+	}
+	if err := database.DB.Model(&models.GridItem{}).
+		Select("board_id, COUNT(*) as count").
+		Where("user_id = ?", userID).
+		Group("board_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	itemCounts := make(map[string]int, len(counts))
+	for _, c := range counts {
+		itemCounts[c.BoardID] = c.Count
+	}
+
+	response := make([]models.BoardResponse, 0, len(boards))
+	for _, board := range boards {
+		response = append(response, toBoardResponse(board, board.ID == active.ID, itemCounts[board.ID]))
+	}
+	return response, nil
+}
+
+// DescribeBoard returns the API representation of a single board
+func (s *GridService) DescribeBoard(board *models.Board) (*models.BoardResponse, error) {
+	active, err := s.ActiveBoard(board.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var itemCount int64
+	if err := database.DB.Model(&models.GridItem{}).Where("board_id = ?", board.ID).Count(&itemCount).Error; err != nil {
+		return nil, err
+	}
+
+	response := toBoardResponse(*board, board.ID == active.ID, int(itemCount))
+	return &response, nil
+}
+
+// CreateBoard creates a new board with an empty home page and the standard system controls
+func (s *GridService) CreateBoard(userID, name string) (*models.Board, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrBoardNameRequired
+	}
+
+	log.Printf("Creating board %q for user ID: %s", name, userID)
+
+	// Make sure the default board exists before any other board does
+	if _, err := s.DefaultBoard(userID); err != nil {
+		return nil, err
+	}
+
+	board := models.Board{UserID: userID, Name: name}
+	if err := database.DB.Create(&board).Error; err != nil {
+		return nil, err
+	}
+
+	initialGrid := map[string][]models.GridItemResponse{
+		"home":           {},
+		"systemControls": GetDefaultGrid(s.cfg)["systemControls"],
+	}
+	if _, err := s.saveGrid(initialGrid, &board, AnyRevision, "create_board", fmt.Sprintf("Created board %q", name)); err != nil {
+		database.DB.Delete(&board)
+		return nil, err
+	}
+
+	log.Printf("Board created: %s", board.ID)
+	return &board, nil
+}
+
+// RenameBoard changes the name of one of the user's boards
+func (s *GridService) RenameBoard(board *models.Board, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrBoardNameRequired
+	}
+
+	log.Printf("Renaming board %s to %q", board.ID, name)
+	if err := database.DB.Model(board).Update("name", name).Error; err != nil {
+		return err
+	}
+	board.Name = name
+	return nil
+}
+
+// DeleteBoard deletes a board with its items, versions and trash. If it was the user's
+// active board, the default board becomes active again.
+func (s *GridService) DeleteBoard(board *models.Board) error {
+	if board.IsDefault {
+		return ErrDefaultBoard
+	}
+
+	log.Printf("Deleting board %s of user ID: %s", board.ID, board.UserID)
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.GridItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.GridVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.GridTrashEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).
+			Where("id = ? AND active_board_id = ?", board.UserID, board.ID).
+			Update("active_board_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(board).Error
+	})
+}
+
+// ActiveBoard returns the board the user last switched to, or the default board
+func (s *GridService) ActiveBoard(userID string) (*models.Board, error) {
+	var user models.User
+	if err := database.DB.Select("id, active_board_id").Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	if user.ActiveBoardID != nil {
+		board, err := s.GetBoard(userID, *user.ActiveBoardID)
+		if err == nil {
+			return board, nil
+		}
+		if !errors.Is(err, ErrBoardNotFound) {
+			return nil, err
+		}
+	}
+
+	return s.DefaultBoard(userID)
+}
+
+// SetActiveBoard makes one of the user's boards the active one
+func (s *GridService) SetActiveBoard(userID, boardID string) (*models.Board, error) {
+	board, err := s.GetBoard(userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Setting active board %s for user ID: %s", boardID, userID)
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("active_board_id", boardID).Error; err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// toBoardResponse converts a board into its response representation
+func toBoardResponse(board models.Board, isActive bool, itemCount int) models.BoardResponse {
+	return models.BoardResponse{
+		ID:        board.ID,
+		Name:      board.Name,
+		IsDefault: board.IsDefault,
+		IsActive:  isActive,
+		ItemCount: itemCount,
+		CreatedAt: board.CreatedAt,
+		UpdatedAt: board.UpdatedAt,
+	}
+}
+
+// GetBoardByID returns any user's board, for administration
+func (s *GridService) GetBoardByID(boardID string) (*models.Board, error) {
+	var board models.Board
+	if err := database.DB.Where("id = ?", boardID).First(&board).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBoardNotFound
+		}
+		return nil, err
+	}
+	return &board, nil
+}
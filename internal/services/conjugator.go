@@ -0,0 +1,639 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tenses supported by the conjugation engine
+const (
+	TensePresente   = "presente"
+	TensePassato    = "passato"
+	TenseImperfetto = "imperfetto"
+	TenseFuturo     = "futuro"
+)
+
+// Persons of an Italian conjugation, in the order the RAG knowledge lists them
+const (
+	PersonIo = iota
+	PersonTu
+	PersonLui
+	PersonNoi
+	PersonVoi
+	PersonLoro
+)
+
+// Subject is the grammatical subject found in a sentence
+type Subject struct {
+	Person   int
+	Feminine bool
+}
+
+// subjectPronouns maps subject pronouns to their subject
+var subjectPronouns = map[string]Subject{
+	"io":   {Person: PersonIo},
+	"tu":   {Person: PersonTu},
+	"lui":  {Person: PersonLui},
+	"egli": {Person: PersonLui},
+	"lei":  {Person: PersonLui, Feminine: true},
+	"ella": {Person: PersonLui, Feminine: true},
+	"noi":  {Person: PersonNoi},
+	"voi":  {Person: PersonVoi},
+	"loro": {Person: PersonLoro},
+	"essi": {Person: PersonLoro},
+	"esse": {Person: PersonLoro, Feminine: true},
+}
+
+// reflexivePronouns are the pronouns of reflexive verbs by person
+var reflexivePronouns = [6]string{"mi", "ti", "si", "ci", "vi", "si"}
+
+// Default knowledge, used for anything the RAG knowledge does not provide
+var (
+	defaultPresenteEndings = map[string][6]string{
+		"are": {"o", "i", "a", "iamo", "ate", "ano"},
+		"ere": {"o", "i", "e", "iamo", "ete", "ono"},
+		"ire": {"o", "i", "e", "iamo", "ite", "ono"},
+		"isc": {"isco", "isci", "isce", "iamo", "ite", "iscono"},
+	}
+	defaultImperfettoEndings = map[string][6]string{
+		"are": {"avo", "avi", "ava", "avamo", "avate", "avano"},
+		"ere": {"evo", "evi", "eva", "evamo", "evate", "evano"},
+		"ire": {"ivo", "ivi", "iva", "ivamo", "ivate", "ivano"},
+	}
+	defaultFuturoEndings = [6]string{"ò", "ai", "à", "emo", "ete", "anno"}
+	defaultParticiples   = map[string]string{"are": "ato", "ere": "uto", "ire": "ito"}
+	defaultAuxiliaries   = map[string][6]string{
+		"essere": {"sono", "sei", "è", "siamo", "siete", "sono"},
+		"avere":  {"ho", "hai", "ha", "abbiamo", "avete", "hanno"},
+	}
+	defaultIscVerbs = []string{"finire", "capire", "preferire", "pulire", "costruire", "spedire", "colpire", "gestire", "suggerire", "unire", "restituire", "tradire", "impedire", "obbedire", "ubbidire", "sparire", "fornire", "definire", "condire", "guarire", "arrossire", "dimagrire", "ingrandire", "stupire", "favorire", "agire", "reagire", "contribuire", "distribuire"}
+	// defaultEssereVerbs take essere as auxiliary in the passato prossimo
+	defaultEssereVerbs = []string{"essere", "stare", "andare", "venire", "arrivare", "partire", "tornare", "ritornare", "uscire", "entrare", "rimanere", "restare", "diventare", "nascere", "morire", "cadere", "salire", "scendere", "piacere", "sembrare", "succedere", "accadere", "riuscire", "costare", "durare", "bastare", "crescere", "fuggire", "giungere", "mancare"}
+)
+
+// irregularVerbs lists common verbs that do not follow the regular pattern in a
+// tense. Unless the RAG knowledge provides their forms, the engine does not
+// guess them.
+var irregularVerbs = map[string][]string{
+	TensePresente:   {"essere", "avere", "andare", "fare", "dare", "stare", "dire", "bere", "venire", "tenere", "volere", "potere", "dovere", "sapere", "uscire", "rimanere", "porre", "tradurre", "condurre", "produrre", "ridurre", "morire", "salire", "scegliere", "togliere", "cogliere", "sedere", "spegnere", "piacere", "tacere", "apparire", "parere", "valere", "trarre", "udire", "cuocere", "riuscire"},
+	TenseImperfetto: {"essere", "fare", "dire", "bere", "porre", "tradurre", "condurre", "produrre", "ridurre", "trarre"},
+	TenseFuturo:     {"andare", "avere", "essere", "dare", "fare", "stare", "vedere", "potere", "dovere", "sapere", "vivere", "venire", "volere", "tenere", "rimanere", "bere", "cadere", "valere", "parere", "morire", "porre", "tradurre", "condurre", "produrre", "ridurre", "trarre", "udire"},
+	TensePassato:    {"essere", "fare", "dire", "leggere", "scrivere", "vedere", "prendere", "mettere", "aprire", "chiudere", "venire", "rimanere", "chiedere", "rispondere", "bere", "correre", "decidere", "morire", "nascere", "offrire", "perdere", "piangere", "ridere", "rompere", "scegliere", "scendere", "spegnere", "spendere", "vincere", "vivere", "accendere", "apparire", "dipingere", "muovere", "piacere", "porre", "tradurre", "condurre", "produrre", "ridurre", "trarre", "succedere", "cuocere", "coprire", "soffrire", "discutere", "togliere", "cogliere", "giungere", "raggiungere", "spingere", "stringere", "dividere", "difendere", "esprimere", "correggere", "proteggere", "distruggere", "uccidere", "mordere", "nascondere", "tingere", "valere", "parere", "essere"},
+}
+
+var (
+	// wordPattern splits a sentence into words, keeping accented letters
+	wordPattern = regexp.MustCompile(`[\p{L}']+`)
+	// parenthesesPattern finds the verb examples of a rule, e.g. "(andare, venire)"
+	parenthesesPattern = regexp.MustCompile(`\(([^)]*)\)`)
+)
+
+// Conjugator conjugates Italian verbs using the rules and irregular forms of the
+// RAG knowledge, completed with built-in defaults for the regular patterns
+type Conjugator struct {
+	presenteEndings   map[string][6]string
+	imperfettoEndings map[string][6]string
+	futuroEndings     [6]string
+	participles       map[string]string
+
+	presenteIrregular   map[string][6]string
+	imperfettoIrregular map[string][6]string
+	futuroRoots         map[string]string
+	irregularParticiple map[string]string
+
+	iscVerbs    map[string]bool
+	essereVerbs map[string]bool
+	irregular   map[string]map[string]bool
+}
+
+// NewConjugator builds a conjugator from RAG knowledge; nil knowledge leaves only
+// the built-in regular patterns
+func NewConjugator(ragData map[string]interface{}) *Conjugator {
+	c := &Conjugator{
+		presenteEndings:     make(map[string][6]string),
+		imperfettoEndings:   make(map[string][6]string),
+		futuroEndings:       defaultFuturoEndings,
+		participles:         make(map[string]string),
+		presenteIrregular:   make(map[string][6]string),
+		imperfettoIrregular: make(map[string][6]string),
+		futuroRoots:         make(map[string]string),
+		irregularParticiple: make(map[string]string),
+		iscVerbs:            make(map[string]bool),
+		essereVerbs:         make(map[string]bool),
+		irregular:           make(map[string]map[string]bool),
+	}
+	for class, endings := range defaultPresenteEndings {
+		c.presenteEndings[class] = endings
+	}
+	for class, endings := range defaultImperfettoEndings {
+		c.imperfettoEndings[class] = endings
+	}
+	for class, ending := range defaultParticiples {
+		c.participles[class] = ending
+	}
+	for verb, forms := range defaultAuxiliaries {
+		c.presenteIrregular[verb] = forms
+	}
+	for _, verb := range defaultIscVerbs {
+		c.iscVerbs[verb] = true
+	}
+	for _, verb := range defaultEssereVerbs {
+		c.essereVerbs[verb] = true
+	}
+	for tense, verbs := range irregularVerbs {
+		c.irregular[tense] = make(map[string]bool, len(verbs))
+		for _, verb := range verbs {
+			c.irregular[tense][verb] = true
+		}
+	}
+
+	c.loadPresente(ragSection(ragData, "presente_indicativo"))
+	c.loadPassato(ragSection(ragData, "passato_prossimo"))
+	c.loadImperfetto(ragSection(ragData, "imperfetto"))
+	c.loadFuturo(ragSection(ragData, "futuro_semplice"))
+	return c
+}
+
+// DetectSubject finds the first subject pronoun in a sentence
+func DetectSubject(sentence string) (Subject, bool) {
+	for _, word := range wordPattern.FindAllString(strings.ToLower(sentence), -1) {
+		if subject, ok := subjectPronouns[word]; ok {
+			return subject, true
+		}
+	}
+	return Subject{}, false
+}
+
+// ConjugateSentence conjugates each verb for the subject of the sentence. Verbs
+// are left in the infinitive when the sentence has no subject pronoun or the
+// engine does not know their forms; the second result lists those verbs.
+func (c *Conjugator) ConjugateSentence(sentence string, verbs []string, tense string) (map[string]interface{}, []string) {
+	result := make(map[string]interface{}, len(verbs))
+	var unknown []string
+
+	subject, ok := DetectSubject(sentence)
+	for _, verb := range verbs {
+		if !ok {
+			result[verb] = verb
+			continue
+		}
+		form, known := c.Conjugate(verb, tense, subject)
+		if !known {
+			result[verb] = verb
+			unknown = append(unknown, verb)
+			continue
+		}
+		result[verb] = form
+	}
+	return result, unknown
+}
+
+// Conjugate returns the form of an infinitive for a tense and subject. It reports
+// false when the verb is irregular in that tense and its forms are not known.
+func (c *Conjugator) Conjugate(verb, tense string, subject Subject) (string, bool) {
+	infinitive := strings.ToLower(strings.TrimSpace(verb))
+	if subject.Person < PersonIo || subject.Person > PersonLoro {
+		return "", false
+	}
+
+	// Reflexive verbs (lavarsi) are conjugated like their base verb (lavare)
+	// with a reflexive pronoun and essere in compound tenses
+	reflexive := false
+	if base, ok := reflexiveBase(infinitive); ok {
+		infinitive = base
+		reflexive = true
+	}
+
+	var form string
+	var known bool
+	switch tense {
+	case TensePresente:
+		form, known = c.presente(infinitive, subject.Person)
+	case TenseImperfetto:
+		form, known = c.imperfetto(infinitive, subject.Person)
+	case TenseFuturo:
+		form, known = c.futuro(infinitive, subject.Person)
+	case TensePassato:
+		form, known = c.passato(infinitive, subject, reflexive)
+	}
+	if !known {
+		return "", false
+	}
+
+	if reflexive {
+		form = reflexivePronouns[subject.Person] + " " + form
+	}
+	return form, true
+}
+
+// Matches reports whether form is a correct conjugation of verb. In the passato
+// prossimo the auxiliary and the participle's agreement are not checked, as the
+// choice depends on meaning the engine cannot see. The second result is false
+// when the engine does not know the verb's forms and cannot judge.
+func (c *Conjugator) Matches(verb, tense string, subject Subject, form string) (bool, bool) {
+	expected, known := c.Conjugate(verb, tense, subject)
+	if !known {
+		return false, false
+	}
+
+	form = strings.ToLower(strings.Join(strings.Fields(form), " "))
+	if form == expected {
+		return true, true
+	}
+	if tense != TensePassato {
+		return false, true
+	}
+
+	// Compare the participle without its agreement ending
+	expectedWords := strings.Fields(expected)
+	formWords := strings.Fields(form)
+	if len(formWords) < 2 || len(formWords) != len(expectedWords) {
+		return false, true
+	}
+	participleStem := func(participle string) string {
+		return participle[:len(participle)-1]
+	}
+	return participleStem(formWords[len(formWords)-1]) == participleStem(expectedWords[len(expectedWords)-1]), true
+}
+
+// presente conjugates the presente indicativo
+func (c *Conjugator) presente(verb string, person int) (string, bool) {
+	if forms, ok := c.presenteIrregular[verb]; ok {
+		return forms[person], true
+	}
+	if c.irregular[TensePresente][verb] {
+		return "", false
+	}
+
+	class, stem, ok := verbClass(verb)
+	if !ok {
+		return "", false
+	}
+	if class == "ire" && c.iscVerbs[verb] {
+		class = "isc"
+	}
+	return joinStem(stem, c.presenteEndings[class][person]), true
+}
+
+// imperfetto conjugates the imperfetto indicativo
+func (c *Conjugator) imperfetto(verb string, person int) (string, bool) {
+	if forms, ok := c.imperfettoIrregular[verb]; ok {
+		return forms[person], true
+	}
+	if c.irregular[TenseImperfetto][verb] {
+		return "", false
+	}
+
+	class, stem, ok := verbClass(verb)
+	if !ok {
+		return "", false
+	}
+	return stem + c.imperfettoEndings[class][person], true
+}
+
+// futuro conjugates the futuro semplice
+func (c *Conjugator) futuro(verb string, person int) (string, bool) {
+	if root, ok := c.futuroRoots[verb]; ok {
+		return root + c.futuroEndings[person], true
+	}
+	if c.irregular[TenseFuturo][verb] {
+		return "", false
+	}
+
+	class, stem, ok := verbClass(verb)
+	if !ok {
+		return "", false
+	}
+
+	var root string
+	switch {
+	case class != "are":
+		root = strings.TrimSuffix(verb, "e")
+	case strings.HasSuffix(stem, "c") || strings.HasSuffix(stem, "g"):
+		// cercare -> cercherò, pagare -> pagherò
+		root = stem + "her"
+	case strings.HasSuffix(stem, "ci") || strings.HasSuffix(stem, "gi"):
+		// mangiare -> mangerò, cominciare -> comincerò
+		root = strings.TrimSuffix(stem, "i") + "er"
+	default:
+		root = stem + "er"
+	}
+	return root + c.futuroEndings[person], true
+}
+
+// passato conjugates the passato prossimo: auxiliary plus past participle, which
+// agrees with the subject when the auxiliary is essere
+func (c *Conjugator) passato(verb string, subject Subject, reflexive bool) (string, bool) {
+	participle, ok := c.participle(verb)
+	if !ok {
+		return "", false
+	}
+
+	auxiliary := "avere"
+	if reflexive || c.essereVerbs[verb] {
+		auxiliary = "essere"
+		participle = agreeParticiple(participle, subject)
+	}
+	return c.presenteIrregular[auxiliary][subject.Person] + " " + participle, true
+}
+
+// participle returns the past participle of an infinitive
+func (c *Conjugator) participle(verb string) (string, bool) {
+	if participle, ok := c.irregularParticiple[verb]; ok {
+		return participle, true
+	}
+	if c.irregular[TensePassato][verb] {
+		return "", false
+	}
+
+	class, stem, ok := verbClass(verb)
+	if !ok {
+		return "", false
+	}
+	return stem + c.participles[class], true
+}
+
+// loadPresente reads regular endings, -isc- verbs and irregular verbs of the presente
+func (c *Conjugator) loadPresente(section map[string]interface{}) {
+	if rules, ok := section["general_rules"].(map[string]interface{}); ok {
+		for name, value := range rules {
+			rule, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			class := ruleClass(name)
+			if class == "" {
+				continue
+			}
+			if strings.Contains(name, "isc") {
+				class = "isc"
+			}
+			if conjugation, ok := rule["conjugation"].(string); ok {
+				if endings, ok := endingsFromModel(exampleVerb(name), conjugation); ok {
+					c.presenteEndings[class] = endings
+				}
+			}
+			if notes, ok := rule["notes"].(string); ok && class == "isc" {
+				if _, list, found := strings.Cut(notes, ":"); found {
+					for _, verb := range splitList(list) {
+						c.iscVerbs[verb] = true
+					}
+				}
+				c.iscVerbs[exampleVerb(name)] = true
+			}
+		}
+	}
+
+	c.loadIrregularForms(section["irregular_verbs"], c.presenteIrregular)
+}
+
+// loadPassato reads participles and verbs taking essere of the passato prossimo
+func (c *Conjugator) loadPassato(section map[string]interface{}) {
+	if rule, ok := section["regular_participles"].(string); ok {
+		// "-are -> -ato; -ere -> -uto; -ire -> -ito"
+		for _, part := range strings.Split(rule, ";") {
+			from, to, found := strings.Cut(part, "->")
+			if !found {
+				continue
+			}
+			class := ruleClass(from)
+			ending := strings.TrimLeft(strings.TrimSpace(to), "-")
+			if class != "" && ending != "" {
+				c.participles[class] = ending
+			}
+		}
+	}
+
+	if participles, ok := section["irregular_participles"].(map[string]interface{}); ok {
+		for verb, value := range participles {
+			if participle, ok := value.(string); ok {
+				c.irregularParticiple[strings.ToLower(verb)] = strings.ToLower(strings.TrimSpace(participle))
+			}
+		}
+	}
+
+	if choice, ok := section["auxiliary_choice"].(map[string]interface{}); ok {
+		if essere, ok := choice["essere"].(map[string]interface{}); ok {
+			if rule, ok := essere["rule"].(string); ok {
+				for _, match := range parenthesesPattern.FindAllStringSubmatch(rule, -1) {
+					for _, verb := range splitList(match[1]) {
+						c.essereVerbs[verb] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// loadImperfetto reads regular endings and irregular verbs of the imperfetto
+func (c *Conjugator) loadImperfetto(section map[string]interface{}) {
+	if rules, ok := section["regular_endings"].(map[string]interface{}); ok {
+		for name, value := range rules {
+			rule, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			class := ruleClass(name)
+			if endings, ok := rule["endings"].(string); ok && class != "" {
+				if parsed, ok := parseEndings(endings); ok {
+					c.imperfettoEndings[class] = parsed
+				}
+			}
+		}
+	}
+
+	c.loadIrregularForms(section["irregular_verbs"], c.imperfettoIrregular)
+}
+
+// loadFuturo reads endings and irregular roots of the futuro semplice
+func (c *Conjugator) loadFuturo(section map[string]interface{}) {
+	if endings, ok := section["endings"].(string); ok {
+		if parsed, ok := parseEndings(endings); ok {
+			c.futuroEndings = parsed
+		}
+	}
+
+	if roots, ok := section["irregular_roots"].(map[string]interface{}); ok {
+		for verb, value := range roots {
+			root, ok := value.(string)
+			if !ok {
+				continue
+			}
+			// "sar- (es. io sarò, noi saremo)" -> "sar"
+			root, _, _ = strings.Cut(root, "-")
+			if root = strings.ToLower(strings.TrimSpace(root)); root != "" {
+				c.futuroRoots[strings.ToLower(verb)] = root
+			}
+		}
+	}
+}
+
+// loadIrregularForms reads irregular verbs listed as "Essere (to be)" with either
+// a conjugation string or an object holding one
+func (c *Conjugator) loadIrregularForms(value interface{}, into map[string][6]string) {
+	verbs, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, details := range verbs {
+		conjugation, ok := details.(string)
+		if !ok {
+			if detailsMap, isMap := details.(map[string]interface{}); isMap {
+				conjugation, ok = detailsMap["conjugation"].(string)
+			}
+		}
+		if !ok {
+			continue
+		}
+		if forms, ok := parseForms(conjugation); ok {
+			verb, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(name)), " ")
+			into[verb] = forms
+		}
+	}
+}
+
+// ragSection returns a top level object of the RAG knowledge
+func ragSection(ragData map[string]interface{}, name string) map[string]interface{} {
+	section, _ := ragData[name].(map[string]interface{})
+	return section
+}
+
+// verbClass returns the conjugation class (are, ere, ire) and stem of an infinitive
+func verbClass(verb string) (string, string, bool) {
+	for _, class := range []string{"are", "ere", "ire"} {
+		if stem, ok := strings.CutSuffix(verb, class); ok && stem != "" {
+			return class, stem, true
+		}
+	}
+	return "", "", false
+}
+
+// reflexiveBase returns the base verb of a reflexive infinitive (lavarsi -> lavare)
+func reflexiveBase(verb string) (string, bool) {
+	stem, ok := strings.CutSuffix(verb, "rsi")
+	if !ok || stem == "" {
+		return "", false
+	}
+	return stem + "re", true
+}
+
+// joinStem appends a presente ending, keeping the hard sound of -care/-gare verbs
+// (cerchi) and avoiding a doubled i for -iare verbs (mangi)
+func joinStem(stem, ending string) string {
+	if strings.HasPrefix(ending, "i") {
+		switch {
+		case strings.HasSuffix(stem, "c") || strings.HasSuffix(stem, "g"):
+			if !strings.HasPrefix(ending, "isc") {
+				return stem + "h" + ending
+			}
+		case strings.HasSuffix(stem, "i"):
+			return stem + strings.TrimPrefix(ending, "i")
+		}
+	}
+	return stem + ending
+}
+
+// agreeParticiple makes a participle agree with the subject (andato, andata, andati, andate)
+func agreeParticiple(participle string, subject Subject) string {
+	stem, ok := strings.CutSuffix(participle, "o")
+	if !ok {
+		return participle
+	}
+	plural := subject.Person >= PersonNoi
+	switch {
+	case plural && subject.Feminine:
+		return stem + "e"
+	case plural:
+		return stem + "i"
+	case subject.Feminine:
+		return stem + "a"
+	}
+	return participle
+}
+
+// ruleClass extracts the conjugation class from a rule name such as "-are verbs (parlare)"
+func ruleClass(name string) string {
+	name = strings.TrimSpace(name)
+	for _, class := range []string{"are", "ere", "ire"} {
+		if strings.HasPrefix(name, "-"+class) {
+			return class
+		}
+	}
+	return ""
+}
+
+// exampleVerb extracts the model verb from a rule name such as "-are verbs (parlare)"
+func exampleVerb(name string) string {
+	if match := parenthesesPattern.FindStringSubmatch(name); match != nil {
+		return strings.ToLower(strings.TrimSpace(match[1]))
+	}
+	return ""
+}
+
+// endingsFromModel derives the endings of a class from the conjugation of its model
+// verb, e.g. parlare and "io parlo, tu parli, ..." give o, i, ...
+func endingsFromModel(verb, conjugation string) ([6]string, bool) {
+	var endings [6]string
+	_, stem, ok := verbClass(verb)
+	if !ok {
+		return endings, false
+	}
+	forms, ok := parseForms(conjugation)
+	if !ok {
+		return endings, false
+	}
+	for i, form := range forms {
+		ending, ok := strings.CutPrefix(form, stem)
+		if !ok {
+			return endings, false
+		}
+		endings[i] = ending
+	}
+	return endings, true
+}
+
+// parseForms splits a conjugation such as "io parlo, tu parli, ..." or
+// "sono, sei, ..." into its six forms without pronouns
+func parseForms(conjugation string) ([6]string, bool) {
+	var forms [6]string
+	parts := strings.Split(conjugation, ",")
+	if len(parts) != 6 {
+		return forms, false
+	}
+	for i, part := range parts {
+		words := strings.Fields(strings.ToLower(part))
+		if len(words) == 0 {
+			return forms, false
+		}
+		forms[i] = words[len(words)-1]
+	}
+	return forms, true
+}
+
+// parseEndings splits endings such as "-avo, -avi, ..." into six endings
+func parseEndings(value string) ([6]string, bool) {
+	var endings [6]string
+	parts := strings.Split(value, ",")
+	if len(parts) != 6 {
+		return endings, false
+	}
+	for i, part := range parts {
+		endings[i] = strings.TrimLeft(strings.TrimSpace(part), "-")
+		if endings[i] == "" {
+			return endings, false
+		}
+	}
+	return endings, true
+}
+
+// splitList splits a comma separated list of verbs, dropping punctuation
+func splitList(value string) []string {
+	var verbs []string
+	for _, part := range strings.Split(value, ",") {
+		verb := strings.ToLower(strings.Trim(strings.TrimSpace(part), ".;: "))
+		if verb != "" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
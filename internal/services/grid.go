@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
 
+	"github.com/daniele/web-app-caa/internal/config"
 	"github.com/daniele/web-app-caa/internal/database"
 	"github.com/daniele/web-app-caa/internal/models"
 	"github.com/google/uuid"
@@ -13,22 +15,49 @@ import (
 )
 
 // GridService handles grid-related operations
-type GridService struct{}
+type GridService struct {
+	cfg    *config.Config
+	events GridEventBroker
+	images *ImageService
+}
 
-// NewGridService creates a new GridService
-func NewGridService() *GridService {
-	return &GridService{}
+// NewGridService creates a new GridService that publishes changes to events and
+// stores uploaded symbol images with images
+func NewGridService(cfg *config.Config, events GridEventBroker, images *ImageService) *GridService {
+	return &GridService{cfg: cfg, events: events, images: images}
 }
 
-// SaveGrid saves the entire grid for a user
-func (s *GridService) SaveGrid(gridData map[string][]models.GridItemResponse, userID string) error {
-	log.Printf("Saving grid for user ID: %s", userID)
+// SubscribeEvents subscribes to change events for the user's grid
+func (s *GridService) SubscribeEvents(userID string) (<-chan models.GridEvent, func()) {
+	return s.events.Subscribe(userID)
+}
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		// Delete existing grid items for the user
-		log.Printf("Deleting existing grid items for user ID: %s", userID)
-		if err := tx.Where("user_id = ?", userID).Delete(&models.GridItem{}).Error; err != nil {
-			return err
+// SaveGrid saves the entire grid of a board if it is still at expectedRevision
+// (or AnyRevision) and returns the new revision
+func (s *GridService) SaveGrid(gridData map[string][]models.GridItemResponse, board *models.Board, expectedRevision int) (int, error) {
+	return s.saveGrid(gridData, board, expectedRevision, "save", "")
+}
+
+// saveGrid replaces the board's grid and records a version with the given action and summary
+func (s *GridService) saveGrid(gridData map[string][]models.GridItemResponse, board *models.Board, expectedRevision int, action, summary string) (int, error) {
+	log.Printf("Saving grid for board %s of user ID: %s", board.ID, board.UserID)
+
+	// Reject structurally invalid grids before anything is stored
+	if errs, _ := validateGrid(gridData); len(errs) > 0 {
+		log.Printf("Rejecting invalid grid for board %s: %d problems", board.ID, len(errs))
+		return 0, &GridValidationError{Problems: errs}
+	}
+
+	// Store uploaded images before the transaction so it is not held open during uploads
+	if err := s.storeIcons(gridData, board.UserID); err != nil {
+		return 0, err
+	}
+
+	return s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		// Delete existing grid items of the board
+		log.Printf("Deleting existing grid items for board: %s", board.ID)
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.GridItem{}).Error; err != nil {
+			return "", "", err
 		}
 
 		totalItems := 0
@@ -38,28 +67,15 @@ func (s *GridService) SaveGrid(gridData map[string][]models.GridItemResponse, us
 			log.Printf("Processing category: %s with %d items", categoryKey, len(items))
 
 			for index, item := range items {
-				// Process image if it's a base64 data URL
-				iconData := item.Icon
-				if strings.HasPrefix(iconData, "data:image/") {
-					log.Printf("Processing image for item: %s", item.ID)
-					// Process image (placeholder for now - would implement actual image processing)
-					optimizedIcon, err := s.processImage(iconData)
-					if err != nil {
-						log.Printf("Error processing image for item %s: %v", item.ID, err)
-						// Continue with original icon on error
-					} else {
-						iconData = optimizedIcon
-					}
-				}
-
 				gridItem := models.GridItem{
 					ID:             item.ID,
-					UserID:         userID,
+					UserID:         board.UserID,
+					BoardID:        board.ID,
 					ParentCategory: categoryKey,
 					ItemOrder:      index,
 					Type:           item.Type,
 					Label:          item.Label,
-					Icon:           iconData,
+					Icon:           item.Icon,
 					Color:          item.Color,
 					Target:         item.Target,
 					Text:           item.Text,
@@ -71,65 +87,60 @@ func (s *GridService) SaveGrid(gridData map[string][]models.GridItemResponse, us
 				}
 
 				if err := tx.Create(&gridItem).Error; err != nil {
-					return err
+					return "", "", err
 				}
 				totalItems++
 			}
 		}
 
 		log.Printf("Total items saved: %d", totalItems)
-		return nil
+
+		if summary == "" {
+			summary = fmt.Sprintf("Saved grid with %d items", totalItems)
+		}
+		return action, summary, nil
 	})
 }
 
-// GetGrid retrieves the grid for a user
-func (s *GridService) GetGrid(userID string) (map[string][]models.GridItemResponse, error) {
-	log.Printf("Getting grid for user ID: %s", userID)
+// GetGrid retrieves the grid of a board
+func (s *GridService) GetGrid(board *models.Board) (map[string][]models.GridItemResponse, error) {
+	return s.getGrid(database.DB, board)
+}
 
-	var items []models.GridItem
-	result := database.DB.Where("user_id = ?", userID).
-		Order("parent_category ASC, item_order ASC").
-		Find(&items)
+// GetGridWithRevision retrieves the grid of a board together with the revision it was read at
+func (s *GridService) GetGridWithRevision(board *models.Board) (map[string][]models.GridItemResponse, int, error) {
+	var gridData map[string][]models.GridItemResponse
+	var revision int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if gridData, err = s.getGrid(tx, board); err != nil {
+			return err
+		}
+		revision, err = s.currentRevision(tx, board.ID)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return gridData, revision, nil
+}
+
+// getGrid reads the board's grid within tx, adding empty pages for category targets
+func (s *GridService) getGrid(tx *gorm.DB, board *models.Board) (map[string][]models.GridItemResponse, error) {
+	log.Printf("Getting grid for board %s of user ID: %s", board.ID, board.UserID)
 
-	if result.Error != nil {
-		log.Printf("Error getting grid: %v", result.Error)
-		return nil, result.Error
+	gridData, err := s.readGrid(tx, board.ID)
+	if err != nil {
+		log.Printf("Error getting grid: %v", err)
+		return nil, err
 	}
 
-	if len(items) == 0 {
+	if len(gridData) == 0 {
 		log.Printf("No grid items found for user")
 		return nil, nil
 	}
 
-	log.Printf("Found %d grid items", len(items))
-	gridData := make(map[string][]models.GridItemResponse)
-
-	// First pass: populate gridData with items from the database
-	for _, item := range items {
-		if _, exists := gridData[item.ParentCategory]; !exists {
-			gridData[item.ParentCategory] = []models.GridItemResponse{}
-			log.Printf("Initialized category: %s", item.ParentCategory)
-		}
-
-		gridItemResponse := models.GridItemResponse{
-			ID:         item.ID,
-			Type:       item.Type,
-			Label:      item.Label,
-			Icon:       item.Icon,
-			Color:      item.Color,
-			Target:     item.Target,
-			Text:       item.Text,
-			Speak:      item.Speak,
-			Action:     item.Action,
-			IsVisible:  item.IsVisible,
-			SymbolType: item.SymbolType,
-			IsHideable: item.IsHideable,
-		}
-
-		gridData[item.ParentCategory] = append(gridData[item.ParentCategory], gridItemResponse)
-	}
-
-	// Second pass: ensure every category target has an array, even if it's empty
+	// Ensure every category target has an array, even if it's empty
 	for _, categoryItems := range gridData {
 		for _, item := range categoryItems {
 			if item.Type == "category" && item.Target != "" {
@@ -145,62 +156,102 @@ func (s *GridService) GetGrid(userID string) (map[string][]models.GridItemRespon
 	return gridData, nil
 }
 
-// AddItem adds a new item to the grid
-func (s *GridService) AddItem(itemData models.GridItemResponse, parentCategory string, userID string) (*models.GridItemResponse, error) {
-	log.Printf("Adding item to category %s for user %s", parentCategory, userID)
+// readGrid loads the board's stored grid items grouped by parent category, in display order
+func (s *GridService) readGrid(tx *gorm.DB, boardID string) (map[string][]models.GridItemResponse, error) {
+	var items []models.GridItem
+	if err := tx.Where("board_id = ?", boardID).
+		Order("parent_category ASC, item_order ASC").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	gridData := make(map[string][]models.GridItemResponse)
+	for _, item := range items {
+		gridData[item.ParentCategory] = append(gridData[item.ParentCategory], toGridItemResponse(item))
+	}
+
+	return gridData, nil
+}
+
+// toGridItemResponse converts a stored grid item into its response representation
+func toGridItemResponse(item models.GridItem) models.GridItemResponse {
+	return models.GridItemResponse{
+		ID:         item.ID,
+		Type:       item.Type,
+		Label:      item.Label,
+		Icon:       item.Icon,
+		Color:      item.Color,
+		Target:     item.Target,
+		Text:       item.Text,
+		Speak:      item.Speak,
+		Action:     item.Action,
+		IsVisible:  item.IsVisible,
+		SymbolType: item.SymbolType,
+		IsHideable: item.IsHideable,
+	}
+}
+
+// AddItem adds a new item to the board's grid and returns it with the new revision
+func (s *GridService) AddItem(itemData models.GridItemResponse, parentCategory string, board *models.Board, expectedRevision int) (*models.GridItemResponse, int, error) {
+	log.Printf("Adding item to category %s of board %s for user %s", parentCategory, board.ID, board.UserID)
 
 	// Generate a new UUID for the item - backend controls all IDs
 	newID := uuid.New().String()
 	log.Printf("Generated new UUID: %s", newID)
 
-	// Process image if needed
-	iconData := itemData.Icon
-	if strings.HasPrefix(iconData, "data:image/") {
-		log.Printf("Processing image for new item")
-		optimizedIcon, err := s.processImage(iconData)
+	// Store an uploaded image and keep only its URL
+	iconData, err := s.storeIcon(board.UserID, itemData.Icon)
+	if err != nil {
+		log.Printf("Error processing image: %v", err)
+		return nil, 0, err
+	}
+
+	revision, err := s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		// Get max order for the category
+		var maxOrder int
+		err := tx.Model(&models.GridItem{}).
+			Where("parent_category = ? AND board_id = ?", parentCategory, board.ID).
+			Select("COALESCE(MAX(item_order), -1) as max_order").
+			Row().Scan(&maxOrder)
 		if err != nil {
-			log.Printf("Error processing image: %v", err)
-			// Continue with original icon
-		} else {
-			iconData = optimizedIcon
+			log.Printf("Warning: failed to get max order, using default: %v", err)
+			maxOrder = -1
 		}
-	}
 
-	// Get max order for the category
-	var maxOrder int
-	err := database.DB.Model(&models.GridItem{}).
-		Where("parent_category = ? AND user_id = ?", parentCategory, userID).
-		Select("COALESCE(MAX(item_order), -1) as max_order").
-		Row().Scan(&maxOrder)
-	if err != nil {
-		log.Printf("Warning: failed to get max order, using default: %v", err)
-		maxOrder = -1
-	}
+		newOrder := maxOrder + 1
+		log.Printf("New item order: %d", newOrder)
+
+		gridItem := models.GridItem{
+			ID:             newID, // Use the backend-generated UUID
+			UserID:         board.UserID,
+			BoardID:        board.ID,
+			ParentCategory: parentCategory,
+			ItemOrder:      newOrder,
+			Type:           itemData.Type,
+			Label:          itemData.Label,
+			Icon:           iconData,
+			Color:          itemData.Color,
+			Target:         itemData.Target,
+			Text:           itemData.Text,
+			Speak:          itemData.Speak,
+			Action:         itemData.Action,
+			IsVisible:      itemData.IsVisible,
+			SymbolType:     itemData.SymbolType,
+			IsHideable:     itemData.IsHideable,
+		}
 
-	newOrder := maxOrder + 1
-	log.Printf("New item order: %d", newOrder)
-
-	gridItem := models.GridItem{
-		ID:             newID, // Use the backend-generated UUID
-		UserID:         userID,
-		ParentCategory: parentCategory,
-		ItemOrder:      newOrder,
-		Type:           itemData.Type,
-		Label:          itemData.Label,
-		Icon:           iconData,
-		Color:          itemData.Color,
-		Target:         itemData.Target,
-		Text:           itemData.Text,
-		Speak:          itemData.Speak,
-		Action:         itemData.Action,
-		IsVisible:      itemData.IsVisible,
-		SymbolType:     itemData.SymbolType,
-		IsHideable:     itemData.IsHideable,
-	}
+		if err := tx.Create(&gridItem).Error; err != nil {
+			return "", "", err
+		}
+		if err := s.validateStoredItems(tx, board.ID, newID); err != nil {
+			return "", "", err
+		}
 
-	if err := database.DB.Create(&gridItem).Error; err != nil {
+		return "add_item", fmt.Sprintf("Added item %q to %s", itemData.Label, parentCategory), nil
+	})
+	if err != nil {
 		log.Printf("Error adding item: %v", err)
-		return nil, err
+		return nil, 0, err
 	}
 
 	log.Printf("Item added successfully with UUID: %s", newID)
@@ -220,23 +271,18 @@ func (s *GridService) AddItem(itemData models.GridItemResponse, parentCategory s
 		IsHideable: itemData.IsHideable,
 	}
 
-	return &response, nil
+	return &response, revision, nil
 }
 
-// UpdateItem updates an existing grid item
-func (s *GridService) UpdateItem(itemID string, itemData models.GridItemResponse, userID string) error {
-	log.Printf("Updating item %s for user %s", itemID, userID)
+// UpdateItem updates an existing item of the board and returns the new revision
+func (s *GridService) UpdateItem(itemID string, itemData models.GridItemResponse, board *models.Board, expectedRevision int) (int, error) {
+	log.Printf("Updating item %s of board %s for user %s", itemID, board.ID, board.UserID)
 
-	// Process image if needed
-	iconData := itemData.Icon
-	if strings.HasPrefix(iconData, "data:image/") {
-		log.Printf("Processing image for item update")
-		optimizedIcon, err := s.processImage(iconData)
-		if err != nil {
-			log.Printf("Error processing image: %v", err)
-		} else {
-			iconData = optimizedIcon
-		}
+	// Store an uploaded image and keep only its URL
+	iconData, err := s.storeIcon(board.UserID, itemData.Icon)
+	if err != nil {
+		log.Printf("Error processing image: %v", err)
+		return 0, err
 	}
 
 	updates := map[string]interface{}{}
@@ -270,71 +316,66 @@ func (s *GridService) UpdateItem(itemID string, itemData models.GridItemResponse
 
 	if len(updates) == 0 {
 		log.Printf("No fields to update for item")
-		return fmt.Errorf("no fields to update")
+		return 0, fmt.Errorf("no fields to update")
 	}
 
 	log.Printf("Updating fields: %v", getKeys(updates))
 
-	result := database.DB.Model(&models.GridItem{}).
-		Where("id = ? AND user_id = ?", itemID, userID).
-		Updates(updates)
+	var rowsAffected int64
+	revision, err := s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		result := tx.Model(&models.GridItem{}).
+			Where("id = ? AND board_id = ?", itemID, board.ID).
+			Updates(updates)
 
-	if result.Error != nil {
-		log.Printf("Error updating item: %v", result.Error)
-		return result.Error
-	}
-
-	if result.RowsAffected == 0 {
-		log.Printf("Item not found or user not authorized for update")
-		return fmt.Errorf("item not found or user not authorized")
-	}
-
-	log.Printf("Item updated successfully, changes: %d", result.RowsAffected)
-	return nil
-}
-
-// DeleteItem deletes an item
-func (s *GridService) DeleteItem(itemID string, userID string) error {
-	log.Printf("Deleting item %s for user %s", itemID, userID)
+		if result.Error != nil {
+			return "", "", result.Error
+		}
 
-	result := database.DB.Where("id = ? AND user_id = ?", itemID, userID).Delete(&models.GridItem{})
+		if result.RowsAffected == 0 {
+			log.Printf("Item not found or user not authorized for update")
+			return "", "", fmt.Errorf("item not found or user not authorized")
+		}
+		rowsAffected = result.RowsAffected
 
-	if result.Error != nil {
-		log.Printf("Error deleting item: %v", result.Error)
-		return result.Error
-	}
+		if err := s.validateStoredItems(tx, board.ID, itemID); err != nil {
+			return "", "", err
+		}
 
-	if result.RowsAffected == 0 {
-		log.Printf("Item not found or user not authorized for deletion")
-		return fmt.Errorf("item not found or user not authorized")
+		return "update_item", fmt.Sprintf("Updated item %s", itemID), nil
+	})
+	if err != nil {
+		log.Printf("Error updating item: %v", err)
+		return 0, err
 	}
 
-	log.Printf("Item deleted successfully, changes: %d", result.RowsAffected)
-	return nil
+	log.Printf("Item updated successfully, changes: %d", rowsAffected)
+	return revision, nil
 }
 
-// DeleteCategoryContents deletes all items in a category
-func (s *GridService) DeleteCategoryContents(categoryTarget string, userID string) error {
-	log.Printf("Deleting contents of category %s for user %s", categoryTarget, userID)
-
-	result := database.DB.Where("parent_category = ? AND user_id = ?", categoryTarget, userID).Delete(&models.GridItem{})
-
-	if result.Error != nil {
-		log.Printf("Error deleting category contents: %v", result.Error)
-		return result.Error
+// storeIcon replaces a data URL icon with the URL of the processed, stored image.
+// Other icons (ARASAAC and already stored image URLs) are returned unchanged.
+func (s *GridService) storeIcon(userID, icon string) (string, error) {
+	if !strings.HasPrefix(icon, "data:") {
+		return icon, nil
 	}
 
-	log.Printf("Category contents deleted, changes: %d", result.RowsAffected)
-	return nil
+	log.Printf("Processing uploaded image for user ID: %s", userID)
+	return s.images.StoreDataURL(context.Background(), userID, icon)
 }
 
-// processImage processes base64 image data (placeholder implementation)
-func (s *GridService) processImage(base64String string) (string, error) {
-	// This is a placeholder for image processing logic
-	// In the original Node.js version, this used Sharp to resize and convert to WebP
-	// For now, we'll return the original image
-	log.Printf("Image processing placeholder - returning original")
-	return base64String, nil
+// storeIcons stores the data URL icons of a grid, replacing them in place with image URLs
+func (s *GridService) storeIcons(gridData map[string][]models.GridItemResponse, userID string) error {
+	for _, items := range gridData {
+		for i := range items {
+			icon, err := s.storeIcon(userID, items[i].Icon)
+			if err != nil {
+				log.Printf("Error processing image for item %s: %v", items[i].ID, err)
+				return err
+			}
+			items[i].Icon = icon
+		}
+	}
+	return nil
 }
 
 // Helper function to get map keys
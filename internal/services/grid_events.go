@@ -0,0 +1,79 @@
+package services
+
+import (
+	"log"
+	"sync"
+
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+// GridEventBroker fans grid change events out to a user's subscribed clients.
+// MemoryGridEventBroker only reaches clients connected to this process; a shared
+// implementation (e.g. backed by Redis pub/sub) can replace it when running
+// several instances.
+type GridEventBroker interface {
+	Publish(event models.GridEvent)
+	Subscribe(userID string) (events <-chan models.GridEvent, unsubscribe func())
+}
+
+// MemoryGridEventBroker is an in-process GridEventBroker
+type MemoryGridEventBroker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan models.GridEvent]struct{}
+	bufferSize  int
+}
+
+// NewMemoryGridEventBroker creates an in-process broker whose subscriptions
+// buffer up to bufferSize events
+func NewMemoryGridEventBroker(bufferSize int) *MemoryGridEventBroker {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &MemoryGridEventBroker{
+		subscribers: make(map[string]map[chan models.GridEvent]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish delivers the event to every subscription of its user. Subscribers whose
+// buffer is full miss the event; they detect the gap from the revision numbers.
+func (b *MemoryGridEventBroker) Publish(event models.GridEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("[GRID-EVENTS] Dropping event for slow subscriber of user %s (revision %d)", event.UserID, event.Revision)
+		}
+	}
+}
+
+// Subscribe registers a subscription for the user's events. The returned function
+// must be called to release it; it closes the channel.
+func (b *MemoryGridEventBroker) Subscribe(userID string) (<-chan models.GridEvent, func()) {
+	ch := make(chan models.GridEvent, b.bufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan models.GridEvent]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGridItemNotFound is returned when an item does not exist on the board
+	ErrGridItemNotFound = errors.New("item not found")
+	// ErrInvalidOrder is returned when a reorder request does not match the board's items
+	ErrInvalidOrder = errors.New("invalid item order")
+)
+
+// MoveItem moves an item to position on parentCategory (its current page when empty),
+// renumbering the items of the source and destination pages. A nil or out of range
+// position appends the item. It returns the new revision.
+func (s *GridService) MoveItem(itemID, parentCategory string, position *int, board *models.Board, expectedRevision int) (int, error) {
+	log.Printf("Moving item %s of board %s for user %s", itemID, board.ID, board.UserID)
+
+	return s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		var item models.GridItem
+		if err := tx.Where("id = ? AND board_id = ?", itemID, board.ID).First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", "", ErrGridItemNotFound
+			}
+			return "", "", err
+		}
+
+		source := item.ParentCategory
+		target := parentCategory
+		if target == "" {
+			target = source
+		}
+
+		siblings, err := pageItemIDs(tx, board.ID, target)
+		if err != nil {
+			return "", "", err
+		}
+		siblings = removeID(siblings, itemID)
+
+		index := len(siblings)
+		if position != nil && *position >= 0 && *position < len(siblings) {
+			index = *position
+		}
+		order := make([]string, 0, len(siblings)+1)
+		order = append(order, siblings[:index]...)
+		order = append(order, itemID)
+		order = append(order, siblings[index:]...)
+
+		if err := renumberPage(tx, board.ID, target, order); err != nil {
+			return "", "", err
+		}
+		if source != target {
+			remaining, err := pageItemIDs(tx, board.ID, source)
+			if err != nil {
+				return "", "", err
+			}
+			if err := renumberPage(tx, board.ID, source, removeID(remaining, itemID)); err != nil {
+				return "", "", err
+			}
+		}
+
+		if err := s.validateStoredItems(tx, board.ID, itemID); err != nil {
+			return "", "", err
+		}
+
+		if source == target {
+			return "move_item", fmt.Sprintf("Moved item %q to position %d on %s", item.Label, index, target), nil
+		}
+		return "move_item", fmt.Sprintf("Moved item %q from %s to %s", item.Label, source, target), nil
+	})
+}
+
+// ReorderItems applies a batch of page orders from drag and drop. Every listed page
+// must list all of its current items, except those moved to another listed page.
+// It returns the new revision.
+func (s *GridService) ReorderItems(categories map[string][]string, board *models.Board, expectedRevision int) (int, error) {
+	log.Printf("Reordering %d pages of board %s for user %s", len(categories), board.ID, board.UserID)
+
+	if len(categories) == 0 {
+		return 0, fmt.Errorf("%w: no pages given", ErrInvalidOrder)
+	}
+
+	return s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		var items []models.GridItem
+		if err := tx.Select("id, parent_category, item_order").Where("board_id = ?", board.ID).Find(&items).Error; err != nil {
+			return "", "", err
+		}
+		byID := make(map[string]models.GridItem, len(items))
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+
+		pages := make([]string, 0, len(categories))
+		for page := range categories {
+			pages = append(pages, page)
+		}
+		sort.Strings(pages)
+
+		// Every listed ID must belong to the board and appear only once
+		listed := make(map[string]string)
+		for _, page := range pages {
+			for _, id := range categories[page] {
+				if _, ok := byID[id]; !ok {
+					return "", "", fmt.Errorf("%w: item %s is not on this board", ErrInvalidOrder, id)
+				}
+				if other, dup := listed[id]; dup {
+					return "", "", fmt.Errorf("%w: item %s is listed on both %s and %s", ErrInvalidOrder, id, other, page)
+				}
+				listed[id] = page
+			}
+		}
+
+		// No item of a listed page may be left out
+		for _, item := range items {
+			if _, isListedPage := categories[item.ParentCategory]; isListedPage {
+				if _, ok := listed[item.ID]; !ok {
+					return "", "", fmt.Errorf("%w: item %s of page %s is missing", ErrInvalidOrder, item.ID, item.ParentCategory)
+				}
+			}
+		}
+
+		var moved []string
+		changed := 0
+		for _, page := range pages {
+			for index, id := range categories[page] {
+				item := byID[id]
+				if item.ParentCategory == page && item.ItemOrder == index {
+					continue
+				}
+				if item.ParentCategory != page {
+					moved = append(moved, id)
+				}
+				if err := tx.Model(&models.GridItem{}).
+					Where("id = ? AND board_id = ?", id, board.ID).
+					Updates(map[string]interface{}{"parent_category": page, "item_order": index}).Error; err != nil {
+					return "", "", err
+				}
+				changed++
+			}
+		}
+
+		// Pages that items were moved away from but that were not listed keep gaps;
+		// renumber them so orders stay dense
+		for _, id := range moved {
+			source := byID[id].ParentCategory
+			if _, isListedPage := categories[source]; isListedPage {
+				continue
+			}
+			remaining, err := pageItemIDs(tx, board.ID, source)
+			if err != nil {
+				return "", "", err
+			}
+			if err := renumberPage(tx, board.ID, source, remaining); err != nil {
+				return "", "", err
+			}
+		}
+
+		if len(moved) > 0 {
+			if err := s.validateStoredItems(tx, board.ID, moved...); err != nil {
+				return "", "", err
+			}
+		}
+
+		if changed == 0 {
+			return "", "", nil
+		}
+		return "reorder", fmt.Sprintf("Reordered %d items on %d pages", changed, len(pages)), nil
+	})
+}
+
+// pageItemIDs returns the IDs of a page's items in display order
+func pageItemIDs(tx *gorm.DB, boardID, page string) ([]string, error) {
+	var ids []string
+	err := tx.Model(&models.GridItem{}).
+		Where("board_id = ? AND parent_category = ?", boardID, page).
+		Order("item_order ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// renumberPage assigns consecutive orders to the given items and places them on page
+func renumberPage(tx *gorm.DB, boardID, page string, ids []string) error {
+	for index, id := range ids {
+		if err := tx.Model(&models.GridItem{}).
+			Where("id = ? AND board_id = ?", id, boardID).
+			Updates(map[string]interface{}{"parent_category": page, "item_order": index}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeID returns ids without id
+func removeID(ids []string, id string) []string {
+	kept := make([]string, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGridTemplateNotFound is returned when a grid template does not exist
+	ErrGridTemplateNotFound = errors.New("grid template not found")
+	// ErrGridTemplateSlugTaken is returned when another template already uses a slug
+	ErrGridTemplateSlugTaken = errors.New("grid template slug already in use")
+	// ErrInvalidGridTemplate is returned when a template request is missing data or has a malformed slug
+	ErrInvalidGridTemplate = errors.New("invalid grid template")
+)
+
+// templateSlugPattern restricts slugs to values that are safe to send as a grid type
+var templateSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// SeedGridTemplates stores the built-in starter grids as templates when no
+// template exists yet. Admins may edit or delete them afterwards.
+func SeedGridTemplates(cfg *config.Config) error {
+	var count int64
+	if err := database.DB.Model(&models.GridTemplate{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	defaultGrid := GetDefaultGrid(cfg)
+	arasaacURL := func(id string) string {
+		return fmt.Sprintf("%s/%s", cfg.APIs.ArasaacBaseURL, id)
+	}
+
+	builtins := []struct {
+		slug, name, description, icon string
+		grid                          models.GridResponse
+	}{
+		{"simplified", "Semplificata", "Meno opzioni, ideale per chi si avvicina per la prima volta alla CAA.", arasaacURL("32434"), GetSimplifiedGrid(cfg)},
+		{"default", "Standard", "Una selezione bilanciata di categorie e simboli per iniziare.", arasaacURL("32436"), defaultGrid},
+		{"empty", "Avanzata", "Inizia da zero e costruisci la tua griglia personalizzata.", arasaacURL("3046"), models.GridResponse{
+			"home":           {},
+			"systemControls": defaultGrid["systemControls"],
+		}},
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, builtin := range builtins {
+			slug := builtin.slug
+			template := models.GridTemplate{
+				Slug:        &slug,
+				Name:        builtin.name,
+				Description: builtin.description,
+				Icon:        builtin.icon,
+			}
+			if err := setTemplateGrid(&template, builtin.grid); err != nil {
+				return err
+			}
+			if err := tx.Create(&template).Error; err != nil {
+				return fmt.Errorf("error seeding grid template %s: %w", slug, err)
+			}
+		}
+		log.Printf("[GRID-TEMPLATES] Seeded %d built-in grid templates", len(builtins))
+		return nil
+	})
+}
+
+// ListTemplates returns all grid templates without their grids
+func (s *GridService) ListTemplates() ([]models.GridTemplateResponse, error) {
+	var templates []models.GridTemplate
+	if err := database.DB.Omit("grid").Order("created_at ASC, name ASC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.GridTemplateResponse, len(templates))
+	for i, template := range templates {
+		responses[i] = toGridTemplateResponse(template)
+	}
+	return responses, nil
+}
+
+// GetTemplate returns a grid template with its grid
+func (s *GridService) GetTemplate(templateID string) (*models.GridTemplateResponse, error) {
+	template, err := s.loadTemplate(database.DB.Where("id = ?", templateID))
+	if err != nil {
+		return nil, err
+	}
+
+	response := toGridTemplateResponse(*template)
+	if err := json.Unmarshal([]byte(template.Grid), &response.Grid); err != nil {
+		return nil, fmt.Errorf("error reading grid template: %w", err)
+	}
+	return &response, nil
+}
+
+// CreateTemplate stores a new grid template
+func (s *GridService) CreateTemplate(req models.GridTemplateRequest, createdBy string) (*models.GridTemplateResponse, error) {
+	log.Printf("Creating grid template %q by user ID: %s", req.Name, createdBy)
+
+	if len(req.Grid) == 0 {
+		return nil, fmt.Errorf("%w: grid is required", ErrInvalidGridTemplate)
+	}
+
+	template := models.GridTemplate{CreatedBy: createdBy}
+	if err := s.applyTemplateRequest(&template, req, createdBy); err != nil {
+		return nil, err
+	}
+	if err := createTemplate(&template); err != nil {
+		return nil, err
+	}
+
+	response := toGridTemplateResponse(template)
+	return &response, nil
+}
+
+// UpdateTemplate changes a grid template's details and, when given, its grid
+func (s *GridService) UpdateTemplate(templateID string, req models.GridTemplateRequest, updatedBy string) (*models.GridTemplateResponse, error) {
+	log.Printf("Updating grid template %s by user ID: %s", templateID, updatedBy)
+
+	template, err := s.loadTemplate(database.DB.Where("id = ?", templateID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyTemplateRequest(template, req, updatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Save(template).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrGridTemplateSlugTaken
+		}
+		return nil, err
+	}
+
+	response := toGridTemplateResponse(*template)
+	return &response, nil
+}
+
+// DeleteTemplate deletes a grid template. Grids set up from it are not affected.
+func (s *GridService) DeleteTemplate(templateID string) error {
+	log.Printf("Deleting grid template %s", templateID)
+
+	result := database.DB.Where("id = ?", templateID).Delete(&models.GridTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGridTemplateNotFound
+	}
+	return nil
+}
+
+// SaveBoardAsTemplate stores a copy of the board's current grid as a new template
+func (s *GridService) SaveBoardAsTemplate(board *models.Board, req models.GridTemplateFromBoardRequest, createdBy string) (*models.GridTemplateResponse, error) {
+	log.Printf("Saving board %s of user %s as grid template %q", board.ID, board.UserID, req.Name)
+
+	gridData, err := s.readGrid(database.DB, board.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	template := models.GridTemplate{CreatedBy: createdBy}
+	if err := s.applyTemplateRequest(&template, models.GridTemplateRequest{
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		Icon:        req.Icon,
+		Grid:        gridData,
+	}, createdBy); err != nil {
+		return nil, err
+	}
+	if err := createTemplate(&template); err != nil {
+		return nil, err
+	}
+
+	response := toGridTemplateResponse(template)
+	return &response, nil
+}
+
+// InstantiateTemplate returns a copy of a template's grid, looked up by ID or slug,
+// with fresh item IDs and its own pages so it can be saved to any board
+func (s *GridService) InstantiateTemplate(idOrSlug string) (models.GridResponse, error) {
+	template, err := s.loadTemplate(database.DB.Where("id = ? OR slug = ?", idOrSlug, idOrSlug))
+	if err != nil {
+		return nil, err
+	}
+
+	var gridData models.GridResponse
+	if err := json.Unmarshal([]byte(template.Grid), &gridData); err != nil {
+		return nil, fmt.Errorf("error reading grid template: %w", err)
+	}
+
+	log.Printf("Instantiating grid template %s (%s) with %d items", template.ID, template.Name, template.ItemCount)
+	return instantiateGrid(gridData), nil
+}
+
+// applyTemplateRequest validates a request and copies it onto template. An empty
+// slug or grid keeps the template's current one. Uploaded images in the grid are
+// stored under the ID of the user making the request.
+func (s *GridService) applyTemplateRequest(template *models.GridTemplate, req models.GridTemplateRequest, userID string) error {
+	template.Name = req.Name
+	template.Description = req.Description
+	template.Icon = req.Icon
+	if req.Slug != "" {
+		if !templateSlugPattern.MatchString(req.Slug) {
+			return fmt.Errorf("%w: slug may only contain lowercase letters, digits, '-' and '_'", ErrInvalidGridTemplate)
+		}
+		slug := req.Slug
+		template.Slug = &slug
+	}
+
+	if req.Grid == nil {
+		return nil
+	}
+	if errs, _ := validateGrid(req.Grid); len(errs) > 0 {
+		return &GridValidationError{Problems: errs}
+	}
+	if err := s.storeIcons(req.Grid, userID); err != nil {
+		return err
+	}
+	return setTemplateGrid(template, req.Grid)
+}
+
+// loadTemplate loads the template matched by query
+func (s *GridService) loadTemplate(query *gorm.DB) (*models.GridTemplate, error) {
+	var template models.GridTemplate
+	if err := query.First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGridTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// createTemplate inserts a template, reporting a taken slug as ErrGridTemplateSlugTaken
+func createTemplate(template *models.GridTemplate) error {
+	if err := database.DB.Create(template).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrGridTemplateSlugTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// setTemplateGrid stores gridData on template and updates its item count
+func setTemplateGrid(template *models.GridTemplate, gridData models.GridResponse) error {
+	data, err := json.Marshal(gridData)
+	if err != nil {
+		return fmt.Errorf("error marshaling grid template: %w", err)
+	}
+
+	itemCount := 0
+	for _, items := range gridData {
+		itemCount += len(items)
+	}
+	template.Grid = string(data)
+	template.ItemCount = itemCount
+	return nil
+}
+
+// instantiateGrid copies a grid with new item IDs and new page keys. Category
+// targets are remapped to the new page keys; the root pages keep their names.
+func instantiateGrid(gridData models.GridResponse) models.GridResponse {
+	pages := make(map[string]string)
+	pageKey := func(page string) string {
+		if page == "" || isRootPage(page) {
+			return page
+		}
+		if key, ok := pages[page]; ok {
+			return key
+		}
+		key := uuid.New().String()
+		pages[page] = key
+		return key
+	}
+
+	instance := make(models.GridResponse, len(gridData))
+	for _, page := range sortedPages(gridData) {
+		items := make([]models.GridItemResponse, len(gridData[page]))
+		for i, item := range gridData[page] {
+			item.ID = uuid.New().String()
+			if item.Type == "category" {
+				item.Target = pageKey(item.Target)
+			}
+			items[i] = item
+		}
+		instance[pageKey(page)] = items
+	}
+	return instance
+}
+
+// toGridTemplateResponse converts a stored template into its list representation
+func toGridTemplateResponse(template models.GridTemplate) models.GridTemplateResponse {
+	response := models.GridTemplateResponse{
+		ID:          template.ID,
+		Name:        template.Name,
+		Description: template.Description,
+		Icon:        template.Icon,
+		ItemCount:   template.ItemCount,
+		CreatedAt:   template.CreatedAt,
+		UpdatedAt:   template.UpdatedAt,
+	}
+	if template.Slug != nil {
+		response.Slug = *template.Slug
+	}
+	return response
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTrashEntryNotFound is returned when a trash entry does not exist or has expired
+	ErrTrashEntryNotFound = errors.New("trash entry not found")
+	// ErrTrashConflict is returned when items of a trash entry exist on a board again
+	ErrTrashConflict = errors.New("trashed items already exist")
+)
+
+// DeleteItem moves an item to the board's trash. Deleting a category also trashes
+// the page it opens and, recursively, the pages of the categories on it; pages
+// that another remaining category still opens are kept. Everything removed is
+// recorded as a single version and a single trash entry.
+func (s *GridService) DeleteItem(itemID string, board *models.Board, expectedRevision int) (int, error) {
+	log.Printf("Deleting item %s of board %s for user %s", itemID, board.ID, board.UserID)
+
+	var removed int
+	revision, err := s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		var items []models.GridItem
+		if err := tx.Where("board_id = ?", board.ID).Order("parent_category ASC, item_order ASC").Find(&items).Error; err != nil {
+			return "", "", err
+		}
+
+		subtree := deletedSubtree(items, itemID)
+		if len(subtree) == 0 {
+			log.Printf("Item not found or user not authorized for deletion")
+			return "", "", ErrGridItemNotFound
+		}
+		item := subtree[0]
+
+		ids := make([]string, len(subtree))
+		for i, deleted := range subtree {
+			ids[i] = deleted.ID
+		}
+		if err := tx.Where("board_id = ? AND id IN ?", board.ID, ids).Delete(&models.GridItem{}).Error; err != nil {
+			return "", "", err
+		}
+
+		remaining, err := pageItemIDs(tx, board.ID, item.ParentCategory)
+		if err != nil {
+			return "", "", err
+		}
+		if err := renumberPage(tx, board.ID, item.ParentCategory, remaining); err != nil {
+			return "", "", err
+		}
+
+		if err := s.trashItems(tx, board, subtree); err != nil {
+			return "", "", err
+		}
+		removed = len(subtree)
+
+		summary := fmt.Sprintf("Deleted item %q from %s", item.Label, item.ParentCategory)
+		if len(subtree) > 1 {
+			summary = fmt.Sprintf("%s with %d nested items", summary, len(subtree)-1)
+		}
+		return "delete_item", summary, nil
+	})
+	if err != nil {
+		log.Printf("Error deleting item: %v", err)
+		return 0, err
+	}
+
+	log.Printf("Item moved to trash, changes: %d", removed)
+	return revision, nil
+}
+
+// ListTrash returns the board's unexpired trash entries, newest first
+func (s *GridService) ListTrash(board *models.Board) ([]models.GridTrashEntryResponse, error) {
+	if err := s.pruneTrash(database.DB, board.ID); err != nil {
+		return nil, err
+	}
+
+	var entries []models.GridTrashEntry
+	if err := database.DB.Omit("items").
+		Where("board_id = ?", board.ID).
+		Order("deleted_at DESC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.GridTrashEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = models.GridTrashEntryResponse{
+			ID:             entry.ID,
+			ItemID:         entry.ItemID,
+			Label:          entry.Label,
+			Type:           entry.Type,
+			ParentCategory: entry.ParentCategory,
+			ItemCount:      entry.ItemCount,
+			DeletedAt:      entry.DeletedAt,
+			ExpiresAt:      entry.ExpiresAt,
+		}
+	}
+	return responses, nil
+}
+
+// RestoreTrash puts the items of a trash entry back on the board and removes the
+// entry. The deleted item goes back to its former position on its former page,
+// or at the end when the page has fewer items now; it is appended to home when no
+// category opens its page any more. It returns the page the item was restored to,
+// the number of items restored and the new revision.
+func (s *GridService) RestoreTrash(board *models.Board, entryID string, expectedRevision int) (string, int, int, error) {
+	log.Printf("Restoring trash entry %s of board %s for user %s", entryID, board.ID, board.UserID)
+
+	var parent string
+	var restored int
+	revision, err := s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		entry, err := s.loadTrashEntry(tx, board.ID, entryID)
+		if err != nil {
+			return "", "", err
+		}
+
+		var items []models.GridItem
+		if err := json.Unmarshal([]byte(entry.Items), &items); err != nil {
+			return "", "", fmt.Errorf("error reading trash entry: %w", err)
+		}
+		if len(items) == 0 {
+			return "", "", fmt.Errorf("error reading trash entry: no items")
+		}
+
+		ids := make([]string, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		var existing int64
+		if err := tx.Model(&models.GridItem{}).Where("id IN ?", ids).Count(&existing).Error; err != nil {
+			return "", "", err
+		}
+		if existing > 0 {
+			return "", "", ErrTrashConflict
+		}
+
+		parent = entry.ParentCategory
+		if !isRootPage(parent) {
+			var openers int64
+			if err := tx.Model(&models.GridItem{}).
+				Where("board_id = ? AND type = ? AND target = ?", board.ID, "category", parent).
+				Count(&openers).Error; err != nil {
+				return "", "", err
+			}
+			if openers == 0 {
+				parent = "home"
+			}
+		}
+
+		siblings, err := pageItemIDs(tx, board.ID, parent)
+		if err != nil {
+			return "", "", err
+		}
+		index := len(siblings)
+		if parent == entry.ParentCategory && items[0].ItemOrder >= 0 && items[0].ItemOrder < len(siblings) {
+			index = items[0].ItemOrder
+		}
+		items[0].ParentCategory = parent
+		items[0].ItemOrder = index
+
+		for i := range items {
+			items[i].UserID = board.UserID
+			items[i].BoardID = board.ID
+		}
+		// Select all columns so hidden and non-hideable items keep their flags
+		// instead of falling back to the column defaults
+		if err := tx.Select("*").Omit("User").Create(&items).Error; err != nil {
+			return "", "", err
+		}
+
+		order := make([]string, 0, len(siblings)+1)
+		order = append(order, siblings[:index]...)
+		order = append(order, items[0].ID)
+		order = append(order, siblings[index:]...)
+		if err := renumberPage(tx, board.ID, parent, order); err != nil {
+			return "", "", err
+		}
+
+		if err := s.validateStoredItems(tx, board.ID, ids...); err != nil {
+			return "", "", err
+		}
+
+		if err := tx.Delete(entry).Error; err != nil {
+			return "", "", err
+		}
+		restored = len(items)
+
+		summary := fmt.Sprintf("Restored item %q to %s", entry.Label, parent)
+		if len(items) > 1 {
+			summary = fmt.Sprintf("%s with %d nested items", summary, len(items)-1)
+		}
+		return "restore_trash", summary, nil
+	})
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return parent, restored, revision, nil
+}
+
+// DeleteTrash permanently removes a trash entry
+func (s *GridService) DeleteTrash(board *models.Board, entryID string) error {
+	log.Printf("Deleting trash entry %s of board %s for user %s", entryID, board.ID, board.UserID)
+
+	result := database.DB.Where("id = ? AND board_id = ?", entryID, board.ID).Delete(&models.GridTrashEntry{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTrashEntryNotFound
+	}
+	return nil
+}
+
+// trashItems records deleted items as one trash entry and prunes expired entries
+func (s *GridService) trashItems(tx *gorm.DB, board *models.Board, items []models.GridItem) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("error marshaling trashed items: %w", err)
+	}
+
+	now := time.Now()
+	entry := models.GridTrashEntry{
+		UserID:         board.UserID,
+		BoardID:        board.ID,
+		ItemID:         items[0].ID,
+		Label:          items[0].Label,
+		Type:           items[0].Type,
+		ParentCategory: items[0].ParentCategory,
+		ItemCount:      len(items),
+		Items:          string(data),
+		DeletedAt:      now,
+	}
+	if retention := s.cfg.Grid.TrashRetention; retention > 0 {
+		expiresAt := now.Add(retention)
+		entry.ExpiresAt = &expiresAt
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("error recording trash entry: %w", err)
+	}
+
+	return s.pruneTrash(tx, board.ID)
+}
+
+// loadTrashEntry loads an unexpired trash entry of the board
+func (s *GridService) loadTrashEntry(tx *gorm.DB, boardID, entryID string) (*models.GridTrashEntry, error) {
+	var entry models.GridTrashEntry
+	if err := tx.Where("id = ? AND board_id = ?", entryID, boardID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTrashEntryNotFound
+		}
+		return nil, err
+	}
+	if entry.ExpiresAt != nil && entry.ExpiresAt.Before(time.Now()) {
+		return nil, ErrTrashEntryNotFound
+	}
+	return &entry, nil
+}
+
+// pruneTrash removes the board's expired trash entries
+func (s *GridService) pruneTrash(tx *gorm.DB, boardID string) error {
+	if err := tx.Where("board_id = ? AND expires_at IS NOT NULL AND expires_at < ?", boardID, time.Now()).
+		Delete(&models.GridTrashEntry{}).Error; err != nil {
+		return fmt.Errorf("error pruning grid trash: %w", err)
+	}
+	return nil
+}
+
+// deletedSubtree returns the item with itemID followed by the items of the pages it
+// opens, recursively. A page is only included when no category outside the subtree
+// opens it too. It returns nil when the item does not exist.
+func deletedSubtree(items []models.GridItem, itemID string) []models.GridItem {
+	var subtree []models.GridItem
+	deleted := make(map[string]bool)
+	for _, item := range items {
+		if item.ID == itemID {
+			subtree = append(subtree, item)
+			deleted[item.ID] = true
+		}
+	}
+	if len(subtree) == 0 {
+		return nil
+	}
+
+	// Grow the subtree until no more pages are left without a remaining opener;
+	// a page's last opener may only be added in a later round
+	removedPages := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		openers := make(map[string]bool)
+		for _, item := range items {
+			if !deleted[item.ID] && item.Type == "category" && item.Target != "" {
+				openers[item.Target] = true
+			}
+		}
+		for _, opener := range subtree {
+			page := opener.Target
+			if opener.Type != "category" || page == "" || removedPages[page] || openers[page] || isRootPage(page) {
+				continue
+			}
+			removedPages[page] = true
+			for _, item := range items {
+				if item.ParentCategory == page && !deleted[item.ID] {
+					subtree = append(subtree, item)
+					deleted[item.ID] = true
+					changed = true
+				}
+			}
+		}
+	}
+	return subtree
+}
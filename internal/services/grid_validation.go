@@ -0,0 +1,433 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidGrid is returned when a grid write would leave the grid structurally invalid
+var ErrInvalidGrid = errors.New("invalid grid")
+
+// GridValidationError lists the problems that made a grid write invalid
+type GridValidationError struct {
+	Problems []models.GridProblem
+}
+
+func (e *GridValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("%v: %s", ErrInvalidGrid, e.Problems[0].Message)
+	}
+	return fmt.Sprintf("%v: %s (and %d more problems)", ErrInvalidGrid, e.Problems[0].Message, len(e.Problems)-1)
+}
+
+func (e *GridValidationError) Unwrap() error {
+	return ErrInvalidGrid
+}
+
+// rootPages are the grid pages that exist without a category pointing to them
+var rootPages = []string{"home", "systemControls"}
+
+// gridItemTypes are the item types the clients can render
+var gridItemTypes = map[string]bool{
+	"symbol":   true,
+	"category": true,
+	"system":   true,
+}
+
+// systemActions are the actions system controls can trigger
+var systemActions = map[string]bool{
+	"deleteLastWord": true,
+	"deleteAllText":  true,
+	"speakText":      true,
+	"setTense":       true,
+}
+
+// ValidateGrid checks the structure of the board's grid
+func (s *GridService) ValidateGrid(board *models.Board) (*models.GridValidationReport, error) {
+	log.Printf("Validating grid for board %s of user ID: %s", board.ID, board.UserID)
+
+	var report *models.GridValidationReport
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		report, err = s.validateStoredGrid(tx, board)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RepairGrid removes orphaned pages from the board's grid and, when requested,
+// category items linking to empty pages. Problems that need a decision from the
+// user (unknown types, cycles) are only reported.
+func (s *GridService) RepairGrid(board *models.Board, req models.GridRepairRequest, expectedRevision int) (*models.GridRepairResponse, error) {
+	log.Printf("Repairing grid for board %s of user ID: %s", board.ID, board.UserID)
+
+	var repaired []models.GridProblem
+	var itemsRemoved int64
+	revision, err := s.writeGrid(board, expectedRevision, func(tx *gorm.DB) (string, string, error) {
+		gridData, err := s.readGrid(tx, board.ID)
+		if err != nil {
+			return "", "", err
+		}
+
+		var orphanPages, danglingItems []string
+		_, warnings := validateGrid(gridData)
+		for _, warning := range warnings {
+			switch {
+			case warning.Code == models.GridProblemOrphanPage:
+				orphanPages = append(orphanPages, warning.Category)
+				repaired = append(repaired, warning)
+			case warning.Code == models.GridProblemDanglingLink && req.RemoveDanglingLinks:
+				danglingItems = append(danglingItems, warning.ItemID)
+				repaired = append(repaired, warning)
+			}
+		}
+
+		if len(orphanPages) > 0 {
+			result := tx.Where("board_id = ? AND parent_category IN ?", board.ID, orphanPages).Delete(&models.GridItem{})
+			if result.Error != nil {
+				return "", "", result.Error
+			}
+			itemsRemoved += result.RowsAffected
+		}
+		if len(danglingItems) > 0 {
+			result := tx.Where("board_id = ? AND id IN ?", board.ID, danglingItems).Delete(&models.GridItem{})
+			if result.Error != nil {
+				return "", "", result.Error
+			}
+			itemsRemoved += result.RowsAffected
+		}
+
+		if itemsRemoved == 0 {
+			return "", "", nil
+		}
+		return "repair", fmt.Sprintf("Repaired grid: removed %d orphaned pages and %d dangling links (%d items)",
+			len(orphanPages), len(danglingItems), itemsRemoved), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.ValidateGrid(board)
+	if err != nil {
+		return nil, err
+	}
+
+	if repaired == nil {
+		repaired = []models.GridProblem{}
+	}
+	log.Printf("Grid repair for board %s removed %d items", board.ID, itemsRemoved)
+	return &models.GridRepairResponse{
+		Repaired:     repaired,
+		ItemsRemoved: int(itemsRemoved),
+		Report:       *report,
+		Revision:     revision,
+	}, nil
+}
+
+// ValidationOverview validates the grids of all boards and returns those with problems
+func (s *GridService) ValidationOverview() (*models.GridValidationOverviewResponse, error) {
+	log.Printf("Validating grids of all boards")
+
+	var boards []models.Board
+	if err := database.DB.Preload("User").Order("user_id, created_at").Find(&boards).Error; err != nil {
+		return nil, err
+	}
+
+	overview := &models.GridValidationOverviewResponse{
+		BoardsChecked: len(boards),
+		Boards:        []models.BoardValidationSummary{},
+	}
+	for _, board := range boards {
+		gridData, err := s.readGrid(database.DB, board.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		errs, warnings := validateGrid(gridData)
+		if len(errs) == 0 && len(warnings) == 0 {
+			continue
+		}
+		overview.Boards = append(overview.Boards, models.BoardValidationSummary{
+			BoardID:   board.ID,
+			BoardName: board.Name,
+			UserID:    board.UserID,
+			Username:  board.User.Username,
+			Errors:    len(errs),
+			Warnings:  len(warnings),
+		})
+	}
+	return overview, nil
+}
+
+// validateStoredGrid validates the board's grid as stored, within tx
+func (s *GridService) validateStoredGrid(tx *gorm.DB, board *models.Board) (*models.GridValidationReport, error) {
+	gridData, err := s.readGrid(tx, board.ID)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := s.currentRevision(tx, board.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	errs, warnings := validateGrid(gridData)
+	return &models.GridValidationReport{
+		BoardID:  board.ID,
+		Valid:    len(errs) == 0,
+		Errors:   errs,
+		Warnings: warnings,
+		Revision: revision,
+	}, nil
+}
+
+// validateStoredItems checks items after they were written within tx: their own
+// fields, and that they do not close a cycle of category links
+func (s *GridService) validateStoredItems(tx *gorm.DB, boardID string, itemIDs ...string) error {
+	gridData, err := s.readGrid(tx, boardID)
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		changed[id] = true
+	}
+
+	var problems []models.GridProblem
+	for _, category := range sortedPages(gridData) {
+		for _, item := range gridData[category] {
+			if !changed[item.ID] {
+				continue
+			}
+			problems = append(problems, validateItem(item, category)...)
+			if item.Type == "category" && pageReaches(gridData, item.Target, category) {
+				problems = append(problems, gridProblem(models.GridProblemCycle, category, item,
+					fmt.Sprintf("Category %q on page %s links back to page %s, creating a loop", item.Label, category, item.Target)))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &GridValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateGrid checks a grid and returns its errors (rejected on save) and warnings
+func validateGrid(gridData map[string][]models.GridItemResponse) (errs, warnings []models.GridProblem) {
+	errs = []models.GridProblem{}
+	warnings = []models.GridProblem{}
+
+	seen := make(map[string]string)
+	for _, category := range sortedPages(gridData) {
+		for _, item := range gridData[category] {
+			if item.ID != "" {
+				if first, exists := seen[item.ID]; exists {
+					errs = append(errs, gridProblem(models.GridProblemDuplicateID, category, item,
+						fmt.Sprintf("Item ID %s on page %s is already used on page %s", item.ID, category, first)))
+				} else {
+					seen[item.ID] = category
+				}
+			}
+
+			errs = append(errs, validateItem(item, category)...)
+
+			if item.Type == "category" && item.Target != "" && !isRootPage(item.Target) && len(gridData[item.Target]) == 0 {
+				warnings = append(warnings, gridProblem(models.GridProblemDanglingLink, category, item,
+					fmt.Sprintf("Category %q links to page %s, which has no items", item.Label, item.Target)))
+			}
+		}
+	}
+
+	errs = append(errs, cycleLinks(gridData)...)
+
+	reachable := reachablePages(gridData)
+	for _, category := range sortedPages(gridData) {
+		if !reachable[category] && len(gridData[category]) > 0 {
+			warnings = append(warnings, models.GridProblem{
+				Code:     models.GridProblemOrphanPage,
+				Category: category,
+				Message:  fmt.Sprintf("Page %s with %d items is not reachable from any category", category, len(gridData[category])),
+			})
+		}
+	}
+
+	return errs, warnings
+}
+
+// validateItem checks the type, action and target of a single item
+func validateItem(item models.GridItemResponse, category string) []models.GridProblem {
+	var problems []models.GridProblem
+
+	if !gridItemTypes[item.Type] {
+		problems = append(problems, gridProblem(models.GridProblemUnknownType, category, item,
+			fmt.Sprintf("Item %q has unknown type %q", item.Label, item.Type)))
+	}
+
+	switch item.Type {
+	case "system":
+		if !systemActions[item.Action] {
+			problems = append(problems, gridProblem(models.GridProblemUnknownAction, category, item,
+				fmt.Sprintf("System control %q has unknown action %q", item.Label, item.Action)))
+		}
+	case "category":
+		switch {
+		case strings.TrimSpace(item.Target) == "":
+			problems = append(problems, gridProblem(models.GridProblemMissingTarget, category, item,
+				fmt.Sprintf("Category %q has no target page", item.Label)))
+		case item.Target == "systemControls":
+			problems = append(problems, gridProblem(models.GridProblemReservedTarget, category, item,
+				fmt.Sprintf("Category %q cannot open the system controls", item.Label)))
+		}
+	}
+
+	return problems
+}
+
+// cycleLinks returns the category items that link back to a page they are reached
+// from, which would let navigation loop forever
+func cycleLinks(gridData map[string][]models.GridItemResponse) []models.GridProblem {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	problems := []models.GridProblem{}
+	state := make(map[string]int)
+
+	var visit func(page string)
+	visit = func(page string) {
+		state[page] = visiting
+		for _, item := range gridData[page] {
+			if item.Type != "category" || item.Target == "" {
+				continue
+			}
+			switch state[item.Target] {
+			case visiting:
+				problems = append(problems, gridProblem(models.GridProblemCycle, page, item,
+					fmt.Sprintf("Category %q on page %s links back to page %s, creating a loop", item.Label, page, item.Target)))
+			case unvisited:
+				visit(item.Target)
+			}
+		}
+		state[page] = done
+	}
+
+	// Start from the roots so the link reported is the one pointing back up
+	for _, page := range append(append([]string{}, rootPages...), sortedPages(gridData)...) {
+		if state[page] == unvisited {
+			visit(page)
+		}
+	}
+	return problems
+}
+
+// pageReaches reports whether page to can be opened from page from, following category links
+func pageReaches(gridData map[string][]models.GridItemResponse, from, to string) bool {
+	visited := make(map[string]bool)
+	queue := []string{from}
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		if page == to {
+			return true
+		}
+		if visited[page] {
+			continue
+		}
+		visited[page] = true
+		for _, item := range gridData[page] {
+			if item.Type == "category" && item.Target != "" {
+				queue = append(queue, item.Target)
+			}
+		}
+	}
+	return false
+}
+
+// reachablePages returns the pages that can be opened from the root pages
+func reachablePages(gridData map[string][]models.GridItemResponse) map[string]bool {
+	reachable := make(map[string]bool)
+	queue := append([]string{}, rootPages...)
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		if reachable[page] {
+			continue
+		}
+		reachable[page] = true
+		for _, item := range gridData[page] {
+			if item.Type == "category" && item.Target != "" && !reachable[item.Target] {
+				queue = append(queue, item.Target)
+			}
+		}
+	}
+	return reachable
+}
+
+// removeCycleLinks drops category items that close a cycle from gridData and
+// returns them. Used on import, where "back" buttons commonly link to a parent board.
+func removeCycleLinks(gridData map[string][]models.GridItemResponse) []models.GridProblem {
+	problems := cycleLinks(gridData)
+	if len(problems) == 0 {
+		return problems
+	}
+
+	remove := make(map[string]bool, len(problems))
+	for _, problem := range problems {
+		remove[problem.ItemID] = true
+	}
+	for page, items := range gridData {
+		kept := items[:0]
+		for _, item := range items {
+			if !remove[item.ID] {
+				kept = append(kept, item)
+			}
+		}
+		gridData[page] = kept
+	}
+	return problems
+}
+
+// sortedPages returns the page keys of a grid in a stable order
+func sortedPages(gridData map[string][]models.GridItemResponse) []string {
+	pages := make([]string, 0, len(gridData))
+	for page := range gridData {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+// isRootPage reports whether page exists without a category pointing to it
+func isRootPage(page string) bool {
+	for _, root := range rootPages {
+		if page == root {
+			return true
+		}
+	}
+	return false
+}
+
+// gridProblem builds a problem about a single item
+func gridProblem(code, category string, item models.GridItemResponse, message string) models.GridProblem {
+	return models.GridProblem{
+		Code:     code,
+		Category: category,
+		ItemID:   item.ID,
+		Label:    item.Label,
+		Message:  message,
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrGridVersionNotFound is returned when a requested grid version does not exist
+var ErrGridVersionNotFound = errors.New("grid version not found")
+
+// AnyRevision disables the revision check on a grid write
+const AnyRevision = -1
+
+var (
+	// ErrRevisionMismatch is returned when a write was based on a stale revision
+	ErrRevisionMismatch = errors.New("grid revision mismatch")
+	// ErrRevisionConflict is returned when another write committed the same revision first
+	ErrRevisionConflict = errors.New("grid modified concurrently")
+)
+
+// GridRevisionError reports a failed revision check along with the grid's current revision
+type GridRevisionError struct {
+	Err     error
+	Current int
+}
+
+func (e *GridRevisionError) Error() string {
+	return fmt.Sprintf("%v (current revision %d)", e.Err, e.Current)
+}
+
+func (e *GridRevisionError) Unwrap() error {
+	return e.Err
+}
+
+// Revision returns the board's current grid revision (0 when the grid has never been saved)
+func (s *GridService) Revision(board *models.Board) (int, error) {
+	return s.currentRevision(database.DB, board.ID)
+}
+
+// currentRevision reads the latest recorded version number for the board
+func (s *GridService) currentRevision(tx *gorm.DB, boardID string) (int, error) {
+	var revision int
+	if err := tx.Model(&models.GridVersion{}).
+		Where("board_id = ?", boardID).
+		Select("COALESCE(MAX(version), 0)").
+		Row().Scan(&revision); err != nil {
+		return 0, fmt.Errorf("error reading grid revision: %w", err)
+	}
+	return revision, nil
+}
+
+// writeGrid runs fn in a transaction guarded by expectedRevision and records the
+// result as a new version. fn returns the version action and summary, or an empty
+// action when it changed nothing. The returned value is the grid's new revision.
+func (s *GridService) writeGrid(board *models.Board, expectedRevision int, fn func(tx *gorm.DB) (action, summary string, err error)) (int, error) {
+	var revision int
+	var action, summary string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		current, err := s.currentRevision(tx, board.ID)
+		if err != nil {
+			return err
+		}
+		if expectedRevision != AnyRevision && expectedRevision != current {
+			log.Printf("Grid revision mismatch for board %s: expected %d, current %d", board.ID, expectedRevision, current)
+			return &GridRevisionError{Err: ErrRevisionMismatch, Current: current}
+		}
+
+		action, summary, err = fn(tx)
+		if err != nil {
+			return err
+		}
+		if action == "" {
+			revision = current
+			return nil
+		}
+
+		revision, err = s.recordVersion(tx, board, current, action, summary)
+		return err
+	})
+	if errors.Is(err, ErrRevisionConflict) {
+		// The transaction lost a race; report the revision that won
+		current, revErr := s.Revision(board)
+		if revErr != nil {
+			return 0, revErr
+		}
+		return 0, &GridRevisionError{Err: ErrRevisionConflict, Current: current}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if action != "" {
+		s.events.Publish(models.GridEvent{
+			UserID:    board.UserID,
+			BoardID:   board.ID,
+			Action:    action,
+			Summary:   summary,
+			Revision:  revision,
+			Timestamp: time.Now(),
+		})
+	}
+	return revision, nil
+}
+
+// recordVersion snapshots the board's current grid inside tx as the version after
+// latest and prunes versions beyond the configured retention
+func (s *GridService) recordVersion(tx *gorm.DB, board *models.Board, latest int, action, summary string) (int, error) {
+	gridData, err := s.readGrid(tx, board.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error reading grid for version snapshot: %w", err)
+	}
+
+	itemCount := 0
+	for _, items := range gridData {
+		itemCount += len(items)
+	}
+
+	snapshot, err := json.Marshal(gridData)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling grid snapshot: %w", err)
+	}
+
+	version := models.GridVersion{
+		UserID:    board.UserID,
+		BoardID:   board.ID,
+		Version:   latest + 1,
+		Action:    action,
+		Summary:   summary,
+		ItemCount: itemCount,
+		Snapshot:  string(snapshot),
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return 0, ErrRevisionConflict
+		}
+		return 0, fmt.Errorf("error recording grid version: %w", err)
+	}
+
+	log.Printf("Recorded grid version %d for board %s (%s)", version.Version, board.ID, action)
+
+	if retention := s.cfg.Grid.VersionRetention; retention > 0 && version.Version > retention {
+		if err := tx.Where("board_id = ? AND version <= ?", board.ID, version.Version-retention).
+			Delete(&models.GridVersion{}).Error; err != nil {
+			return 0, fmt.Errorf("error pruning grid versions: %w", err)
+		}
+	}
+
+	return version.Version, nil
+}
+
+// ListVersions returns the board's grid versions, newest first, without snapshots
+func (s *GridService) ListVersions(board *models.Board) ([]models.GridVersionResponse, error) {
+	log.Printf("Listing grid versions for board: %s", board.ID)
+
+	var versions []models.GridVersion
+	if err := database.DB.Select("version, action, summary, item_count, created_at").
+		Where("board_id = ?", board.ID).
+		Order("version DESC").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	response := make([]models.GridVersionResponse, 0, len(versions))
+	for _, v := range versions {
+		response = append(response, models.GridVersionResponse{
+			Version:   v.Version,
+			Action:    v.Action,
+			Summary:   v.Summary,
+			ItemCount: v.ItemCount,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return response, nil
+}
+
+// GetVersion returns a single grid version including its snapshot
+func (s *GridService) GetVersion(board *models.Board, version int) (*models.GridVersionResponse, error) {
+	v, gridData, err := s.loadVersion(board, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GridVersionResponse{
+		Version:   v.Version,
+		Action:    v.Action,
+		Summary:   v.Summary,
+		ItemCount: v.ItemCount,
+		CreatedAt: v.CreatedAt,
+		Grid:      gridData,
+	}, nil
+}
+
+// DiffVersions compares two grid versions item by item
+func (s *GridService) DiffVersions(board *models.Board, from, to int) (*models.GridDiffResponse, error) {
+	log.Printf("Diffing grid versions %d..%d for board: %s", from, to, board.ID)
+
+	_, fromGrid, err := s.loadVersion(board, from)
+	if err != nil {
+		return nil, err
+	}
+	_, toGrid, err := s.loadVersion(board, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffGrids(fromGrid, toGrid, from, to), nil
+}
+
+// RestoreVersion replaces the board's grid with a previous version's snapshot and
+// returns the new revision. The restore itself is recorded as a new version, so it
+// can be undone.
+func (s *GridService) RestoreVersion(board *models.Board, version, expectedRevision int) (int, error) {
+	log.Printf("Restoring grid version %d for board: %s", version, board.ID)
+
+	_, gridData, err := s.loadVersion(board, version)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.saveGrid(gridData, board, expectedRevision, "restore", fmt.Sprintf("Restored version %d", version))
+}
+
+// loadVersion fetches a version row and decodes its snapshot
+func (s *GridService) loadVersion(board *models.Board, version int) (*models.GridVersion, models.GridResponse, error) {
+	var v models.GridVersion
+	if err := database.DB.Where("board_id = ? AND version = ?", board.ID, version).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrGridVersionNotFound
+		}
+		return nil, nil, err
+	}
+
+	var gridData models.GridResponse
+	if err := json.Unmarshal([]byte(v.Snapshot), &gridData); err != nil {
+		return nil, nil, fmt.Errorf("error decoding grid snapshot: %w", err)
+	}
+	if gridData == nil {
+		gridData = models.GridResponse{}
+	}
+
+	return &v, gridData, nil
+}
+
+// gridItemLocation is an item together with the category it lives in and its
+// position on that page
+type gridItemLocation struct {
+	category string
+	position int
+	item     models.GridItemResponse
+}
+
+// diffGrids computes added, removed and modified items between two grids
+func diffGrids(fromGrid, toGrid models.GridResponse, from, to int) *models.GridDiffResponse {
+	index := func(grid models.GridResponse) map[string]gridItemLocation {
+		items := make(map[string]gridItemLocation)
+		for category, categoryItems := range grid {
+			for position, item := range categoryItems {
+				items[item.ID] = gridItemLocation{category: category, position: position, item: item}
+			}
+		}
+		return items
+	}
+	fromItems := index(fromGrid)
+	toItems := index(toGrid)
+
+	diff := &models.GridDiffResponse{
+		From:     from,
+		To:       to,
+		Added:    []models.GridDiffItem{},
+		Removed:  []models.GridDiffItem{},
+		Modified: []models.GridDiffModified{},
+	}
+
+	for id, after := range toItems {
+		before, existed := fromItems[id]
+		if !existed {
+			diff.Added = append(diff.Added, models.GridDiffItem{Category: after.category, Item: after.item})
+			continue
+		}
+
+		changes := diffGridItems(before, after)
+		if len(changes) > 0 {
+			diff.Modified = append(diff.Modified, models.GridDiffModified{
+				ID:       id,
+				Category: after.category,
+				Label:    after.item.Label,
+				Changes:  changes,
+			})
+		}
+	}
+
+	for id, before := range fromItems {
+		if _, exists := toItems[id]; !exists {
+			diff.Removed = append(diff.Removed, models.GridDiffItem{Category: before.category, Item: before.item})
+		}
+	}
+
+	// Stable output ordering
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Item.ID < diff.Added[j].Item.ID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Item.ID < diff.Removed[j].Item.ID })
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].ID < diff.Modified[j].ID })
+
+	return diff
+}
+
+// diffGridItems returns the changed fields between two versions of the same item
+func diffGridItems(before, after gridItemLocation) map[string]models.GridDiffValue {
+	changes := make(map[string]models.GridDiffValue)
+	compare := func(field string, old, new interface{}) {
+		if old != new {
+			changes[field] = models.GridDiffValue{Old: old, New: new}
+		}
+	}
+
+	compare("parent_category", before.category, after.category)
+	compare("position", before.position, after.position)
+	compare("type", before.item.Type, after.item.Type)
+	compare("label", before.item.Label, after.item.Label)
+	compare("icon", before.item.Icon, after.item.Icon)
+	compare("color", before.item.Color, after.item.Color)
+	compare("target", before.item.Target, after.item.Target)
+	compare("text", before.item.Text, after.item.Text)
+	compare("speak", before.item.Speak, after.item.Speak)
+	compare("action", before.item.Action, after.item.Action)
+	compare("isVisible", before.item.IsVisible, after.item.IsVisible)
+	compare("symbol_type", before.item.SymbolType, after.item.SymbolType)
+	compare("isHideable", before.item.IsHideable, after.item.IsHideable)
+
+	return changes
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// ErrImageNotFound is returned when a stored image does not exist
+var ErrImageNotFound = errors.New("image not found")
+
+// ImageStore persists processed symbol images by key. S3StorageService stores
+// them in the configured bucket; LocalImageStore keeps them on disk.
+type ImageStore interface {
+	PutImage(ctx context.Context, key string, content []byte, contentType string) error
+	GetImage(ctx context.Context, key string) ([]byte, string, error)
+}
+
+// LocalImageStore is an ImageStore backed by a local directory
+type LocalImageStore struct {
+	dir string
+}
+
+// NewLocalImageStore creates an image store that writes below dir
+func NewLocalImageStore(dir string) *LocalImageStore {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[IMAGES] Warning: Failed to create image directory %s: %v", dir, err)
+	}
+	return &LocalImageStore{dir: dir}
+}
+
+// PutImage writes an image to the directory
+func (s *LocalImageStore) PutImage(ctx context.Context, key string, content []byte, contentType string) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating image directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial image
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("error writing image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing image: %w", err)
+	}
+	return nil
+}
+
+// GetImage reads an image from the directory
+func (s *LocalImageStore) GetImage(ctx context.Context, key string) ([]byte, string, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", ErrImageNotFound
+		}
+		return nil, "", fmt.Errorf("error reading image: %w", err)
+	}
+	return content, mime.TypeByExtension(filepath.Ext(key)), nil
+}
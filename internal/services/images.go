@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/config"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register WebP decoder
+)
+
+// ImageURLPrefix is the path under which stored images are served
+const ImageURLPrefix = "/api/images/"
+
+// maxSourcePixels bounds the decoded size of an upload, guarding against images
+// that are small on the wire but huge once decompressed
+const maxSourcePixels = 50_000_000
+
+var (
+	// ErrInvalidImage is returned when an uploaded image cannot be accepted
+	ErrInvalidImage = errors.New("invalid image")
+
+	// allowedImageTypes are the formats accepted for upload
+	allowedImageTypes = map[string]bool{
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+		"image/webp": true,
+	}
+
+	// imageKeyPattern matches keys generated by StoreDataURL: <user id>/<sha256>.<ext>
+	imageKeyPattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,64}/[0-9a-f]{64}\.(jpg|png)$`)
+)
+
+// ImageService turns uploaded data URL images into normalized files in an ImageStore
+type ImageService struct {
+	cfg   config.ImageConfig
+	store ImageStore
+}
+
+// NewImageService creates an ImageService storing images in S3 when it is enabled,
+// otherwise in the configured local directory
+func NewImageService(cfg *config.Config) *ImageService {
+	var store ImageStore
+	if s3Storage := NewS3StorageService(cfg); s3Storage.IsEnabled() {
+		log.Printf("[IMAGES] Storing images in S3")
+		store = s3Storage
+	} else {
+		log.Printf("[IMAGES] Storing images in %s", cfg.Images.LocalDir)
+		store = NewLocalImageStore(cfg.Images.LocalDir)
+	}
+	return &ImageService{cfg: cfg.Images, store: store}
+}
+
+// StoreDataURL validates, normalizes and stores a data URL image uploaded by the
+// user and returns the URL it is served from
+func (s *ImageService) StoreDataURL(ctx context.Context, userID, dataURL string) (string, error) {
+	content, err := s.decodeUpload(dataURL)
+	if err != nil {
+		return "", err
+	}
+
+	processed, contentType, err := s.normalize(content)
+	if err != nil {
+		return "", err
+	}
+
+	// Content-addressed keys deduplicate repeated saves of the same image
+	sum := sha256.Sum256(processed)
+	ext := ".png"
+	if contentType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	key := userID + "/" + hex.EncodeToString(sum[:]) + ext
+
+	if err := s.store.PutImage(ctx, key, processed, contentType); err != nil {
+		return "", err
+	}
+
+	log.Printf("[IMAGES] Stored image %s (%d bytes, from %d bytes upload)", key, len(processed), len(content))
+	return ImageURLPrefix + key, nil
+}
+
+// Open returns a stored image and its content type
+func (s *ImageService) Open(ctx context.Context, key string) ([]byte, string, error) {
+	if !imageKeyPattern.MatchString(key) {
+		return nil, "", ErrImageNotFound
+	}
+	return s.store.GetImage(ctx, key)
+}
+
+// InlineDataURL returns a stored image referenced by icon as a data URL. It reports
+// false when icon does not refer to a stored image or the image cannot be read.
+func (s *ImageService) InlineDataURL(ctx context.Context, icon string) (string, bool) {
+	key, ok := strings.CutPrefix(icon, ImageURLPrefix)
+	if !ok {
+		return "", false
+	}
+
+	content, contentType, err := s.Open(ctx, key)
+	if err != nil {
+		log.Printf("[IMAGES] Warning: failed to read stored image %s: %v", key, err)
+		return "", false
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(content), true
+}
+
+// decodeUpload decodes a base64 data URL, checking its declared type and size
+func (s *ImageService) decodeUpload(dataURL string) ([]byte, error) {
+	header, payload, found := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("%w: expected a base64 data URL", ErrInvalidImage)
+	}
+
+	declaredType := strings.ToLower(strings.TrimSuffix(header, ";base64"))
+	if !allowedImageTypes[declaredType] {
+		return nil, fmt.Errorf("%w: unsupported image type %q", ErrInvalidImage, declaredType)
+	}
+
+	if len(payload) > base64.StdEncoding.EncodedLen(s.cfg.MaxUploadBytes) {
+		return nil, fmt.Errorf("%w: image is larger than %d bytes", ErrInvalidImage, s.cfg.MaxUploadBytes)
+	}
+
+	content, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed base64 data", ErrInvalidImage)
+	}
+	if len(content) > s.cfg.MaxUploadBytes {
+		return nil, fmt.Errorf("%w: image is larger than %d bytes", ErrInvalidImage, s.cfg.MaxUploadBytes)
+	}
+	return content, nil
+}
+
+// normalize decodes an image, applies its EXIF orientation, scales it down to the
+// maximum dimension and re-encodes it. Re-encoding from pixels drops EXIF and any
+// other metadata. Opaque images become JPEG, images with transparency PNG.
+func (s *ImageService) normalize(content []byte) ([]byte, string, error) {
+	// Trust the bytes, not the declared type
+	sniffedType := http.DetectContentType(content)
+	if !allowedImageTypes[sniffedType] {
+		return nil, "", fmt.Errorf("%w: content is %s", ErrInvalidImage, sniffedType)
+	}
+
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if imgConfig.Width <= 0 || imgConfig.Height <= 0 || imgConfig.Width*imgConfig.Height > maxSourcePixels {
+		return nil, "", fmt.Errorf("%w: unsupported dimensions %dx%d", ErrInvalidImage, imgConfig.Width, imgConfig.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	width, height := fitDimensions(src.Bounds().Dx(), src.Bounds().Dy(), s.cfg.MaxDimension)
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	img := scaled
+	if sniffedType == "image/jpeg" {
+		img = applyOrientation(scaled, jpegOrientation(content))
+	}
+
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: s.cfg.JPEGQuality}); err != nil {
+			return nil, "", fmt.Errorf("error encoding image: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("error encoding image: %w", err)
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// fitDimensions scales width and height down so the longest side is at most
+// maxDimension, keeping the aspect ratio. Images are never scaled up.
+func fitDimensions(width, height, maxDimension int) (int, int) {
+	if maxDimension <= 0 || (width <= maxDimension && height <= maxDimension) {
+		return width, height
+	}
+	if width >= height {
+		return maxDimension, max(1, height*maxDimension/width)
+	}
+	return max(1, width*maxDimension/height), maxDimension
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, returning 1 (upright)
+// when it is missing or unreadable
+func jpegOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the marker segments up to the start of the image data
+	pos := 2
+	for pos+4 <= len(content) && content[pos] == 0xFF {
+		marker := content[pos+1]
+		length := int(binary.BigEndian.Uint16(content[pos+2:]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(content) {
+			break
+		}
+		segment := content[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF header
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips an image so that EXIF orientation
+// (1-8) is baked into the pixels
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Languages supported by the AI language services and ARASAAC searches
+const (
+	LanguageItalian = "it"
+	LanguageSpanish = "es"
+	LanguageEnglish = "en"
+)
+
+// ErrUnsupportedLanguage is returned when a request names a language without prompts
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// tenseDefinition describes a tense with the RAG section describing it
+type tenseDefinition struct {
+	id, name, ragSection string
+}
+
+// languageDefinition describes a supported language: its tenses, each with a
+// prompt template, the file its RAG knowledge is stored in and the sample data
+// its prompt templates are validated with
+type languageDefinition struct {
+	code, name string
+	tenses     []tenseDefinition
+	ragFile    string
+	// rules tells whether the rule-based conjugator knows the language
+	rules          bool
+	sampleVerbs    []string
+	sampleSentence string // Keywords to correct
+	// messages words the problems of LLM answers in the repair prompt
+	messages outputMessages
+}
+
+// supportedLanguages lists the supported languages, the default one first
+var supportedLanguages = []languageDefinition{
+	{
+		code: LanguageItalian,
+		name: "Italiano",
+		tenses: []tenseDefinition{
+			{TensePresente, "Presente", "presente_indicativo"},
+			{TensePassato, "Passato prossimo", "passato_prossimo"},
+			{TenseImperfetto, "Imperfetto", "imperfetto"},
+			{TenseFuturo, "Futuro semplice", "futuro_semplice"},
+		},
+		ragFile:        "rag_knowledge.json",
+		rules:          true,
+		sampleVerbs:    []string{"mangiare", "essere", "avere"},
+		sampleSentence: "Io volere mangiare pizza",
+		messages: outputMessages{
+			notObject:     "la risposta non è un oggetto JSON valido",
+			unknownVerb:   "la chiave %q non è uno dei verbi richiesti",
+			missingVerb:   "manca il verbo %q",
+			unexpectedKey: "la chiave %q non è prevista",
+			missingKey:    "manca la chiave %q",
+			multiline:     "il valore di %q deve essere una sola frase su una riga",
+			notString:     "il valore di %q non è una stringa",
+			empty:         "il valore di %q è vuoto",
+		},
+	},
+	{
+		code: LanguageSpanish,
+		name: "Español",
+		tenses: []tenseDefinition{
+			{"presente", "Presente", "presente_indicativo"},
+			{"preterito", "Pretérito indefinido", "preterito_indefinido"},
+			{"imperfecto", "Pretérito imperfecto", "preterito_imperfecto"},
+			{"futuro", "Futuro simple", "futuro_simple"},
+		},
+		ragFile:        "rag_knowledge.es.json",
+		sampleVerbs:    []string{"comer", "ser", "tener"},
+		sampleSentence: "Yo querer comer pizza",
+		messages: outputMessages{
+			notObject:     "la respuesta no es un objeto JSON válido",
+			unknownVerb:   "la clave %q no es uno de los verbos pedidos",
+			missingVerb:   "falta el verbo %q",
+			unexpectedKey: "la clave %q no está prevista",
+			missingKey:    "falta la clave %q",
+			multiline:     "el valor de %q debe ser una sola frase en una línea",
+			notString:     "el valor de %q no es una cadena",
+			empty:         "el valor de %q está vacío",
+		},
+	},
+	{
+		code: LanguageEnglish,
+		name: "English",
+		tenses: []tenseDefinition{
+			{"present", "Present simple", "present_simple"},
+			{"past", "Past simple", "past_simple"},
+			{"future", "Future simple", "future_simple"},
+		},
+		ragFile:        "rag_knowledge.en.json",
+		sampleVerbs:    []string{"eat", "be", "have"},
+		sampleSentence: "I want eat pizza",
+		messages: outputMessages{
+			notObject:     "the answer is not a valid JSON object",
+			unknownVerb:   "the key %q is not one of the requested verbs",
+			missingVerb:   "the verb %q is missing",
+			unexpectedKey: "the key %q is not expected",
+			missingKey:    "the key %q is missing",
+			multiline:     "the value of %q must be a single sentence on one line",
+			notString:     "the value of %q is not a string",
+			empty:         "the value of %q is empty",
+		},
+	},
+}
+
+// findLanguage returns the definition of a supported language
+func findLanguage(code string) (*languageDefinition, bool) {
+	for i := range supportedLanguages {
+		if supportedLanguages[i].code == code {
+			return &supportedLanguages[i], true
+		}
+	}
+	return nil, false
+}
+
+// normalizeLanguage checks a language code against the supported languages,
+// ignoring case and region (es-ES is es). An empty code means fallback.
+func normalizeLanguage(code, fallback string) (string, error) {
+	primary := strings.ToLower(strings.TrimSpace(code))
+	if primary == "" {
+		return fallback, nil
+	}
+	if i := strings.IndexAny(primary, "-_"); i > 0 {
+		primary = primary[:i]
+	}
+	if _, ok := findLanguage(primary); ok {
+		return primary, nil
+	}
+
+	codes := make([]string, len(supportedLanguages))
+	for i, language := range supportedLanguages {
+		codes[i] = language.code
+	}
+	return "", fmt.Errorf("%w: %q (supported: %s)", ErrUnsupportedLanguage, code, strings.Join(codes, ", "))
+}
+
+// configuredLanguage returns the configured default language, Italian when the
+// configuration names an unsupported one
+func configuredLanguage(cfg *config.Config) string {
+	language, err := normalizeLanguage(cfg.DefaultLanguage, LanguageItalian)
+	if err != nil {
+		log.Printf("Invalid DEFAULT_LANGUAGE, using %s: %v", LanguageItalian, err)
+		return LanguageItalian
+	}
+	return language
+}
+
+// LanguageService resolves the language of each user's requests from their
+// preference and the configured default
+type LanguageService struct {
+	db              *gorm.DB
+	defaultLanguage string
+}
+
+// NewLanguageService creates a new LanguageService
+func NewLanguageService(db *gorm.DB, cfg *config.Config) *LanguageService {
+	return &LanguageService{
+		db:              db,
+		defaultLanguage: configuredLanguage(cfg),
+	}
+}
+
+// DefaultLanguage returns the language of users without a preference
+func (s *LanguageService) DefaultLanguage() string {
+	return s.defaultLanguage
+}
+
+// PreferredLanguage returns the language a user chose, empty when they use the default
+func (s *LanguageService) PreferredLanguage(userID string) (string, error) {
+	var user models.User
+	if err := s.db.Select("language").Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("user not found")
+		}
+		return "", fmt.Errorf("error loading user language: %w", err)
+	}
+	return user.Language, nil
+}
+
+// SetPreferredLanguage stores the language a user chose; an empty language goes
+// back to the default. It returns the normalized language stored.
+func (s *LanguageService) SetPreferredLanguage(userID, code string) (string, error) {
+	language, err := normalizeLanguage(code, "")
+	if err != nil {
+		return "", err
+	}
+
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("language", language)
+	if result.Error != nil {
+		return "", fmt.Errorf("error saving user language: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", errors.New("user not found")
+	}
+	return language, nil
+}
+
+// ResolveLanguage returns the language of a user's request: the one it names,
+// else the user's preference, else the default
+func (s *LanguageService) ResolveLanguage(userID, requested string) (string, error) {
+	if requested != "" {
+		return normalizeLanguage(requested, s.defaultLanguage)
+	}
+
+	preferred, err := s.PreferredLanguage(userID)
+	if err != nil {
+		return "", err
+	}
+	language, err := normalizeLanguage(preferred, s.defaultLanguage)
+	if err != nil {
+		// The preference names a language that is no longer supported
+		return s.defaultLanguage, nil
+	}
+	return language, nil
+}
@@ -3,34 +3,54 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/daniele/web-app-caa/internal/config"
 	"github.com/daniele/web-app-caa/internal/models"
-	"github.com/daniele/web-app-caa/pkg/ollama"
-
-	"github.com/openai/openai-go/v2"
-	"github.com/openai/openai-go/v2/option"
-	"github.com/openai/openai-go/v2/shared"
 )
 
+// ErrUnsupportedTense is returned when a conjugation request names a tense without a prompt
+var ErrUnsupportedTense = errors.New("unsupported tense")
+
 // LLMService handles direct LLM operations using Go templates
 type LLMService struct {
-	backendType  string
-	llmHost      string
-	openaiKey    string
-	llmModel     string
-	ollamaClient ollama.Client  // For Ollama requests
-	openaiClient *openai.Client // For OpenAI requests
-	ragData      map[string]interface{}
-	templates    map[string]*template.Template
-	s3Storage    *S3StorageService // S3 storage service for RAG knowledge
+	provider        LLMProvider   // Primary backend, wrapped with its fallbacks when configured
+	timeout         time.Duration // Deadline of one conjugation or correction, fallbacks and repairs included
+	model           string        // Configured model, part of the cache key
+	defaultLanguage string        // Language of requests that do not name one
+	rag             map[string]*ragDocument
+	ragMu           sync.RWMutex  // Guards rag, replaced when admins edit the knowledge
+	ragEditMu       sync.Mutex    // Serializes admin edits, so patches apply to the latest knowledge
+	retriever       *ragRetriever // Selects the RAG entries injected into conjugation prompts
+	// templates holds the prompt templates keyed by language and name (it/presente)
+	templates map[string]*template.Template
+	// templateVersions hashes each template's source, part of the cache key
+	templateVersions map[string]string
+	templateMu       sync.RWMutex // Guards templates and templateVersions, swapped when admins edit them
+	repairAttempts   int          // Times an invalid LLM answer is sent back for repair
+	cache            *LLMCache    // Conjugation and correction results; nil when disabled
+	ragStore         RagStore     // Storage of the RAG knowledge and its backups; nil when disabled
+	backupRetention  int          // RAG knowledge backups kept per language; 0 keeps all
+}
+
+// ragDocument is the RAG knowledge of a language
+type ragDocument struct {
+	data    map[string]interface{}
+	version string // Hash of data, part of the cache key
+	// conjugator is the rule-based conjugation built from the knowledge; nil for
+	// languages the rules do not know
+	conjugator *Conjugator
+	index      *ragIndex // Entries of the knowledge per verb and per rule
 }
 
 // TemplateData represents the data structure for template rendering
@@ -41,54 +61,55 @@ type TemplateData struct {
 	BaseFormsJSON string   `json:"base_forms_json"` // JSON-formatted string for templates
 
 	// RAG knowledge fields
-	RagKnowledge string `json:"rag_knowledge"`
+	RagKnowledge string   `json:"rag_knowledge"`
+	RagEntries   []string `json:"rag_entries"` // IDs of the RAG entries injected
 
 	// Passato-specific fields
 	RegularParticiples   string                 `json:"regular_participles"`
 	IrregularParticiples map[string]interface{} `json:"irregular_participles"`
 	AuxiliaryChoice      map[string]interface{} `json:"auxiliary_choice"`
 
+	// Imperfetto-specific fields
+	RegularEndings map[string]interface{} `json:"regular_endings"`
+	IrregularVerbs map[string]interface{} `json:"irregular_verbs"`
+
 	// Futuro-specific fields
 	IrregularRoots map[string]interface{} `json:"irregular_roots"`
 	Endings        string                 `json:"endings"`
+
+	// Repair-specific fields
+	Problems         []string `json:"problems"`
+	ExpectedKeysJSON string   `json:"expected_keys_json"` // JSON-formatted keys the answer must have
 }
 
 // NewLLMService creates a new LLMService with templates and RAG data
 func NewLLMService(cfg *config.Config) *LLMService {
 	service := &LLMService{
-		backendType: cfg.LLM.BackendType,
-		llmHost:     cfg.LLM.Host,
-		openaiKey:   cfg.LLM.OpenAIKey,
-		llmModel:    cfg.LLM.Model,
-		templates:   make(map[string]*template.Template),
-		s3Storage:   NewS3StorageService(cfg),
-	}
-
-	// Initialize Ollama client if using Ollama backend
-	if cfg.LLM.BackendType == "ollama" {
-		service.ollamaClient = ollama.NewClient(&ollama.ClientConfig{
-			BaseURL: cfg.Ollama.BaseURL,
-			Timeout: cfg.Ollama.Timeout,
-		})
-	}
-
-	// Initialize OpenAI client if using OpenAI backend
-	if cfg.LLM.BackendType == "openai" && cfg.LLM.OpenAIKey != "" {
-		var opts []option.RequestOption
-		opts = append(opts, option.WithAPIKey(cfg.LLM.OpenAIKey))
-
-		// If using a custom host (not official OpenAI), set base URL
-		if cfg.LLM.Host != "" && cfg.LLM.Host != "https://api.openai.com" {
-			opts = append(opts, option.WithBaseURL(cfg.LLM.Host))
-		}
-
-		client := openai.NewClient(opts...)
-		service.openaiClient = &client
+		timeout:          cfg.LLM.RequestTimeout,
+		repairAttempts:   max(cfg.LLM.RepairAttempts, 0),
+		model:            cfg.LLM.Model,
+		defaultLanguage:  configuredLanguage(cfg),
+		rag:              make(map[string]*ragDocument),
+		templates:        make(map[string]*template.Template),
+		templateVersions: make(map[string]string),
+		retriever:        newRagRetriever(cfg.LLM.RAG),
+		cache:            NewLLMCache(cfg.LLM.Cache),
+		ragStore:         NewRagStore(cfg),
+		backupRetention:  max(cfg.RagStorage.BackupRetention, 0),
+	}
+
+	provider, err := NewLLMProviderChain(cfg)
+	if err != nil {
+		log.Printf("Error initializing LLM providers: %v", err)
+	} else {
+		service.provider = provider
 	}
 
-	// Load RAG knowledge
-	if err := service.loadRagData(); err != nil {
-		log.Printf("Error loading RAG data: %v", err)
+	// Load the RAG knowledge of every language
+	for _, language := range supportedLanguages {
+		if err := service.loadRagData(language.code); err != nil {
+			log.Printf("Error loading %s RAG data: %v", language.code, err)
+		}
 	}
 
 	// Load templates
@@ -96,78 +117,133 @@ func NewLLMService(cfg *config.Config) *LLMService {
 		log.Printf("Error loading templates: %v", err)
 	}
 
-	log.Printf("LLMService initialized - Backend: %s, Host: %s, Model: %s",
-		cfg.LLM.BackendType, cfg.LLM.Host, cfg.LLM.Model)
+	providerName := "none"
+	if service.provider != nil {
+		providerName = service.provider.Name()
+	}
+	log.Printf("LLMService initialized - Providers: %s, Model: %s, Default language: %s", providerName, cfg.LLM.Model, service.defaultLanguage)
 
 	return service
 }
 
-// loadRagData loads the RAG knowledge from S3 or local file as fallback
-func (s *LLMService) loadRagData() error {
+// loadRagData loads the RAG knowledge of a language from the RAG storage, or
+// the bundled file as fallback
+func (s *LLMService) loadRagData(language string) error {
 	ctx := context.Background()
+	file := ragFile(language)
 
-	// Try to load from S3 if enabled
-	if s.s3Storage.IsEnabled() {
-		log.Printf("Loading RAG data from S3...")
-		knowledge, err := s.s3Storage.GetRagKnowledge(ctx)
+	// Try to load from the storage if enabled
+	if s.ragStore != nil {
+		log.Printf("Loading %s RAG data from %s...", language, s.ragStore.Name())
+		knowledge, err := s.ragStore.GetRagKnowledge(ctx, file)
 		if err != nil {
-			log.Printf("Failed to load RAG data from S3: %v, falling back to local file", err)
+			log.Printf("Failed to load %s RAG data from %s: %v, falling back to local file", language, s.ragStore.Name(), err)
 		} else {
-			s.ragData = knowledge
-			log.Printf("RAG knowledge loaded successfully from S3")
+			s.setRagData(language, knowledge)
+			log.Printf("%s RAG knowledge loaded successfully from %s", language, s.ragStore.Name())
 			return nil
 		}
 	}
 
 	// Fallback to local file
-	log.Printf("Loading RAG data from local file...")
-	file, err := os.Open("rag_knowledge.json")
+	log.Printf("Loading %s RAG data from local file...", language)
+	knowledge, err := readRagFile(file)
+	if err != nil {
+		return err
+	}
+	s.setRagData(language, knowledge)
+
+	log.Printf("%s RAG knowledge loaded successfully from local file", language)
+	return nil
+}
+
+// readRagFile reads RAG knowledge from a local file
+func readRagFile(file string) (map[string]interface{}, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf("error opening rag_knowledge.json: %w", err)
+		return nil, fmt.Errorf("error opening %s: %w", file, err)
 	}
 	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf("Warning: failed to close rag_knowledge.json: %v", err)
+		if err := f.Close(); err != nil {
+			log.Printf("Warning: failed to close %s: %v", file, err)
 		}
 	}()
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&s.ragData); err != nil {
-		return fmt.Errorf("error decoding RAG data: %w", err)
+	var knowledge map[string]interface{}
+	decoder := json.NewDecoder(f)
+	if err := decoder.Decode(&knowledge); err != nil {
+		return nil, fmt.Errorf("error decoding RAG data: %w", err)
 	}
-
-	log.Printf("RAG knowledge loaded successfully from local file")
-	return nil
+	return knowledge, nil
 }
 
-// loadTemplates loads all the prompt templates
-func (s *LLMService) loadTemplates() error {
-	templateDir := "internal/prompts"
-	templateFiles := []string{"presente.tmpl", "passato.tmpl", "futuro.tmpl", "correct_sentence.tmpl"}
+// setRagData replaces the RAG knowledge of a language and rebuilds its
+// conjugator. Cached results are purged when the knowledge changes. Knowledge
+// loaded from files or S3 is used even when it does not match the schema, with
+// a warning; admin edits are validated before.
+func (s *LLMService) setRagData(language string, knowledge map[string]interface{}) {
+	if problems := validateRagKnowledge(language, knowledge); len(problems) > 0 {
+		log.Printf("Warning: %s RAG knowledge does not match the schema: %v", language, &RagValidationError{Problems: problems})
+	}
+	data, _ := json.Marshal(knowledge)
+	document := newRagDocument(language, knowledge, contentVersion(data))
 
-	for _, filename := range templateFiles {
-		filePath := filepath.Join(templateDir, filename)
-		tmpl, err := template.ParseFiles(filePath)
-		if err != nil {
-			return fmt.Errorf("error parsing template %s: %w", filename, err)
-		}
+	s.ragMu.Lock()
+	previous, ok := s.rag[language]
+	s.rag[language] = document
+	s.ragMu.Unlock()
 
-		// Remove .tmpl extension for the key
-		key := strings.TrimSuffix(filename, ".tmpl")
-		s.templates[key] = tmpl
+	if ok && previous.version != document.version {
+		log.Printf("%s RAG knowledge changed, purging cached results", language)
+		s.cache.Purge()
 	}
+}
 
-	log.Printf("Templates loaded successfully: %v", templateFiles)
-	return nil
+// newRagDocument builds the RAG knowledge of a language with its retrieval
+// index, and the rule-based conjugator for the languages it knows
+func newRagDocument(language string, knowledge map[string]interface{}, version string) *ragDocument {
+	document := &ragDocument{data: knowledge, version: version, index: newRagIndex(language, knowledge)}
+	if definition, ok := findLanguage(language); ok && definition.rules {
+		document.conjugator = NewConjugator(knowledge)
+	}
+	return document
 }
 
-// formatRagKnowledge formats the RAG JSON data for presente tense
-func (s *LLMService) formatRagKnowledge() string {
-	if s.ragData == nil {
-		return ""
+// languageRag returns the RAG knowledge of a language, empty when none was loaded
+func (s *LLMService) languageRag(language string) *ragDocument {
+	s.ragMu.RLock()
+	document, ok := s.rag[language]
+	s.ragMu.RUnlock()
+	if !ok {
+		return newRagDocument(language, nil, "")
 	}
+	return document
+}
+
+// contentVersion identifies content by a short hash
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// loadTemplates loads the prompt templates in use: the latest version stored in
+// the database, or the embedded default of templates without one
+func (s *LLMService) loadTemplates() error {
+	sources, err := activePromptSources()
+	if err != nil {
+		return err
+	}
+	if err := s.installTemplates(sources); err != nil {
+		return err
+	}
+
+	log.Printf("Templates loaded successfully: %d templates in %d languages", len(sources), len(supportedLanguages))
+	return nil
+}
 
-	presenteData, ok := s.ragData["presente_indicativo"].(map[string]interface{})
+// formatRagKnowledge formats the Italian RAG JSON data for presente tense
+func formatRagKnowledge(ragData map[string]interface{}) string {
+	presenteData, ok := ragData["presente_indicativo"].(map[string]interface{})
 	if !ok {
 		return ""
 	}
@@ -177,8 +253,8 @@ func (s *LLMService) formatRagKnowledge() string {
 
 	// Get general rules
 	if generalRules, ok := presenteData["general_rules"].(map[string]interface{}); ok {
-		for rule, details := range generalRules {
-			if detailsMap, ok := details.(map[string]interface{}); ok {
+		for _, rule := range sortedKeys(generalRules) {
+			if detailsMap, ok := generalRules[rule].(map[string]interface{}); ok {
 				if conjugation, ok := detailsMap["conjugation"].(string); ok {
 					knowledge.WriteString(fmt.Sprintf("- %s: %s\n", rule, conjugation))
 				}
@@ -186,12 +262,11 @@ func (s *LLMService) formatRagKnowledge() string {
 		}
 	}
 
-	knowledge.WriteString("\n**Verbi Irregolari Comuni (Esempi Chiave):**\n")
-
-	// Get irregular verbs
-	if irregularVerbs, ok := presenteData["irregular_verbs"].(map[string]interface{}); ok {
-		for verb, details := range irregularVerbs {
-			if detailsMap, ok := details.(map[string]interface{}); ok {
+	// Get irregular verbs, left out when none was retrieved
+	if irregularVerbs, ok := presenteData["irregular_verbs"].(map[string]interface{}); ok && len(irregularVerbs) > 0 {
+		knowledge.WriteString("\n**Verbi Irregolari Comuni (Esempi Chiave):**\n")
+		for _, verb := range sortedKeys(irregularVerbs) {
+			if detailsMap, ok := irregularVerbs[verb].(map[string]interface{}); ok {
 				if conjugation, ok := detailsMap["conjugation"].(string); ok {
 					knowledge.WriteString(fmt.Sprintf("- **%s**: %s\n", verb, conjugation))
 				}
@@ -204,7 +279,9 @@ func (s *LLMService) formatRagKnowledge() string {
 
 // renderTemplate renders a template with the given data
 func (s *LLMService) renderTemplate(templateName string, data TemplateData) (string, error) {
+	s.templateMu.RLock()
 	tmpl, exists := s.templates[templateName]
+	s.templateMu.RUnlock()
 	if !exists {
 		return "", fmt.Errorf("template %s not found", templateName)
 	}
@@ -217,8 +294,11 @@ func (s *LLMService) renderTemplate(templateName string, data TemplateData) (str
 	return buf.String(), nil
 }
 
-// prepareTemplateData prepares the template data based on tense
-func (s *LLMService) prepareTemplateData(sentence string, baseForms []string, tense string) TemplateData {
+// prepareTemplateData prepares the template data based on language and tense.
+// The Italian templates get the parts of the tense's RAG section they use; the
+// others get the section as JSON in RagKnowledge. With retrieval enabled the
+// section only holds the entries relevant to baseForms, listed in RagEntries.
+func (s *LLMService) prepareTemplateData(ctx context.Context, language, sentence string, baseForms []string, tense string) TemplateData {
 	// Convert BaseForms to JSON string for template use
 	baseFormsJSON, _ := json.Marshal(baseForms)
 
@@ -228,16 +308,37 @@ func (s *LLMService) prepareTemplateData(sentence string, baseForms []string, te
 		BaseFormsJSON: string(baseFormsJSON),
 	}
 
-	if s.ragData == nil {
+	rag := s.languageRag(language)
+	if rag.data == nil {
+		return data
+	}
+
+	section := tenseRagSection(language, tense)
+	var selection ragSelection
+	if s.retriever.enabled {
+		selection = rag.index.retrieve(ctx, s.retriever, language, section, baseForms)
+	} else {
+		selection = rag.index.all(rag.data, section)
+	}
+	data.RagEntries = selection.entries
+	log.Printf("[RAG] Injecting %d of %d %s/%s entries: %s", len(selection.entries), selection.total,
+		language, section, strings.Join(selection.entries, ", "))
+	ragData := selection.knowledge
+
+	if language != LanguageItalian {
+		if sectionData, ok := ragData[section]; ok {
+			sectionJSON, _ := json.MarshalIndent(sectionData, "", "  ")
+			data.RagKnowledge = string(sectionJSON)
+		}
 		return data
 	}
 
 	switch tense {
 	case "presente":
-		data.RagKnowledge = s.formatRagKnowledge()
+		data.RagKnowledge = formatRagKnowledge(ragData)
 
 	case "passato":
-		if passatoData, ok := s.ragData["passato_prossimo"].(map[string]interface{}); ok {
+		if passatoData, ok := ragData["passato_prossimo"].(map[string]interface{}); ok {
 			if regularParticiples, ok := passatoData["regular_participles"].(string); ok {
 				data.RegularParticiples = regularParticiples
 			}
@@ -249,8 +350,18 @@ func (s *LLMService) prepareTemplateData(sentence string, baseForms []string, te
 			}
 		}
 
+	case "imperfetto":
+		if imperfettoData, ok := ragData["imperfetto"].(map[string]interface{}); ok {
+			if regularEndings, ok := imperfettoData["regular_endings"].(map[string]interface{}); ok {
+				data.RegularEndings = regularEndings
+			}
+			if irregularVerbs, ok := imperfettoData["irregular_verbs"].(map[string]interface{}); ok {
+				data.IrregularVerbs = irregularVerbs
+			}
+		}
+
 	case "futuro":
-		if futuroData, ok := s.ragData["futuro_semplice"].(map[string]interface{}); ok {
+		if futuroData, ok := ragData["futuro_semplice"].(map[string]interface{}); ok {
 			if irregularRoots, ok := futuroData["irregular_roots"].(map[string]interface{}); ok {
 				data.IrregularRoots = irregularRoots
 			}
@@ -263,126 +374,219 @@ func (s *LLMService) prepareTemplateData(sentence string, baseForms []string, te
 	return data
 }
 
-// llmResponse sends a prompt to the LLM and gets the response
-func (s *LLMService) llmResponse(prompt string) (string, error) {
-	switch s.backendType {
-	case "ollama":
-		return s.ollamaRequest(prompt)
-	case "openai":
-		return s.openaiRequest(prompt)
-	}
-
-	return "", fmt.Errorf("unknown backend type: %s", s.backendType)
-}
-
-// ollamaRequest makes a request to Ollama using the dedicated client
-func (s *LLMService) ollamaRequest(prompt string) (string, error) {
-	if s.ollamaClient == nil {
-		return "", fmt.Errorf("ollama client not initialized")
+// llmResponse sends a conversation to the LLM and gets its answer, streaming it
+// to onDelta when set. The answer is metered into ctx's usage.
+func (s *LLMService) llmResponse(ctx context.Context, messages []LLMMessage, onDelta func(string) error) (*LLMResponse, error) {
+	if s.provider == nil {
+		return nil, ErrNoLLMProvider
 	}
 
-	// Use the client's SimpleChat method for basic interactions
-	response, err := s.ollamaClient.SimpleChat(s.llmModel, prompt)
-	if err != nil {
-		return "", fmt.Errorf("ollama request failed: %w", err)
+	req := LLMRequest{
+		Messages: messages,
+		JSON:     true,
 	}
-
-	return response, nil
-}
-
-// openaiRequest makes a request to OpenAI API using the official client
-func (s *LLMService) openaiRequest(prompt string) (string, error) {
-	if s.openaiClient == nil {
-		return "", fmt.Errorf("OpenAI client not initialized")
+	var resp *LLMResponse
+	var err error
+	if onDelta != nil {
+		resp, err = ChatStream(ctx, s.provider, req, onDelta)
+	} else {
+		resp, err = s.provider.Chat(ctx, req)
 	}
-
-	// Create JSON object response format
-	jsonObjectFormat := &shared.ResponseFormatJSONObjectParam{
-		Type: "json_object",
-	}
-
-	chatCompletion, err := s.openaiClient.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
-		Model: shared.ChatModel(s.llmModel),
-		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-			OfJSONObject: jsonObjectFormat,
-		},
-	})
 	if err != nil {
-		return "", fmt.Errorf("error making openai request: %w", err)
+		return nil, err
 	}
+	llmUsageFrom(ctx).add(resp)
 
-	if len(chatCompletion.Choices) == 0 {
-		return "", fmt.Errorf("no choices in openai response")
-	}
+	return resp, nil
+}
 
-	content := chatCompletion.Choices[0].Message.Content
-	if content == "" {
-		return "", fmt.Errorf("empty content in openai response")
-	}
+// ConjugateWithTemplates performs conjugation using the Go templates. Cancelling
+// ctx stops the LLM request and returns ctx's error; verbs the LLM does not
+// answer correctly, even after repair, are conjugated with the rules instead.
+func (s *LLMService) ConjugateWithTemplates(ctx context.Context, req models.ConjugateRequest) (*models.ConjugateResponse, error) {
+	return s.conjugate(ctx, req, nil)
+}
 
-	return content, nil
+// ConjugateStream performs conjugation like ConjugateWithTemplates, passing the
+// LLM's first answer to onDelta as it is generated. The returned conjugations
+// are validated and may differ from the streamed text.
+func (s *LLMService) ConjugateStream(ctx context.Context, req models.ConjugateRequest, onDelta func(string) error) (*models.ConjugateResponse, error) {
+	return s.conjugate(ctx, req, onDelta)
 }
 
-// ConjugateWithTemplates performs conjugation using the Go templates
-func (s *LLMService) ConjugateWithTemplates(req models.ConjugateRequest) (map[string]interface{}, error) {
+// conjugate renders the tense's prompt, asks the LLM and validates its answer,
+// streaming it to onDelta when set
+func (s *LLMService) conjugate(ctx context.Context, req models.ConjugateRequest, onDelta func(string) error) (*models.ConjugateResponse, error) {
 	log.Printf("Conjugation request - Sentence: '%s', Base forms: %v, Tense: %s",
 		req.Sentence, req.BaseForms, req.Tense)
 
 	// Determine template name
-	templateName := "presente" // default
-	switch req.Tense {
-	case "passato":
-		templateName = "passato"
-	case "futuro":
-		templateName = "futuro"
+	language, err := s.NormalizeLanguage(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	req.Language = language
+	tense, err := normalizeTense(language, req.Tense)
+	if err != nil {
+		return nil, err
+	}
+	req.Tense = tense
+	rag := s.languageRag(language)
+	templateKey := promptTemplateKey(language, tense)
+
+	cacheKey := llmCacheKey("conjugate", s.model, language, s.templateVersion(templateKey),
+		s.templateVersion(promptTemplateKey(language, repairTemplate)), rag.version, s.retriever.signature(), tense,
+		normalizeCacheText(req.Sentence), normalizeCacheList(req.BaseForms))
+	var cached models.ConjugateResponse
+	if s.cache.Get(cacheKey, &cached) {
+		llmUsageFrom(ctx).markCached()
+		log.Printf("Conjugation served from cache: %v", cached.Conjugations)
+		return &cached, nil
 	}
 
 	// Prepare template data
-	data := s.prepareTemplateData(req.Sentence, req.BaseForms, req.Tense)
+	data := s.prepareTemplateData(ctx, language, req.Sentence, req.BaseForms, tense)
 
 	// Render template
-	prompt, err := s.renderTemplate(templateName, data)
+	prompt, err := s.renderTemplate(templateKey, data)
 	if err != nil {
 		log.Printf("Error rendering template: %v", err)
 		return nil, fmt.Errorf("error rendering template: %w", err)
 	}
 
-	log.Printf("Generated prompt for %s tense", req.Tense)
+	log.Printf("Generated prompt for %s tense (%s)", req.Tense, language)
 
-	// Get LLM response
-	response, err := s.llmResponse(prompt)
+	// Get LLM response, repairing answers that do not match the requested verbs
+	verbs := uniqueVerbs(req.BaseForms)
+	accepted := make(map[string]string, len(verbs))
+	messages := languageMessages(language)
+	attempts, fallback, err := s.structuredResponse(ctx, language, prompt, verbs, onDelta, func(response string) []string {
+		return validateConjugationOutput(response, verbs, accepted, messages)
+	})
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		log.Printf("Error getting LLM response: %v", err)
-		// Return fallback result
-		fallback := make(map[string]interface{})
-		for _, verb := range req.BaseForms {
-			fallback[verb] = verb
+	}
+
+	result := resolveConjugations(req, rag.conjugator, accepted, attempts)
+	result.RagEntries = data.RagEntries
+	switch {
+	case err == nil && len(accepted) == len(verbs):
+		// Only complete answers are cached; verbs filled in by the rules or left
+		// as given are asked again next time
+		s.cacheAnswer(cacheKey, "conjugate", result, fallback)
+		log.Printf("Successfully conjugated verbs: %v (sources: %v)", result.Conjugations, result.Sources)
+	case attempts > 0:
+		log.Printf("Conjugated verbs partly without the LLM, not cached: %v (sources: %v)", result.Conjugations, result.Sources)
+	default:
+		log.Printf("Conjugated verbs with rules: %v", result.Conjugations)
+	}
+	return result, nil
+}
+
+// normalizeTense checks a request tense against the tenses of a supported
+// language. An empty tense means the language's first one (presente in Italian).
+func normalizeTense(language, tense string) (string, error) {
+	definition, _ := findLanguage(language)
+	if tense == "" {
+		return definition.tenses[0].id, nil
+	}
+	ids := make([]string, len(definition.tenses))
+	for i, supported := range definition.tenses {
+		if supported.id == tense {
+			return tense, nil
+		}
+		ids[i] = supported.id
+	}
+	return "", fmt.Errorf("%w: %q in %s (supported: %s)", ErrUnsupportedTense, tense, language, strings.Join(ids, ", "))
+}
+
+// tenseRagSection returns the RAG section describing a tense of a language
+func tenseRagSection(language, tense string) string {
+	definition, _ := findLanguage(language)
+	for _, supported := range definition.tenses {
+		if supported.id == tense {
+			return supported.ragSection
+		}
+	}
+	return ""
+}
+
+// NormalizeLanguage checks a language code against the supported languages. An
+// empty code means the default language.
+func (s *LLMService) NormalizeLanguage(code string) (string, error) {
+	return normalizeLanguage(code, s.defaultLanguage)
+}
+
+// SupportedTenses lists the tenses of a supported language accepted by
+// ConjugateWithTemplates, described by the language's RAG knowledge when it has
+// a description for them
+func (s *LLMService) SupportedTenses(language string) []models.TenseInfo {
+	definition, ok := findLanguage(language)
+	if !ok {
+		return nil
+	}
+
+	ragData := s.languageRag(language).data
+	tenses := make([]models.TenseInfo, len(definition.tenses))
+	for i, tense := range definition.tenses {
+		tenses[i] = models.TenseInfo{ID: tense.id, Name: tense.name}
+		if section, ok := ragData[tense.ragSection].(map[string]interface{}); ok {
+			tenses[i].Description, _ = section["description"].(string)
 		}
-		return fallback, nil
 	}
+	return tenses
+}
 
-	// Parse JSON response
-	var conjugations map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &conjugations); err != nil {
-		log.Printf("Error parsing LLM response: %v", err)
-		// Return fallback result
-		fallback := make(map[string]interface{})
-		for _, verb := range req.BaseForms {
-			fallback[verb] = verb
+// SupportedLanguages lists the languages of the AI language services, the
+// default one first, with their tenses
+func (s *LLMService) SupportedLanguages() []models.LanguageInfo {
+	languages := make([]models.LanguageInfo, 0, len(supportedLanguages))
+	for _, language := range supportedLanguages {
+		info := models.LanguageInfo{Code: language.code, Name: language.name, Tenses: s.SupportedTenses(language.code)}
+		if language.code == s.defaultLanguage {
+			languages = append([]models.LanguageInfo{info}, languages...)
+		} else {
+			languages = append(languages, info)
 		}
-		return fallback, nil
 	}
+	return languages
+}
+
+// CorrectWithTemplate performs sentence correction using the Go template.
+// Cancelling ctx stops the LLM request and returns ctx's error; when the LLM
+// gives no valid correction the sentence is returned unchanged.
+func (s *LLMService) CorrectWithTemplate(ctx context.Context, req models.CorrectRequest) (*models.CorrectResponse, error) {
+	return s.correct(ctx, req, nil)
+}
 
-	log.Printf("Successfully conjugated verbs: %v", conjugations)
-	return conjugations, nil
+// CorrectStream performs sentence correction like CorrectWithTemplate, passing
+// the LLM's first answer to onDelta as it is generated.
+func (s *LLMService) CorrectStream(ctx context.Context, req models.CorrectRequest, onDelta func(string) error) (*models.CorrectResponse, error) {
+	return s.correct(ctx, req, onDelta)
 }
 
-// CorrectWithTemplate performs sentence correction using the Go template
-func (s *LLMService) CorrectWithTemplate(req models.CorrectRequest) (map[string]interface{}, error) {
-	log.Printf("Correction request - Sentence: '%s'", req.Sentence)
+// correct renders the correction prompt and asks the LLM, streaming its answer
+// to onDelta when set
+func (s *LLMService) correct(ctx context.Context, req models.CorrectRequest, onDelta func(string) error) (*models.CorrectResponse, error) {
+	log.Printf("Correction request - Sentence: '%s', Language: %s", req.Sentence, req.Language)
+
+	language, err := s.NormalizeLanguage(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	templateKey := promptTemplateKey(language, correctTemplate)
+
+	cacheKey := llmCacheKey("correct", s.model, language, s.templateVersion(templateKey),
+		s.templateVersion(promptTemplateKey(language, repairTemplate)), normalizeCacheText(req.Sentence))
+	var cached models.CorrectResponse
+	if s.cache.Get(cacheKey, &cached) {
+		llmUsageFrom(ctx).markCached()
+		log.Printf("Correction served from cache: '%s'", cached.CorrectedSentence)
+		return &cached, nil
+	}
 
 	// Prepare template data
 	data := TemplateData{
@@ -390,7 +594,7 @@ func (s *LLMService) CorrectWithTemplate(req models.CorrectRequest) (map[string]
 	}
 
 	// Render template
-	prompt, err := s.renderTemplate("correct_sentence", data)
+	prompt, err := s.renderTemplate(templateKey, data)
 	if err != nil {
 		log.Printf("Error rendering correction template: %v", err)
 		return nil, fmt.Errorf("error rendering template: %w", err)
@@ -398,110 +602,242 @@ func (s *LLMService) CorrectWithTemplate(req models.CorrectRequest) (map[string]
 
 	log.Printf("Generated correction prompt")
 
-	// Get LLM response
-	response, err := s.llmResponse(prompt)
-	if err != nil {
-		log.Printf("Error getting LLM correction response: %v", err)
-		return map[string]interface{}{
-			"corrected_sentence": req.Sentence,
-		}, nil
+	// Get LLM response, repairing answers that are not a single corrected sentence
+	var correctedSentence string
+	messages := languageMessages(language)
+	attempts, fallback, err := s.structuredResponse(ctx, language, prompt, []string{correctionKey}, onDelta, func(response string) []string {
+		sentence, problems := validateCorrectionOutput(response, messages)
+		if sentence != "" {
+			correctedSentence = sentence
+		}
+		return problems
+	})
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if correctedSentence == "" {
+		if err != nil {
+			log.Printf("Error getting LLM correction response: %v", err)
+		}
+		log.Printf("No valid correction from the LLM, returning the sentence unchanged")
+		return &models.CorrectResponse{Language: language, CorrectedSentence: req.Sentence, Source: SourceInput, Attempts: attempts}, nil
 	}
-
-	// For correction, the response should be just the corrected sentence
-	correctedSentence := strings.TrimSpace(response)
-	// Remove any quotes if present
-	correctedSentence = strings.Trim(correctedSentence, "\"")
 
 	log.Printf("Successfully corrected sentence: '%s' -> '%s'", req.Sentence, correctedSentence)
 
-	return map[string]interface{}{
-		"corrected_sentence": correctedSentence,
-	}, nil
+	result := &models.CorrectResponse{Language: language, CorrectedSentence: correctedSentence, Source: SourceModel, Attempts: attempts}
+	s.cacheAnswer(cacheKey, "correct", result, fallback)
+	return result, nil
 }
 
-// UpdateRagKnowledge updates the RAG knowledge in memory and optionally saves to S3
-func (s *LLMService) UpdateRagKnowledge(knowledge map[string]interface{}, saveToS3 bool) error {
-	// Update in-memory knowledge
-	s.ragData = knowledge
-	log.Printf("RAG knowledge updated in memory")
+// cacheAnswer caches a result of the LLM. Answers of a fallback provider are not
+// cached: the key names the primary model, whose answer is wanted once it recovers.
+func (s *LLMService) cacheAnswer(key, kind string, result interface{}, fallback bool) {
+	if fallback {
+		log.Printf("[LLM-CACHE] Not caching %s answer of a fallback provider", kind)
+		return
+	}
+	s.cache.Put(key, kind, result)
+}
+
+// CacheStats returns the statistics of the result cache
+func (s *LLMService) CacheStats() models.LLMCacheStatsResponse {
+	return s.cache.Stats()
+}
+
+// PurgeCache drops all cached conjugation and correction results
+func (s *LLMService) PurgeCache() {
+	s.cache.Purge()
+}
 
-	// Save to S3 if enabled and requested
-	if saveToS3 && s.s3Storage.IsEnabled() {
+// UpdateRagKnowledge replaces the RAG knowledge of a language after checking it
+// against the schema; a *RagValidationError lists the problems of a document
+// that does not match. The change is recorded with the acting user.
+func (s *LLMService) UpdateRagKnowledge(language string, knowledge map[string]interface{}, save bool, userID string) error {
+	s.ragEditMu.Lock()
+	defer s.ragEditMu.Unlock()
+
+	before := s.languageRag(language)
+	if err := s.updateRagKnowledge(language, knowledge, save); err != nil {
+		return err
+	}
+	s.recordRagChange(language, "update", "", userID, before)
+	return nil
+}
+
+// PatchRagKnowledge applies a JSON Patch to the RAG knowledge of a language,
+// e.g. to add one irregular verb, and returns the patched knowledge. The patch
+// is all or nothing and the result must match the schema.
+func (s *LLMService) PatchRagKnowledge(language string, operations []models.RagPatchOperation, save bool, userID string) (map[string]interface{}, error) {
+	s.ragEditMu.Lock()
+	defer s.ragEditMu.Unlock()
+
+	before := s.languageRag(language)
+	current := s.GetRagKnowledge(language)
+	if current == nil {
+		return nil, fmt.Errorf("no RAG knowledge to patch")
+	}
+	patched, err := applyRagPatch(current, operations)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateRagKnowledge(language, patched, save); err != nil {
+		return nil, err
+	}
+	log.Printf("%s RAG knowledge patched with %d operations", language, len(operations))
+	s.recordRagChange(language, "patch", "", userID, before)
+	return patched, nil
+}
+
+// updateRagKnowledge validates and applies new RAG knowledge; the caller holds
+// ragEditMu. The knowledge is saved before it is applied, so a failed save
+// leaves the live knowledge unchanged.
+func (s *LLMService) updateRagKnowledge(language string, knowledge map[string]interface{}, save bool) error {
+	if problems := validateRagKnowledge(language, knowledge); len(problems) > 0 {
+		return &RagValidationError{Problems: problems}
+	}
+
+	// Save to the storage if enabled and requested
+	if save && s.ragStore != nil {
 		ctx := context.Background()
-		if err := s.s3Storage.PutRagKnowledge(ctx, knowledge); err != nil {
-			return fmt.Errorf("error saving RAG knowledge to S3: %w", err)
+		if err := s.ragStore.PutRagKnowledge(ctx, ragFile(language), knowledge); err != nil {
+			return fmt.Errorf("error saving RAG knowledge to %s: %w", s.ragStore.Name(), err)
 		}
-		log.Printf("RAG knowledge saved to S3")
+		log.Printf("%s RAG knowledge saved to %s", language, s.ragStore.Name())
 	}
 
+	// Update in-memory knowledge
+	s.setRagData(language, knowledge)
+	log.Printf("%s RAG knowledge updated in memory", language)
+
 	return nil
 }
 
+// ValidateRagKnowledge checks a RAG knowledge document against the schema of a
+// language without applying it
+func (s *LLMService) ValidateRagKnowledge(language string, knowledge map[string]interface{}) []models.RagProblem {
+	return validateRagKnowledge(language, knowledge)
+}
+
+// RagKnowledgeSchema describes the RAG knowledge document of a language
+func (s *LLMService) RagKnowledgeSchema(language string) models.RagSchemaResponse {
+	return ragSchema(language)
+}
+
 // BackupRagKnowledge creates a timestamped backup of the current RAG knowledge
-func (s *LLMService) BackupRagKnowledge() error {
-	if !s.s3Storage.IsEnabled() {
-		return fmt.Errorf("S3 storage is not enabled for backups")
+// of a language, then prunes the backups beyond the retention
+func (s *LLMService) BackupRagKnowledge(language string) error {
+	if s.ragStore == nil {
+		return ErrRagStorageDisabled
 	}
 
-	if s.ragData == nil {
+	ragData := s.languageRag(language).data
+	if ragData == nil {
 		return fmt.Errorf("no RAG knowledge to backup")
 	}
 
 	ctx := context.Background()
-	return s.s3Storage.BackupRagKnowledge(ctx, s.ragData)
+	if err := s.ragStore.BackupRagKnowledge(ctx, ragFile(language), ragData); err != nil {
+		return err
+	}
+	if s.backupRetention > 0 {
+		pruned, err := s.ragStore.PruneRagKnowledgeBackups(ctx, ragFile(language), s.backupRetention)
+		if err != nil {
+			log.Printf("[RAG] Warning: failed to prune %s RAG knowledge backups: %v", language, err)
+		} else if pruned > 0 {
+			log.Printf("[RAG] Pruned %d %s RAG knowledge backups beyond the newest %d", pruned, language, s.backupRetention)
+		}
+	}
+	return nil
 }
 
-// ListRagKnowledgeBackups lists all available RAG knowledge backups
-func (s *LLMService) ListRagKnowledgeBackups() ([]S3Object, error) {
-	if !s.s3Storage.IsEnabled() {
-		return nil, fmt.Errorf("S3 storage is not enabled")
+// ListRagKnowledgeBackups lists all available RAG knowledge backups of a language
+func (s *LLMService) ListRagKnowledgeBackups(language string) ([]S3Object, error) {
+	if s.ragStore == nil {
+		return nil, ErrRagStorageDisabled
 	}
 
 	ctx := context.Background()
-	return s.s3Storage.ListRagKnowledgeVersions(ctx)
+	return s.ragStore.ListRagKnowledgeVersions(ctx, ragFile(language))
 }
 
-// RestoreRagKnowledgeFromBackup restores RAG knowledge from a specific backup
-func (s *LLMService) RestoreRagKnowledgeFromBackup(backupKey string) error {
-	if !s.s3Storage.IsEnabled() {
-		return fmt.Errorf("S3 storage is not enabled")
+// RestoreRagKnowledgeFromBackup restores the RAG knowledge of a language from a
+// specific backup: it is saved as the knowledge file of the storage, so it
+// survives reloads and restarts, then put in use. The change is recorded with
+// the acting user.
+func (s *LLMService) RestoreRagKnowledgeFromBackup(language, backupKey, userID string) error {
+	if s.ragStore == nil {
+		return ErrRagStorageDisabled
 	}
 
 	ctx := context.Background()
-	knowledge, err := s.s3Storage.RestoreRagKnowledge(ctx, backupKey)
+	knowledge, err := s.ragStore.GetRagKnowledgeVersion(ctx, ragFile(language), backupKey)
 	if err != nil {
 		return fmt.Errorf("error restoring from backup: %w", err)
 	}
 
-	s.ragData = knowledge
-	log.Printf("RAG knowledge restored from backup: %s", backupKey)
+	s.ragEditMu.Lock()
+	defer s.ragEditMu.Unlock()
+	before := s.languageRag(language)
+	if err := s.updateRagKnowledge(language, knowledge, true); err != nil {
+		return err
+	}
+	log.Printf("%s RAG knowledge restored from backup: %s", language, backupKey)
+	s.recordRagChange(language, "restore", backupKey, userID, before)
 	return nil
 }
 
-// GetRagKnowledge returns a copy of the current RAG knowledge
-func (s *LLMService) GetRagKnowledge() map[string]interface{} {
-	if s.ragData == nil {
+// GetRagKnowledge returns a copy of the current RAG knowledge of a language
+func (s *LLMService) GetRagKnowledge(language string) map[string]interface{} {
+	ragData := s.languageRag(language).data
+	if ragData == nil {
 		return nil
 	}
 
 	// Return a copy to prevent external modifications
-	data, _ := json.Marshal(s.ragData)
+	data, _ := json.Marshal(ragData)
 	var copy map[string]interface{}
 	json.Unmarshal(data, &copy)
 	return copy
 }
 
-// ReloadRagKnowledge reloads RAG knowledge from S3 or local file
-func (s *LLMService) ReloadRagKnowledge() error {
-	return s.loadRagData()
+// ReloadRagKnowledge reloads the RAG knowledge of a language from S3 or local
+// file; the change is recorded with the acting user
+func (s *LLMService) ReloadRagKnowledge(language, userID string) error {
+	s.ragEditMu.Lock()
+	defer s.ragEditMu.Unlock()
+
+	before := s.languageRag(language)
+	if err := s.loadRagData(language); err != nil {
+		return err
+	}
+	s.recordRagChange(language, "reload", "", userID, before)
+	return nil
+}
+
+// ragFile returns the file the RAG knowledge of a language is stored in
+func ragFile(language string) string {
+	definition, ok := findLanguage(language)
+	if !ok {
+		return ""
+	}
+	return definition.ragFile
+}
+
+// RagStorageName describes the RAG knowledge storage, empty when disabled
+func (s *LLMService) RagStorageName() string {
+	if s.ragStore == nil {
+		return ""
+	}
+	return s.ragStore.Name()
 }
 
-// CheckS3Health checks the health of S3 connection
-func (s *LLMService) CheckS3Health() error {
-	if !s.s3Storage.IsEnabled() {
-		return fmt.Errorf("S3 storage is not enabled")
+// CheckRagStorageHealth checks that the RAG knowledge storage is reachable
+func (s *LLMService) CheckRagStorageHealth() error {
+	if s.ragStore == nil {
+		return ErrRagStorageDisabled
 	}
 
 	ctx := context.Background()
-	return s.s3Storage.CheckHealth(ctx)
+	return s.ragStore.CheckHealth(ctx)
 }
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LLMCache keeps conjugation and correction results in a least recently used
+// memory cache, optionally backed by the llm_cache table. A nil cache is
+// disabled: lookups miss and stores are ignored.
+type LLMCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	persist  bool
+	order    *list.List // Most recently used first
+	entries  map[string]*list.Element
+
+	hits, misses, storeHits, evictions uint64
+}
+
+// llmCacheItem is one result held in memory, encoded as JSON so callers always
+// decode their own copy
+type llmCacheItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time // Zero when the result does not expire
+}
+
+// NewLLMCache creates the result cache, or returns nil when it is disabled
+func NewLLMCache(cfg config.LLMCacheConfig) *LLMCache {
+	if !cfg.Enabled || cfg.Size <= 0 {
+		log.Printf("[LLM-CACHE] Result cache disabled")
+		return nil
+	}
+
+	cache := &LLMCache{
+		capacity: cfg.Size,
+		ttl:      cfg.TTL,
+		persist:  cfg.Persist && database.DB != nil,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if cache.persist {
+		cache.pruneStore()
+	}
+
+	log.Printf("[LLM-CACHE] Result cache enabled - Size: %d, TTL: %s, Persistent: %t", cache.capacity, cache.ttl, cache.persist)
+	return cache
+}
+
+// llmCacheKey hashes the parts identifying a request
+func llmCacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeCacheText lowercases text and collapses its whitespace so trivially
+// different spellings of a phrase share a cache entry
+func normalizeCacheText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// normalizeCacheList normalizes and sorts a list of words
+func normalizeCacheList(words []string) string {
+	normalized := make([]string, len(words))
+	for i, word := range words {
+		normalized[i] = normalizeCacheText(word)
+	}
+	sort.Strings(normalized)
+	return strings.Join(normalized, ",")
+}
+
+// Get decodes the cached result for key into dest, reporting whether there was one
+func (c *LLMCache) Get(key string, dest interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		item := element.Value.(*llmCacheItem)
+		if c.expired(item.expiresAt) {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		} else {
+			c.order.MoveToFront(element)
+			c.hits++
+			data := item.data
+			c.mu.Unlock()
+			return c.decode(data, dest)
+		}
+	}
+	c.mu.Unlock()
+
+	if c.persist {
+		if data, expiresAt, ok := c.loadStored(key); ok && c.decode(data, dest) {
+			c.mu.Lock()
+			c.hits++
+			c.storeHits++
+			c.add(key, data, expiresAt)
+			c.mu.Unlock()
+			return true
+		}
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+	return false
+}
+
+// Put caches result under key
+func (c *LLMCache) Put(key, kind string, result interface{}) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("[LLM-CACHE] Error encoding result: %v", err)
+		return
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	c.add(key, data, expiresAt)
+	c.mu.Unlock()
+
+	if c.persist {
+		c.store(key, kind, data, expiresAt)
+	}
+}
+
+// Purge drops every cached result, in memory and in the database
+func (c *LLMCache) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.mu.Unlock()
+
+	if c.persist {
+		if err := database.DB.Where("1 = 1").Delete(&models.LLMCacheEntry{}).Error; err != nil {
+			log.Printf("[LLM-CACHE] Error purging stored results: %v", err)
+			return
+		}
+	}
+	log.Printf("[LLM-CACHE] Cache purged")
+}
+
+// Stats returns the cache's hit and miss statistics
+func (c *LLMCache) Stats() models.LLMCacheStatsResponse {
+	if c == nil {
+		return models.LLMCacheStatsResponse{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := models.LLMCacheStatsResponse{
+		Enabled:    true,
+		Persistent: c.persist,
+		Entries:    c.order.Len(),
+		Capacity:   c.capacity,
+		TTL:        c.ttl.String(),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		StoreHits:  c.storeHits,
+		Evictions:  c.evictions,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// add inserts or refreshes a result in memory, evicting the least recently
+// used results beyond capacity. The caller holds c.mu.
+func (c *LLMCache) add(key string, data []byte, expiresAt time.Time) {
+	if element, ok := c.entries[key]; ok {
+		element.Value = &llmCacheItem{key: key, data: data, expiresAt: expiresAt}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&llmCacheItem{key: key, data: data, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*llmCacheItem).key)
+		c.evictions++
+	}
+}
+
+// decode unmarshals a cached result into dest
+func (c *LLMCache) decode(data []byte, dest interface{}) bool {
+	if err := json.Unmarshal(data, dest); err != nil {
+		log.Printf("[LLM-CACHE] Error decoding cached result: %v", err)
+		return false
+	}
+	return true
+}
+
+// expired reports whether an expiry time has passed
+func (c *LLMCache) expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
+
+// loadStored reads a result from the database, deleting it when it has expired
+func (c *LLMCache) loadStored(key string) ([]byte, time.Time, bool) {
+	var entry models.LLMCacheEntry
+	if err := database.DB.Where("cache_key = ?", key).First(&entry).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[LLM-CACHE] Error reading stored result: %v", err)
+		}
+		return nil, time.Time{}, false
+	}
+
+	var expiresAt time.Time
+	if entry.ExpiresAt != nil {
+		expiresAt = *entry.ExpiresAt
+	}
+	if c.expired(expiresAt) {
+		database.DB.Delete(&entry)
+		return nil, time.Time{}, false
+	}
+	return []byte(entry.Result), expiresAt, true
+}
+
+// store writes a result to the database, replacing an older one with the same key
+func (c *LLMCache) store(key, kind string, data []byte, expiresAt time.Time) {
+	entry := models.LLMCacheEntry{CacheKey: key, Kind: kind, Result: string(data)}
+	if !expiresAt.IsZero() {
+		entry.ExpiresAt = &expiresAt
+	}
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error; err != nil {
+		log.Printf("[LLM-CACHE] Error storing result: %v", err)
+	}
+}
+
+// pruneStore deletes expired results from the database
+func (c *LLMCache) pruneStore() {
+	result := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.LLMCacheEntry{})
+	if result.Error != nil {
+		log.Printf("[LLM-CACHE] Error pruning expired results: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[LLM-CACHE] Pruned %d expired results", result.RowsAffected)
+	}
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+// evalCorrectGroup groups the correction cases of a language in an evaluation run
+const evalCorrectGroup = "correct"
+
+// evalNoAnswer is the error of a case the LLM gave no answer to, e.g. because
+// the backend is unreachable, and that the fallback answered instead
+const evalNoAnswer = "no answer from the LLM, the fallback answered"
+
+// evalGroup names the group of a case: the language and the tense of a
+// conjugation, or the language and "correct" for a correction (it/presente)
+func evalGroup(language, group string) string {
+	return language + "/" + group
+}
+
+// LoadEvalDataset reads a golden dataset of conjugation and correction cases,
+// one JSON case per line. Blank lines and lines starting with # are skipped.
+func LoadEvalDataset(path string) ([]models.EvalCase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening dataset: %w", err)
+	}
+	defer file.Close()
+
+	var cases []models.EvalCase
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var c models.EvalCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid JSON: %w", path, line, err)
+		}
+		if err := validateEvalCase(&c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if previous, ok := seen[c.ID]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate case id %q (first on line %d)", path, line, c.ID, previous)
+		}
+		seen[c.ID] = line
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading dataset: %w", err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("dataset %s has no cases", path)
+	}
+	return cases, nil
+}
+
+// validateEvalCase checks a dataset case, normalizing its language and tense.
+// Cases without a language are Italian.
+func validateEvalCase(c *models.EvalCase) error {
+	if c.ID == "" {
+		return errors.New("case without id")
+	}
+	if strings.TrimSpace(c.Sentence) == "" {
+		return fmt.Errorf("case %s: sentence is required", c.ID)
+	}
+	language, err := normalizeLanguage(c.Language, LanguageItalian)
+	if err != nil {
+		return fmt.Errorf("case %s: %w", c.ID, err)
+	}
+	c.Language = language
+
+	switch c.Kind {
+	case models.EvalKindConjugate:
+		tense, err := normalizeTense(c.Language, c.Tense)
+		if err != nil {
+			return fmt.Errorf("case %s: %w", c.ID, err)
+		}
+		c.Tense = tense
+		if len(c.BaseForms) == 0 {
+			return fmt.Errorf("case %s: base_forms is required", c.ID)
+		}
+		for _, verb := range c.BaseForms {
+			if _, ok := c.Expected[verb]; !ok {
+				return fmt.Errorf("case %s: no expected form for %q", c.ID, verb)
+			}
+		}
+	case models.EvalKindCorrect:
+		if strings.TrimSpace(c.ExpectedSentence) == "" {
+			return fmt.Errorf("case %s: expected_sentence is required", c.ID)
+		}
+	default:
+		return fmt.Errorf("case %s: unknown kind %q (supported: %s, %s)", c.ID, c.Kind, models.EvalKindConjugate, models.EvalKindCorrect)
+	}
+	return nil
+}
+
+// LoadPromptTemplateFiles replaces the templates in use with the
+// <language>/<name>.tmpl files found in dir, laid out like the embedded
+// defaults, so candidate prompts can be evaluated before they are saved. It
+// returns the templates replaced, as language/name.
+func (s *LLMService) LoadPromptTemplateFiles(dir string) ([]string, error) {
+	sources := make(map[string]string)
+	var names, expected []string
+	for _, language := range supportedLanguages {
+		for _, name := range promptTemplateNames(language.code) {
+			key := promptTemplateKey(language.code, name)
+			expected = append(expected, key)
+			content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)+".tmpl"))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("error reading template %s: %w", key, err)
+			}
+			sources[key] = string(content)
+			names = append(names, key)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no prompt template files in %s (expected one of: %s.tmpl)",
+			dir, strings.Join(expected, ".tmpl, "))
+	}
+
+	if err := s.installTemplates(sources); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// RunEval runs the cases through the conjugation and correction pipeline, on up
+// to concurrency cases at a time, and summarizes the results. progress, when
+// set, is called after each case.
+func (s *LLMService) RunEval(ctx context.Context, cases []models.EvalCase, concurrency int, progress func(models.EvalCaseResult)) *models.EvalRun {
+	run := &models.EvalRun{
+		Provider:  "none",
+		Model:     s.model,
+		Templates: make(map[string]string),
+		StartedAt: time.Now(),
+		Results:   make([]models.EvalCaseResult, len(cases)),
+	}
+	if s.provider != nil {
+		run.Provider = s.provider.Name()
+	}
+	for _, language := range supportedLanguages {
+		for _, name := range promptTemplateNames(language.code) {
+			key := promptTemplateKey(language.code, name)
+			run.Templates[key] = s.templateVersion(key)
+		}
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				run.Results[index] = s.evalCase(ctx, cases[index])
+				if progress != nil {
+					progressMu.Lock()
+					progress(run.Results[index])
+					progressMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range cases {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	run.Summary, run.Groups = summarizeEval(run.Results)
+	return run
+}
+
+// evalCase runs a single case and scores its answer
+func (s *LLMService) evalCase(ctx context.Context, c models.EvalCase) models.EvalCaseResult {
+	result := models.EvalCaseResult{ID: c.ID, Kind: c.Kind, Language: c.Language, Group: evalGroup(c.Language, c.Tense)}
+	usage := &LLMUsage{}
+	ctx = WithLLMUsage(ctx, usage)
+	start := time.Now()
+
+	if c.Kind == models.EvalKindConjugate {
+		result.Expected = c.Expected
+		resp, err := s.ConjugateWithTemplates(ctx, models.ConjugateRequest{Sentence: c.Sentence, BaseForms: c.BaseForms, Language: c.Language, Tense: c.Tense})
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Got, result.Sources, result.Attempts, result.RagEntries = resp.Conjugations, resp.Sources, resp.Attempts, resp.RagEntries
+			for _, verb := range c.BaseForms {
+				if !evalAnswerMatches(resp.Conjugations[verb], c.Expected[verb]) {
+					result.Wrong = append(result.Wrong, verb)
+				}
+			}
+			result.Score = float64(len(c.BaseForms)-len(result.Wrong)) / float64(len(c.BaseForms))
+		}
+	} else {
+		result.Group = evalGroup(c.Language, evalCorrectGroup)
+		result.ExpectedSentence = c.ExpectedSentence
+		resp, err := s.CorrectWithTemplate(ctx, models.CorrectRequest{Sentence: c.Sentence, Language: c.Language})
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.GotSentence, result.Attempts = resp.CorrectedSentence, resp.Attempts
+			result.Sources = map[string]string{correctionKey: resp.Source}
+			for _, accepted := range append([]string{c.ExpectedSentence}, c.Accepted...) {
+				if evalAnswerMatches(resp.CorrectedSentence, accepted) {
+					result.Score = 1
+					break
+				}
+			}
+		}
+	}
+
+	// The rules or the input answer when the LLM call fails, which says nothing
+	// of the model: the case is an error and scores nothing
+	if result.Error == "" && result.Attempts == 0 {
+		result.Error = evalNoAnswer
+		result.Score = 0
+	}
+
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.Passed = result.Error == "" && result.Score == 1
+	result.LLMCalls, result.PromptTokens, result.CompletionTokens = usage.Calls, usage.PromptTokens, usage.CompletionTokens
+	return result
+}
+
+// evalAnswerMatches compares an answer with the expected one, ignoring case,
+// spacing and final punctuation
+func evalAnswerMatches(got, expected string) bool {
+	normalize := func(text string) string {
+		return strings.TrimRight(normalizeCacheText(text), ".!? ")
+	}
+	return normalize(got) == normalize(expected)
+}
+
+// summarizeEval aggregates the results of the whole run and of each group, by
+// language and within each the tenses in their usual order, corrections last
+func summarizeEval(results []models.EvalCaseResult) (models.EvalGroupSummary, []models.EvalGroupSummary) {
+	byGroup := make(map[string][]models.EvalCaseResult)
+	for _, result := range results {
+		byGroup[result.Group] = append(byGroup[result.Group], result)
+	}
+
+	var groups []models.EvalGroupSummary
+	var order []string
+	for _, language := range supportedLanguages {
+		for _, tense := range language.tenses {
+			order = append(order, evalGroup(language.code, tense.id))
+		}
+		order = append(order, evalGroup(language.code, evalCorrectGroup))
+	}
+	for _, group := range order {
+		if len(byGroup[group]) > 0 {
+			groups = append(groups, summarizeEvalGroup(group, byGroup[group]))
+		}
+	}
+	return summarizeEvalGroup("all", results), groups
+}
+
+// summarizeEvalGroup aggregates the results of a group
+func summarizeEvalGroup(group string, results []models.EvalCaseResult) models.EvalGroupSummary {
+	summary := models.EvalGroupSummary{Group: group, Cases: len(results)}
+	if len(results) == 0 {
+		return summary
+	}
+
+	var score float64
+	answers, modelAnswers := 0, 0
+	latencies := make([]int64, 0, len(results))
+	for _, result := range results {
+		if result.Passed {
+			summary.Passed++
+		}
+		if result.Error != "" {
+			summary.Errors++
+		}
+		score += result.Score
+		for _, source := range result.Sources {
+			answers++
+			if source == SourceModel {
+				modelAnswers++
+			}
+		}
+		summary.Tokens += result.PromptTokens + result.CompletionTokens
+		latencies = append(latencies, result.LatencyMs)
+	}
+
+	summary.Accuracy = float64(summary.Passed) / float64(len(results))
+	summary.ItemAccuracy = score / float64(len(results))
+	if answers > 0 {
+		summary.ModelShare = float64(modelAnswers) / float64(answers)
+	}
+	summary.Latency = evalLatency(latencies)
+	return summary
+}
+
+// evalLatency computes nearest-rank latency percentiles
+func evalLatency(latencies []int64) models.EvalLatency {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) int64 {
+		rank := (p*len(latencies) + 99) / 100
+		return latencies[max(rank, 1)-1]
+	}
+	return models.EvalLatency{
+		P50: percentile(50),
+		P90: percentile(90),
+		P95: percentile(95),
+		P99: percentile(99),
+		Max: latencies[len(latencies)-1],
+	}
+}
+
+// SaveEvalRun writes an evaluation run as indented JSON
+func SaveEvalRun(path string, run *models.EvalRun) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding evaluation run: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing evaluation run: %w", err)
+	}
+	return nil
+}
+
+// LoadEvalRun reads an evaluation run saved by SaveEvalRun
+func LoadEvalRun(path string) (*models.EvalRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading evaluation run: %w", err)
+	}
+	var run models.EvalRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("error decoding evaluation run %s: %w", path, err)
+	}
+	return &run, nil
+}
+
+// DiffEvalRuns compares a run with a baseline: accuracy, latency and tokens of
+// each group, and the cases whose outcome changed
+func DiffEvalRuns(base, run *models.EvalRun) *models.EvalDiff {
+	diff := &models.EvalDiff{
+		BaseLabel: base.Label,
+		Label:     run.Label,
+		BaseModel: base.Model,
+		Model:     run.Model,
+		Summary:   diffEvalGroup(&base.Summary, &run.Summary),
+	}
+
+	// Runs saved by other versions may not use the same templates
+	for name, version := range run.Templates {
+		if base.Templates[name] != version {
+			diff.ChangedTemplates = append(diff.ChangedTemplates, name)
+		}
+	}
+	for name := range base.Templates {
+		if _, ok := run.Templates[name]; !ok {
+			diff.ChangedTemplates = append(diff.ChangedTemplates, name)
+		}
+	}
+	sort.Strings(diff.ChangedTemplates)
+
+	baseGroups := make(map[string]*models.EvalGroupSummary, len(base.Groups))
+	for i := range base.Groups {
+		baseGroups[base.Groups[i].Group] = &base.Groups[i]
+	}
+	for i := range run.Groups {
+		group := run.Groups[i].Group
+		diff.Groups = append(diff.Groups, diffEvalGroup(baseGroups[group], &run.Groups[i]))
+		delete(baseGroups, group)
+	}
+	for i := range base.Groups {
+		if _, ok := baseGroups[base.Groups[i].Group]; ok {
+			diff.Groups = append(diff.Groups, diffEvalGroup(&base.Groups[i], nil))
+		}
+	}
+
+	baseResults := make(map[string]*models.EvalCaseResult, len(base.Results))
+	for i := range base.Results {
+		baseResults[base.Results[i].ID] = &base.Results[i]
+	}
+	for i := range run.Results {
+		result := &run.Results[i]
+		previous, ok := baseResults[result.ID]
+		delete(baseResults, result.ID)
+		if change := evalCaseChange(previous, result, ok); change != "" {
+			diff.Cases = append(diff.Cases, models.EvalCaseDiff{ID: result.ID, Change: change, Base: previous, Run: result})
+		}
+	}
+	for i := range base.Results {
+		if previous, ok := baseResults[base.Results[i].ID]; ok {
+			diff.Cases = append(diff.Cases, models.EvalCaseDiff{ID: previous.ID, Change: "removed", Base: previous})
+		}
+	}
+	return diff
+}
+
+// diffEvalGroup compares a group of two runs; either side may be missing
+func diffEvalGroup(base, run *models.EvalGroupSummary) models.EvalGroupDiff {
+	var diff models.EvalGroupDiff
+	if base == nil {
+		diff.MissingInBaseline = true
+	} else {
+		diff.Group = base.Group
+		diff.BaseAccuracy, diff.BaseItemAccuracy = base.Accuracy, base.ItemAccuracy
+		diff.BaseP50, diff.BaseP95, diff.BaseTokens = base.Latency.P50, base.Latency.P95, base.Tokens
+	}
+	if run == nil {
+		diff.MissingInRun = true
+	} else {
+		diff.Group = run.Group
+		diff.Accuracy, diff.ItemAccuracy = run.Accuracy, run.ItemAccuracy
+		diff.P50, diff.P95, diff.Tokens = run.Latency.P50, run.Latency.P95, run.Tokens
+	}
+	return diff
+}
+
+// evalCaseChange names how a case's outcome changed, or returns "" when it did not
+func evalCaseChange(base, run *models.EvalCaseResult, inBaseline bool) string {
+	switch {
+	case !inBaseline:
+		return "added"
+	case !base.Passed && run.Passed:
+		return "fixed"
+	case base.Passed && !run.Passed:
+		return "regressed"
+	case base.Score != run.Score || base.GotSentence != run.GotSentence || !equalStringMaps(base.Got, run.Got):
+		return "changed"
+	}
+	return ""
+}
+
+// equalStringMaps reports whether two string maps hold the same entries
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+// Origins of the entries of a conjugation or correction response
+const (
+	SourceModel = "model" // Returned by the LLM and accepted by validation
+	SourceRules = "rules" // Computed by the rule-based conjugator
+	SourceInput = "input" // Left as the request gave it
+)
+
+// correctionKey is the only key of a correction answer
+const correctionKey = "corrected_sentence"
+
+// outputMessages words the problems of an LLM answer in a language, for its
+// repair prompt. The formats take the offending key, verb or value name.
+type outputMessages struct {
+	notObject     string // The answer is not a JSON object
+	unknownVerb   string // A key is not one of the requested verbs
+	missingVerb   string // A requested verb is missing
+	unexpectedKey string // A key of a correction answer is not expected
+	missingKey    string // The key of a correction answer is missing
+	multiline     string // The corrected sentence spans several lines
+	notString     string // A value is not a string
+	empty         string // A value is empty
+}
+
+// languageMessages returns the problem messages of a language, Italian for
+// languages without their own
+func languageMessages(language string) outputMessages {
+	if definition, ok := findLanguage(language); ok {
+		return definition.messages
+	}
+	definition, _ := findLanguage(LanguageItalian)
+	return definition.messages
+}
+
+// structuredResponse asks the LLM for a JSON answer and checks it with validate,
+// which returns the problems found. While there are problems the answer is sent
+// back with the language's repair prompt listing them, up to the configured number of repair
+// attempts. Every answer goes through validate, so callers can keep the valid
+// parts of each; only the first answer is streamed to onDelta. It returns the
+// number of answers received, whether any came from a fallback provider and the
+// error of the call that failed, if any.
+func (s *LLMService) structuredResponse(ctx context.Context, language, prompt string, expectedKeys []string, onDelta func(string) error, validate func(response string) []string) (attempts int, fallback bool, err error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	messages := []LLMMessage{{Role: "user", Content: prompt}}
+	for {
+		resp, err := s.llmResponse(ctx, messages, onDelta)
+		if err != nil {
+			return attempts, fallback, err
+		}
+		attempts++
+		fallback = fallback || resp.Fallback
+
+		problems := validate(resp.Content)
+		if len(problems) == 0 {
+			return attempts, fallback, nil
+		}
+		log.Printf("LLM response failed validation (attempt %d): %s", attempts, strings.Join(problems, "; "))
+		if attempts > s.repairAttempts {
+			return attempts, fallback, nil
+		}
+
+		keysJSON, _ := json.Marshal(expectedKeys)
+		repair, err := s.renderTemplate(promptTemplateKey(language, repairTemplate), TemplateData{
+			Problems:         problems,
+			ExpectedKeysJSON: string(keysJSON),
+		})
+		if err != nil {
+			log.Printf("Error rendering repair template: %v", err)
+			return attempts, fallback, nil
+		}
+		messages = append(messages,
+			LLMMessage{Role: "assistant", Content: resp.Content},
+			LLMMessage{Role: "user", Content: repair},
+		)
+		onDelta = nil
+	}
+}
+
+// validateConjugationOutput checks a conjugation answer: a JSON object whose keys
+// are the requested verbs and whose values are non-empty strings. Valid forms are
+// added to accepted unless an earlier answer provided them. The problems are
+// worded for the repair prompt; none are returned once every verb is accepted,
+// invalid extra entries then being dropped.
+func validateConjugationOutput(response string, verbs []string, accepted map[string]string, messages outputMessages) []string {
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &answer); err != nil || answer == nil {
+		return []string{messages.notObject}
+	}
+
+	requested := make(map[string]string, len(verbs))
+	for _, verb := range verbs {
+		requested[normalizeOutputKey(verb)] = verb
+	}
+
+	var problems []string
+	answered := make(map[string]bool, len(answer))
+	for key, raw := range answer {
+		verb, ok := requested[normalizeOutputKey(key)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf(messages.unknownVerb, key))
+			continue
+		}
+		answered[verb] = true
+		form, problem := outputString(key, raw, messages)
+		if problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+		if _, ok := accepted[verb]; !ok {
+			accepted[verb] = form
+		}
+	}
+
+	complete := true
+	for _, verb := range verbs {
+		if _, ok := accepted[verb]; !ok {
+			complete = false
+			if !answered[verb] {
+				problems = append(problems, fmt.Sprintf(messages.missingVerb, verb))
+			}
+		}
+	}
+	if complete {
+		if len(problems) > 0 {
+			log.Printf("Dropping invalid entries of LLM response: %s", strings.Join(problems, "; "))
+		}
+		return nil
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// validateCorrectionOutput checks a correction answer: a JSON object holding the
+// corrected sentence as a single line of text. It returns the sentence, empty
+// when the answer is unusable, and the problems found.
+func validateCorrectionOutput(response string, messages outputMessages) (string, []string) {
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &answer); err != nil || answer == nil {
+		return "", []string{messages.notObject}
+	}
+
+	var problems []string
+	for key := range answer {
+		if key != correctionKey {
+			problems = append(problems, fmt.Sprintf(messages.unexpectedKey, key))
+		}
+	}
+	sort.Strings(problems)
+
+	raw, ok := answer[correctionKey]
+	if !ok {
+		return "", append(problems, fmt.Sprintf(messages.missingKey, correctionKey))
+	}
+	sentence, problem := outputString(correctionKey, raw, messages)
+	if problem == "" && strings.ContainsAny(sentence, "\r\n") {
+		problem = fmt.Sprintf(messages.multiline, correctionKey)
+	}
+	if problem != "" {
+		return "", append(problems, problem)
+	}
+
+	if len(problems) > 0 {
+		log.Printf("Dropping invalid entries of LLM correction: %s", strings.Join(problems, "; "))
+	}
+	return strings.Trim(sentence, "\""), nil
+}
+
+// outputString decodes a value of an answer that must be a non-empty string
+func outputString(key string, raw json.RawMessage, messages outputMessages) (string, string) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Sprintf(messages.notString, key)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Sprintf(messages.empty, key)
+	}
+	return value, ""
+}
+
+// normalizeOutputKey matches answer keys to requested verbs regardless of case
+// and surrounding spaces
+func normalizeOutputKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+// uniqueVerbs returns the requested verbs without duplicates, in request order
+func uniqueVerbs(verbs []string) []string {
+	seen := make(map[string]bool, len(verbs))
+	unique := make([]string, 0, len(verbs))
+	for _, verb := range verbs {
+		if !seen[verb] {
+			seen[verb] = true
+			unique = append(unique, verb)
+		}
+	}
+	return unique
+}
+
+// resolveConjugations builds the response from the forms accepted from the LLM.
+// Accepted forms that contradict the rule-based conjugator are replaced by its
+// own; verbs the LLM did not provide are conjugated with the rules, or left in
+// the infinitive when the rules cannot conjugate them. Without a conjugator, for
+// languages the rules do not know, the accepted forms are used as they are.
+func resolveConjugations(req models.ConjugateRequest, conjugator *Conjugator, accepted map[string]string, attempts int) *models.ConjugateResponse {
+	verbs := uniqueVerbs(req.BaseForms)
+	resp := &models.ConjugateResponse{
+		Language:     req.Language,
+		Conjugations: make(map[string]string, len(verbs)),
+		Sources:      make(map[string]string, len(verbs)),
+		Attempts:     attempts,
+	}
+
+	if conjugator == nil {
+		for _, verb := range verbs {
+			if form, ok := accepted[verb]; ok {
+				resp.Conjugations[verb], resp.Sources[verb] = form, SourceModel
+			} else {
+				resp.Conjugations[verb], resp.Sources[verb] = verb, SourceInput
+			}
+		}
+		return resp
+	}
+
+	subject, hasSubject := DetectSubject(req.Sentence)
+	var unknown []string
+	for _, verb := range verbs {
+		expected, known := "", false
+		if hasSubject {
+			expected, known = conjugator.Conjugate(verb, req.Tense, subject)
+		}
+
+		if form, ok := accepted[verb]; ok {
+			if known {
+				if matches, _ := conjugator.Matches(verb, req.Tense, subject, form); !matches {
+					log.Printf("LLM conjugated '%s' as '%s', expected '%s'; using rule-based form", verb, form, expected)
+					resp.Conjugations[verb], resp.Sources[verb] = expected, SourceRules
+					continue
+				}
+			}
+			resp.Conjugations[verb], resp.Sources[verb] = form, SourceModel
+			continue
+		}
+
+		switch {
+		case known:
+			resp.Conjugations[verb], resp.Sources[verb] = expected, SourceRules
+		case !hasSubject:
+			// Without a subject the infinitive is the expected answer
+			resp.Conjugations[verb], resp.Sources[verb] = verb, SourceRules
+		default:
+			resp.Conjugations[verb], resp.Sources[verb] = verb, SourceInput
+			unknown = append(unknown, verb)
+		}
+	}
+
+	if len(unknown) > 0 {
+		log.Printf("Rule-based conjugation left verbs unchanged: %v", unknown)
+	}
+	return resp
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/pkg/ollama"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// ErrNoLLMProvider is returned when no LLM provider could be configured
+var ErrNoLLMProvider = errors.New("no LLM provider configured")
+
+// LLMMessage is one message of a chat completion
+type LLMMessage struct {
+	Role    string // system, user or assistant
+	Content string
+}
+
+// LLMRequest describes a chat completion request
+type LLMRequest struct {
+	Messages []LLMMessage
+	// Model overrides the provider's configured model when set
+	Model string
+	// JSON asks the provider to answer with a JSON object
+	JSON bool
+	// Temperature is left to the provider's default when nil
+	Temperature *float64
+	// MaxTokens limits the length of the answer; 0 uses the provider's default
+	MaxTokens int
+}
+
+// LLMResponse is the result of a chat completion
+type LLMResponse struct {
+	Content          string
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Fallback         bool // Answered by a fallback provider rather than the primary one
+}
+
+// LLMProvider is a backend that can answer chat completions
+type LLMProvider interface {
+	// Name identifies the provider in logs, e.g. "ollama(http://localhost:11434)"
+	Name() string
+	Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+}
+
+// StreamingLLMProvider is a provider that can deliver its answer incrementally.
+// onDelta receives each piece of content as it arrives and may stop the stream by
+// returning an error; the returned response holds the whole answer.
+type StreamingLLMProvider interface {
+	LLMProvider
+	ChatStream(ctx context.Context, req LLMRequest, onDelta func(string) error) (*LLMResponse, error)
+}
+
+// ChatStream streams an answer from provider, or delivers the whole answer as a
+// single delta when the provider cannot stream
+func ChatStream(ctx context.Context, provider LLMProvider, req LLMRequest, onDelta func(string) error) (*LLMResponse, error) {
+	if streaming, ok := provider.(StreamingLLMProvider); ok {
+		return streaming.ChatStream(ctx, req, onDelta)
+	}
+
+	resp, err := provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onDelta(resp.Content); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LLMProviderFactory builds a provider from its configuration
+type LLMProviderFactory func(cfg config.LLMProviderConfig) (LLMProvider, error)
+
+var (
+	llmProvidersMu sync.RWMutex
+	llmProviders   = map[string]LLMProviderFactory{
+		"ollama": newOllamaProvider,
+		"openai": newOpenAIProvider,
+	}
+)
+
+// RegisterLLMProvider makes a backend type available to BACKEND_TYPE and the
+// fallback chain. Registering an existing type replaces its factory.
+func RegisterLLMProvider(backendType string, factory LLMProviderFactory) {
+	llmProvidersMu.Lock()
+	defer llmProvidersMu.Unlock()
+	llmProviders[backendType] = factory
+}
+
+// LLMProviderTypes returns the registered backend types
+func LLMProviderTypes() []string {
+	llmProvidersMu.RLock()
+	defer llmProvidersMu.RUnlock()
+
+	types := make([]string, 0, len(llmProviders))
+	for backendType := range llmProviders {
+		types = append(types, backendType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewLLMProvider builds a provider of a registered backend type
+func NewLLMProvider(cfg config.LLMProviderConfig) (LLMProvider, error) {
+	llmProvidersMu.RLock()
+	factory, ok := llmProviders[cfg.BackendType]
+	llmProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown backend type: %s (available: %s)", cfg.BackendType, strings.Join(LLMProviderTypes(), ", "))
+	}
+	return factory(cfg)
+}
+
+// NewLLMProviderChain builds the primary provider from BACKEND_TYPE followed by
+// the configured fallbacks. Providers that cannot be built are logged and skipped.
+func NewLLMProviderChain(cfg *config.Config) (LLMProvider, error) {
+	primary := config.LLMProviderConfig{
+		BackendType: cfg.LLM.BackendType,
+		Host:        cfg.LLM.Host,
+		Model:       cfg.LLM.Model,
+		APIKey:      cfg.LLM.OpenAIKey,
+	}
+	// The primary Ollama backend has always been reached through OLLAMA_BASE_URL
+	if primary.BackendType == "ollama" {
+		primary.Host = cfg.Ollama.BaseURL
+		primary.Timeout = cfg.Ollama.Timeout
+	}
+
+	var providers []LLMProvider
+	for i, providerCfg := range append([]config.LLMProviderConfig{primary}, cfg.LLM.Fallbacks...) {
+		provider, err := NewLLMProvider(providerCfg)
+		if err != nil {
+			log.Printf("[LLM] Skipping provider %d (%s): %v", i+1, providerCfg.BackendType, err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	switch len(providers) {
+	case 0:
+		return nil, ErrNoLLMProvider
+	case 1:
+		return providers[0], nil
+	}
+	return &FallbackLLMProvider{providers: providers}, nil
+}
+
+// FallbackLLMProvider tries its providers in order until one answers
+type FallbackLLMProvider struct {
+	providers []LLMProvider
+}
+
+// NewFallbackLLMProvider creates a provider that fails over along providers
+func NewFallbackLLMProvider(providers ...LLMProvider) *FallbackLLMProvider {
+	return &FallbackLLMProvider{providers: providers}
+}
+
+// Name lists the providers of the chain
+func (p *FallbackLLMProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Chat returns the first successful answer. It stops early when ctx is done,
+// since the next provider would fail the same way.
+func (p *FallbackLLMProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	var errs []error
+	for i, provider := range p.providers {
+		resp, err := provider.Chat(ctx, req)
+		if err == nil {
+			if i > 0 {
+				log.Printf("[LLM] Answered by fallback provider %s", provider.Name())
+				resp.Fallback = true
+			}
+			return resp, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(p.providers)-1 {
+			log.Printf("[LLM] Provider %s failed, trying next: %v", provider.Name(), err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// ChatStream streams the first provider that starts answering. Once content has
+// been delivered a failure is returned as is, since the next provider would
+// repeat the answer from the start.
+func (p *FallbackLLMProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta func(string) error) (*LLMResponse, error) {
+	var errs []error
+	for i, provider := range p.providers {
+		started := false
+		resp, err := ChatStream(ctx, provider, req, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+		if err == nil {
+			if i > 0 {
+				log.Printf("[LLM] Answered by fallback provider %s", provider.Name())
+				resp.Fallback = true
+			}
+			return resp, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if started || ctx.Err() != nil {
+			break
+		}
+		if i < len(p.providers)-1 {
+			log.Printf("[LLM] Provider %s failed, trying next: %v", provider.Name(), err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// ollamaProvider answers chat completions through the Ollama chat API
+type ollamaProvider struct {
+	host   string
+	model  string
+	client ollama.Client
+}
+
+func newOllamaProvider(cfg config.LLMProviderConfig) (LLMProvider, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("ollama host is required")
+	}
+	return &ollamaProvider{
+		host:  cfg.Host,
+		model: cfg.Model,
+		client: ollama.NewClient(&ollama.ClientConfig{
+			BaseURL: cfg.Host,
+			Timeout: cfg.Timeout,
+		}),
+	}, nil
+}
+
+func (p *ollamaProvider) Name() string {
+	return fmt.Sprintf("ollama(%s)", p.host)
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	model, options := p.chatOptions(req)
+	resp, err := p.client.Chat(ctx, model, "", options...)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	return p.response(model, resp)
+}
+
+func (p *ollamaProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta func(string) error) (*LLMResponse, error) {
+	model, options := p.chatOptions(req)
+	resp, err := p.client.ChatStream(ctx, model, "", func(chunk *ollama.Response) error {
+		if chunk.Message.Content == "" {
+			return nil
+		}
+		return onDelta(chunk.Message.Content)
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	return p.response(model, resp)
+}
+
+// chatOptions returns the model and Ollama options for a request
+func (p *ollamaProvider) chatOptions(req LLMRequest) (string, []ollama.ChatOption) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	messages := make([]ollama.Message, len(req.Messages))
+	for i, message := range req.Messages {
+		messages[i] = ollama.Message{Role: message.Role, Content: message.Content}
+	}
+
+	options := []ollama.ChatOption{ollama.WithMessages(messages)}
+	if req.JSON {
+		options = append(options, ollama.WithJSONFormat())
+	}
+	if req.Temperature != nil || req.MaxTokens > 0 {
+		options = append(options, ollama.WithOptions(ollama.Options{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+		}))
+	}
+	return model, options
+}
+
+// response converts a completed Ollama response
+func (p *ollamaProvider) response(model string, resp *ollama.Response) (*LLMResponse, error) {
+	if !resp.Done {
+		return nil, fmt.Errorf("ollama response not completed")
+	}
+
+	return &LLMResponse{
+		Content:          resp.Message.Content,
+		Provider:         "ollama",
+		Model:            model,
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+	}, nil
+}
+
+// openaiProvider answers chat completions through an OpenAI compatible API
+type openaiProvider struct {
+	host   string
+	model  string
+	client openai.Client
+}
+
+func newOpenAIProvider(cfg config.LLMProviderConfig) (LLMProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required")
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+	// If using a custom host (not official OpenAI), set base URL
+	if cfg.Host != "" && cfg.Host != "https://api.openai.com" {
+		opts = append(opts, option.WithBaseURL(cfg.Host))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.Timeout))
+	}
+
+	host := cfg.Host
+	if host == "" {
+		host = "https://api.openai.com"
+	}
+	return &openaiProvider{
+		host:   host,
+		model:  cfg.Model,
+		client: openai.NewClient(opts...),
+	}, nil
+}
+
+func (p *openaiProvider) Name() string {
+	return fmt.Sprintf("openai(%s)", p.host)
+}
+
+func (p *openaiProvider) Chat(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	params := p.chatParams(req)
+	chatCompletion, err := p.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("error making openai request: %w", err)
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in openai response")
+	}
+
+	content := chatCompletion.Choices[0].Message.Content
+	if content == "" {
+		return nil, fmt.Errorf("empty content in openai response")
+	}
+
+	return &LLMResponse{
+		Content:          content,
+		Provider:         "openai",
+		Model:            string(params.Model),
+		PromptTokens:     int(chatCompletion.Usage.PromptTokens),
+		CompletionTokens: int(chatCompletion.Usage.CompletionTokens),
+	}, nil
+}
+
+func (p *openaiProvider) ChatStream(ctx context.Context, req LLMRequest, onDelta func(string) error) (*LLMResponse, error) {
+	params := p.chatParams(req)
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	resp := &LLMResponse{Provider: "openai", Model: string(params.Model)}
+	var content strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		// The last chunk carries the usage and no choices
+		if chunk.Usage.TotalTokens > 0 {
+			resp.PromptTokens = int(chunk.Usage.PromptTokens)
+			resp.CompletionTokens = int(chunk.Usage.CompletionTokens)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("error making openai request: %w", err)
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("empty content in openai response")
+	}
+
+	resp.Content = content.String()
+	return resp, nil
+}
+
+// chatParams builds the chat completion parameters of a request
+func (p *openaiProvider) chatParams(req LLMRequest) openai.ChatCompletionNewParams {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		switch message.Role {
+		case "system":
+			messages = append(messages, openai.SystemMessage(message.Content))
+		case "assistant":
+			messages = append(messages, openai.AssistantMessage(message.Content))
+		default:
+			messages = append(messages, openai.UserMessage(message.Content))
+		}
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Messages: messages,
+		Model:    shared.ChatModel(model),
+	}
+	if req.JSON {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{Type: "json_object"},
+		}
+	}
+	if req.Temperature != nil {
+		params.Temperature = openai.Float(*req.Temperature)
+	}
+	if req.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+	}
+	return params
+}
@@ -0,0 +1,627 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxOBZEntrySize limits the uncompressed size of a single file read from an .obz package
+	maxOBZEntrySize = 10 << 20
+	// obfSetTenseAction is the extension action used to round-trip the CAA setTense control
+	obfSetTenseAction = ":ext_caa_setTense"
+)
+
+// obfActions maps CAA system actions to their Open Board Format equivalents
+var obfActions = map[string]string{
+	"deleteLastWord": ":backspace",
+	"deleteAllText":  ":clear",
+	"speakText":      ":speak",
+	"setTense":       obfSetTenseAction,
+}
+
+var (
+	obfBoardFileSanitizer = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+	obfRGBPattern         = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*(?:,\s*([0-9.]+)\s*)?\)$`)
+)
+
+// ExportOBZ exports the grid of the source board as an Open Board Zip (.obz) package.
+// Every ParentCategory becomes one OBF board, category items link to their
+// target board through load_board, and uploaded images are embedded as files.
+// The boards are tagged with locale, the language of the labels.
+func (s *GridService) ExportOBZ(source *models.Board, locale string) ([]byte, error) {
+	log.Printf("Exporting grid as OBZ for board %s of user ID: %s", source.ID, source.UserID)
+
+	gridData, err := s.GetGrid(source)
+	if err != nil {
+		return nil, err
+	}
+	if gridData == nil {
+		gridData = map[string][]models.GridItemResponse{"home": {}}
+	}
+
+	// Name boards after the category item pointing to them
+	boardNames := map[string]string{
+		"home":           "Home",
+		"systemControls": "System Controls",
+	}
+	for _, items := range gridData {
+		for _, item := range items {
+			if item.Type == "category" && item.Target != "" {
+				boardNames[item.Target] = item.Label
+			}
+		}
+	}
+
+	boardPaths := make(map[string]string, len(gridData))
+	usedPaths := make(map[string]bool, len(gridData))
+	for _, key := range sortedGridKeys(gridData) {
+		base := obfBoardFileSanitizer.ReplaceAllString(key, "_")
+		if base == "" {
+			base = "board"
+		}
+		boardPath := "boards/" + base + ".obf"
+		for i := 2; usedPaths[boardPath]; i++ {
+			boardPath = fmt.Sprintf("boards/%s_%d.obf", base, i)
+		}
+		usedPaths[boardPath] = true
+		boardPaths[key] = boardPath
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := models.OBZManifest{
+		Format: models.OBFFormat,
+		Root:   boardPaths["home"],
+		Paths: models.OBZPathTable{
+			Boards: make(map[string]string, len(gridData)),
+			Images: make(map[string]string),
+		},
+	}
+
+	for _, key := range sortedGridKeys(gridData) {
+		items := gridData[key]
+		board := models.OBFBoard{
+			Format:  models.OBFFormat,
+			ID:      key,
+			Locale:  locale,
+			Name:    boardNames[key],
+			Buttons: make([]models.OBFButton, 0, len(items)),
+			Images:  []models.OBFImage{},
+			Sounds:  []models.OBFSound{},
+		}
+
+		order := make([]string, 0, len(items))
+		for _, item := range items {
+			// Embed stored images so the package does not depend on this server
+			if dataURL, ok := s.images.InlineDataURL(context.Background(), item.Icon); ok {
+				item.Icon = dataURL
+			}
+
+			button, image, imageFile, err := obfButtonFromItem(item, boardPaths)
+			if err != nil {
+				log.Printf("Warning: skipping image for item %s: %v", item.ID, err)
+			}
+
+			if image != nil {
+				if imageFile != nil {
+					if err := writeZipFile(zw, image.Path, imageFile); err != nil {
+						return nil, err
+					}
+					manifest.Paths.Images[image.ID] = image.Path
+				}
+				board.Images = append(board.Images, *image)
+			}
+
+			board.Buttons = append(board.Buttons, button)
+			order = append(order, button.ID)
+		}
+		board.Grid = obfGridLayout(order)
+
+		content, err := json.MarshalIndent(board, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling board %s: %w", key, err)
+		}
+		if err := writeZipFile(zw, boardPaths[key], content); err != nil {
+			return nil, err
+		}
+		manifest.Paths.Boards[key] = boardPaths[key]
+	}
+
+	manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling OBZ manifest: %w", err)
+	}
+	if err := writeZipFile(zw, "manifest.json", manifestContent); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("error finalizing OBZ package: %w", err)
+	}
+
+	log.Printf("Exported %d boards as OBZ for user ID: %s (%d bytes)", len(gridData), source.UserID, buf.Len())
+	return buf.Bytes(), nil
+}
+
+// ImportOpenBoard imports an .obf board or .obz package and replaces the grid of the
+// target board with it. The root board becomes "home"; every other board gets a fresh
+// UUID key and all buttons get fresh IDs, so importing the same package twice never collides.
+func (s *GridService) ImportOpenBoard(data []byte, filename string, target *models.Board, expectedRevision int) (*models.OBFImportResponse, error) {
+	log.Printf("Importing Open Board file %q into board %s of user ID: %s", filename, target.ID, target.UserID)
+
+	pkg, err := parseOpenBoardPackage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign grid keys to every board
+	boardKeys := make(map[string]string, len(pkg.boards))
+	for id := range pkg.boards {
+		switch {
+		case id == pkg.rootID:
+			boardKeys[id] = "home"
+		case id == "systemControls":
+			boardKeys[id] = "systemControls"
+		default:
+			boardKeys[id] = uuid.New().String()
+		}
+	}
+
+	gridData := make(map[string][]models.GridItemResponse, len(pkg.boards))
+	totalItems := 0
+	for id, board := range pkg.boards {
+		key := boardKeys[id]
+		images := make(map[string]models.OBFImage, len(board.Images))
+		for _, image := range board.Images {
+			images[image.ID] = image
+		}
+
+		items := make([]models.GridItemResponse, 0, len(board.Buttons))
+		for _, button := range orderedOBFButtons(board) {
+			item, ok := pkg.itemFromButton(button, images, boardKeys)
+			if !ok {
+				log.Printf("Skipping unsupported button %s (action %q) on board %s", button.ID, button.Action, id)
+				continue
+			}
+
+			// Images that cannot be processed (e.g. SVG) are dropped rather than failing the import
+			icon, err := s.storeIcon(target.UserID, item.Icon)
+			if errors.Is(err, ErrInvalidImage) {
+				log.Printf("Warning: dropping image of button %s on board %s: %v", button.ID, id, err)
+				icon = ""
+			} else if err != nil {
+				return nil, err
+			}
+			item.Icon = icon
+			items = append(items, item)
+		}
+		gridData[key] = items
+		totalItems += len(items)
+	}
+
+	// Navigation back to a parent board is built into the grid, so links that
+	// would loop are dropped
+	for _, problem := range removeCycleLinks(gridData) {
+		log.Printf("Warning: dropping button %s: %s", problem.ItemID, problem.Message)
+		totalItems--
+	}
+
+	// Keep the user's current system controls when the package has none
+	if _, exists := gridData["systemControls"]; !exists {
+		currentGrid, err := s.GetGrid(target)
+		if err != nil {
+			return nil, err
+		}
+		if controls := currentGrid["systemControls"]; len(controls) > 0 {
+			gridData["systemControls"] = controls
+			log.Printf("Preserving %d existing system controls", len(controls))
+		}
+	}
+
+	summary := fmt.Sprintf("Imported %d boards from %s", len(pkg.boards), filename)
+	revision, err := s.saveGrid(gridData, target, expectedRevision, "import", summary)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Imported %d boards with %d items for user ID: %s", len(pkg.boards), totalItems, target.UserID)
+	return &models.OBFImportResponse{
+		Message:  "Open Board file imported successfully",
+		Boards:   len(pkg.boards),
+		Items:    totalItems,
+		Revision: revision,
+	}, nil
+}
+
+// openBoardPackage holds the parsed contents of an .obf or .obz file
+type openBoardPackage struct {
+	rootID     string
+	boards     map[string]models.OBFBoard
+	boardPaths map[string]string // package path -> board ID
+	files      map[string]*zip.File
+}
+
+// parseOpenBoardPackage parses either a single .obf JSON board or an .obz zip package
+func parseOpenBoardPackage(data []byte) (*openBoardPackage, error) {
+	pkg := &openBoardPackage{
+		boards:     make(map[string]models.OBFBoard),
+		boardPaths: make(map[string]string),
+		files:      make(map[string]*zip.File),
+	}
+
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		var board models.OBFBoard
+		if err := json.Unmarshal(data, &board); err != nil {
+			return nil, fmt.Errorf("invalid OBF board: %w", err)
+		}
+		if board.ID == "" {
+			board.ID = "root"
+		}
+		pkg.rootID = board.ID
+		pkg.boards[board.ID] = board
+		return pkg, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OBZ package: %w", err)
+	}
+	for _, f := range zr.File {
+		pkg.files[path.Clean(f.Name)] = f
+	}
+
+	manifestFile, ok := pkg.files["manifest.json"]
+	if !ok {
+		return nil, fmt.Errorf("invalid OBZ package: manifest.json not found")
+	}
+	manifestContent, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest models.OBZManifest
+	if err := json.Unmarshal(manifestContent, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid OBZ manifest: %w", err)
+	}
+
+	for _, boardPath := range manifest.Paths.Boards {
+		f, ok := pkg.files[path.Clean(boardPath)]
+		if !ok {
+			return nil, fmt.Errorf("invalid OBZ package: board %s not found", boardPath)
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var board models.OBFBoard
+		if err := json.Unmarshal(content, &board); err != nil {
+			return nil, fmt.Errorf("invalid OBF board %s: %w", boardPath, err)
+		}
+		pkg.boards[board.ID] = board
+		pkg.boardPaths[path.Clean(boardPath)] = board.ID
+		if path.Clean(boardPath) == path.Clean(manifest.Root) {
+			pkg.rootID = board.ID
+		}
+	}
+
+	if pkg.rootID == "" {
+		return nil, fmt.Errorf("invalid OBZ package: root board %s not found", manifest.Root)
+	}
+	return pkg, nil
+}
+
+// itemFromButton maps an OBF button onto a grid item, returning false for unsupported buttons
+func (p *openBoardPackage) itemFromButton(button models.OBFButton, images map[string]models.OBFImage, boardKeys map[string]string) (models.GridItemResponse, bool) {
+	item := models.GridItemResponse{
+		ID:         uuid.New().String(),
+		Type:       "symbol",
+		Label:      button.Label,
+		Color:      obfColorToHex(button.BackgroundColor),
+		Text:       button.ExtText,
+		Speak:      button.Vocalization,
+		IsVisible:  !button.Hidden,
+		SymbolType: button.ExtSymbolType,
+		IsHideable: true,
+	}
+	if button.ExtIsHideable != nil {
+		item.IsHideable = *button.ExtIsHideable
+	}
+
+	switch {
+	case button.LoadBoard != nil:
+		boardID := button.LoadBoard.ID
+		if id, ok := p.boardPaths[path.Clean(button.LoadBoard.Path)]; ok && button.LoadBoard.Path != "" {
+			boardID = id
+		}
+		target, ok := boardKeys[boardID]
+		if !ok {
+			log.Printf("Warning: button %s links to unknown board %q", button.ID, boardID)
+			return item, false
+		}
+		item.Type = "category"
+		item.Target = target
+		item.Text = ""
+		item.Speak = ""
+		item.SymbolType = ""
+
+	case button.Action != "":
+		action := ""
+		for caaAction, obfAction := range obfActions {
+			if obfAction == button.Action {
+				action = caaAction
+				break
+			}
+		}
+		if action == "" {
+			return item, false
+		}
+		item.Type = "system"
+		item.Action = action
+		item.Speak = ""
+		item.SymbolType = ""
+
+	default:
+		if item.Text == "" {
+			item.Text = strings.ToLower(button.Label)
+		}
+		if item.Speak == "" {
+			item.Speak = button.Label
+		}
+		if item.SymbolType == "" {
+			item.SymbolType = "altro"
+		}
+	}
+
+	if image, ok := images[button.ImageID]; ok && button.ImageID != "" {
+		item.Icon = p.iconFromImage(image)
+	}
+
+	return item, true
+}
+
+// iconFromImage resolves an OBF image to an icon value (data URL or remote URL)
+func (p *openBoardPackage) iconFromImage(image models.OBFImage) string {
+	if image.Data != "" {
+		return image.Data
+	}
+
+	if image.Path != "" {
+		if f, ok := p.files[path.Clean(image.Path)]; ok {
+			content, err := readZipFile(f)
+			if err != nil {
+				log.Printf("Warning: failed to read image %s: %v", image.Path, err)
+			} else {
+				contentType := image.ContentType
+				if contentType == "" {
+					contentType = mime.TypeByExtension(path.Ext(image.Path))
+				}
+				if contentType == "" {
+					contentType = "image/png"
+				}
+				return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(content))
+			}
+		}
+	}
+
+	return image.URL
+}
+
+// obfButtonFromItem converts a grid item into an OBF button and optional image.
+// When the icon is a data URL the decoded image content is returned for embedding.
+func obfButtonFromItem(item models.GridItemResponse, boardPaths map[string]string) (models.OBFButton, *models.OBFImage, []byte, error) {
+	isHideable := item.IsHideable
+	button := models.OBFButton{
+		ID:              item.ID,
+		Label:           item.Label,
+		Vocalization:    item.Speak,
+		BackgroundColor: obfColorFromHex(item.Color),
+		Hidden:          !item.IsVisible,
+		ExtType:         item.Type,
+		ExtText:         item.Text,
+		ExtSymbolType:   item.SymbolType,
+		ExtIsHideable:   &isHideable,
+	}
+
+	switch item.Type {
+	case "category":
+		if boardPath, ok := boardPaths[item.Target]; ok {
+			button.LoadBoard = &models.OBFLoadBoard{
+				ID:   item.Target,
+				Name: item.Label,
+				Path: boardPath,
+			}
+		}
+	case "system":
+		button.Action = obfActions[item.Action]
+	}
+
+	if item.Icon == "" {
+		return button, nil, nil, nil
+	}
+
+	image := &models.OBFImage{ID: "img-" + item.ID}
+	button.ImageID = image.ID
+
+	if !strings.HasPrefix(item.Icon, "data:") {
+		image.URL = item.Icon
+		return button, image, nil, nil
+	}
+
+	contentType, content, err := decodeDataURL(item.Icon)
+	if err != nil {
+		// Keep the data URL inline rather than dropping the image
+		image.Data = item.Icon
+		return button, image, nil, err
+	}
+	image.ContentType = contentType
+	image.Path = "images/" + image.ID + utils.GetFileExtensionFromMimeType(contentType, ".png")
+	return button, image, content, nil
+}
+
+// orderedOBFButtons returns a board's buttons in grid order, followed by any buttons not placed in the grid
+func orderedOBFButtons(board models.OBFBoard) []models.OBFButton {
+	byID := make(map[string]models.OBFButton, len(board.Buttons))
+	for _, button := range board.Buttons {
+		byID[button.ID] = button
+	}
+
+	ordered := make([]models.OBFButton, 0, len(board.Buttons))
+	placed := make(map[string]bool, len(board.Buttons))
+	for _, row := range board.Grid.Order {
+		for _, cell := range row {
+			if cell == nil || placed[*cell] {
+				continue
+			}
+			if button, ok := byID[*cell]; ok {
+				ordered = append(ordered, button)
+				placed[*cell] = true
+			}
+		}
+	}
+	for _, button := range board.Buttons {
+		if !placed[button.ID] {
+			ordered = append(ordered, button)
+		}
+	}
+	return ordered
+}
+
+// obfGridLayout lays out button IDs row by row in a roughly square grid
+func obfGridLayout(buttonIDs []string) models.OBFGrid {
+	if len(buttonIDs) == 0 {
+		return models.OBFGrid{Rows: 0, Columns: 0, Order: [][]*string{}}
+	}
+
+	columns := int(math.Ceil(math.Sqrt(float64(len(buttonIDs)))))
+	rows := (len(buttonIDs) + columns - 1) / columns
+
+	order := make([][]*string, rows)
+	for r := range order {
+		order[r] = make([]*string, columns)
+	}
+	for i := range buttonIDs {
+		order[i/columns][i%columns] = &buttonIDs[i]
+	}
+
+	return models.OBFGrid{Rows: rows, Columns: columns, Order: order}
+}
+
+// obfColorFromHex converts "#RRGGBB" or "#RRGGBBAA" to an OBF rgb()/rgba() color
+func obfColorFromHex(color string) string {
+	hex := strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return ""
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+
+	if len(hex) == 6 {
+		return fmt.Sprintf("rgb(%d, %d, %d)", value>>16&0xFF, value>>8&0xFF, value&0xFF)
+	}
+	alpha := float64(value&0xFF) / 255
+	return fmt.Sprintf("rgba(%d, %d, %d, %.2f)", value>>24&0xFF, value>>16&0xFF, value>>8&0xFF, alpha)
+}
+
+// obfColorToHex converts an OBF rgb()/rgba() color (or a hex color) to "#RRGGBB" / "#RRGGBBAA"
+func obfColorToHex(color string) string {
+	color = strings.TrimSpace(color)
+	if strings.HasPrefix(color, "#") {
+		return color
+	}
+
+	match := obfRGBPattern.FindStringSubmatch(color)
+	if match == nil {
+		return ""
+	}
+
+	channel := func(s string) int {
+		v, _ := strconv.Atoi(s)
+		return min(v, 255)
+	}
+	hex := fmt.Sprintf("#%02x%02x%02x", channel(match[1]), channel(match[2]), channel(match[3]))
+	if match[4] != "" {
+		if alpha, err := strconv.ParseFloat(match[4], 64); err == nil && alpha < 1 {
+			hex += fmt.Sprintf("%02x", int(math.Round(math.Max(alpha, 0)*255)))
+		}
+	}
+	return hex
+}
+
+// decodeDataURL splits a base64 data URL into its MIME type and decoded content
+func decodeDataURL(dataURL string) (string, []byte, error) {
+	header, payload, found := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return "", nil, fmt.Errorf("unsupported data URL encoding")
+	}
+
+	content, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("error decoding data URL: %w", err)
+	}
+
+	return strings.TrimSuffix(header, ";base64"), content, nil
+}
+
+// sortedGridKeys returns the grid category keys with "home" first and the rest sorted
+func sortedGridKeys(gridData map[string][]models.GridItemResponse) []string {
+	keys := make([]string, 0, len(gridData))
+	for key := range gridData {
+		if key != "home" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return append([]string{"home"}, keys...)
+}
+
+// writeZipFile adds a file to a zip archive
+func writeZipFile(zw *zip.Writer, name string, content []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s to OBZ package: %w", name, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("error writing %s to OBZ package: %w", name, err)
+	}
+	return nil
+}
+
+// readZipFile reads a file from a zip archive, refusing entries larger than maxOBZEntrySize
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxOBZEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", f.Name, err)
+	}
+	if len(content) > maxOBZEntrySize {
+		return nil, fmt.Errorf("file %s exceeds maximum size of %d bytes", f.Name, maxOBZEntrySize)
+	}
+	return content, nil
+}
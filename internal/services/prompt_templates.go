@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+	"github.com/daniele/web-app-caa/internal/prompts"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPromptTemplateNotFound is returned when a prompt template is not one the LLM service uses
+	ErrPromptTemplateNotFound = errors.New("prompt template not found")
+	// ErrPromptTemplateVersionNotFound is returned when a prompt template version does not exist
+	ErrPromptTemplateVersionNotFound = errors.New("prompt template version not found")
+	// ErrInvalidPromptTemplate is returned when a template does not parse or render
+	ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+)
+
+// Prompt templates that are not tied to a tense
+const (
+	correctTemplate = "correct_sentence"
+	repairTemplate  = "repair_output"
+)
+
+// promptTemplateNames lists the templates the LLM service uses in a language
+func promptTemplateNames(language string) []string {
+	definition, ok := findLanguage(language)
+	if !ok {
+		return nil
+	}
+	names := []string{correctTemplate, repairTemplate}
+	for _, tense := range definition.tenses {
+		names = append(names, tense.id)
+	}
+	return names
+}
+
+// isPromptTemplate reports whether name is a template the LLM service uses in a language
+func isPromptTemplate(language, name string) bool {
+	for _, known := range promptTemplateNames(language) {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// promptTemplateKey identifies a template among those of every language, e.g.
+// it/presente. It is also the path of its embedded default without .tmpl.
+func promptTemplateKey(language, name string) string {
+	return language + "/" + name
+}
+
+// defaultPromptTemplate returns the embedded default source of a template
+func defaultPromptTemplate(language, name string) (string, error) {
+	key := promptTemplateKey(language, name)
+	source, err := prompts.Files.ReadFile(key + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("error reading default template %s: %w", key, err)
+	}
+	return string(source), nil
+}
+
+// parsePromptTemplate parses a template source
+func parsePromptTemplate(name, source string) (*template.Template, error) {
+	return template.New(name).Parse(source)
+}
+
+// defaultTemplateComment marks the template versions seeded from the embedded defaults
+const defaultTemplateComment = "Default template"
+
+// SeedPromptTemplates stores the embedded default of every prompt template that
+// has no version yet as its version 1. Admins edit them afterwards. Templates
+// still at a seeded default get the embedded default again as a new version
+// when it changed, so prompt updates reach them; edited ones are left alone.
+func SeedPromptTemplates() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		seeded, updated := 0, 0
+		for _, language := range supportedLanguages {
+			for _, name := range promptTemplateNames(language.code) {
+				source, err := defaultPromptTemplate(language.code, name)
+				if err != nil {
+					return err
+				}
+
+				next := 1
+				latest, err := latestPromptTemplate(tx, language.code, name)
+				switch {
+				case err == nil:
+					if latest.CreatedBy != "" || latest.Comment != defaultTemplateComment || latest.Source == source {
+						continue
+					}
+					next = latest.Version + 1
+				case !errors.Is(err, ErrPromptTemplateVersionNotFound):
+					return err
+				}
+
+				version := models.PromptTemplate{
+					Language: language.code,
+					Name:     name,
+					Version:  next,
+					Source:   source,
+					Comment:  defaultTemplateComment,
+				}
+				if err := tx.Create(&version).Error; err != nil {
+					return fmt.Errorf("error seeding prompt template %s: %w", promptTemplateKey(language.code, name), err)
+				}
+				if next == 1 {
+					seeded++
+				} else {
+					updated++
+				}
+			}
+		}
+		if seeded > 0 {
+			log.Printf("[PROMPTS] Seeded %d default prompt templates", seeded)
+		}
+		if updated > 0 {
+			log.Printf("[PROMPTS] Updated %d unedited prompt templates to their new default", updated)
+		}
+		return nil
+	})
+}
+
+// activePromptSources returns the source in use of every template of every
+// language, keyed by promptTemplateKey: its latest stored version, or the
+// embedded default when none is stored
+func activePromptSources() (map[string]string, error) {
+	sources := make(map[string]string)
+	for _, language := range supportedLanguages {
+		for _, name := range promptTemplateNames(language.code) {
+			key := promptTemplateKey(language.code, name)
+			if database.DB != nil {
+				latest, err := latestPromptTemplate(database.DB, language.code, name)
+				if err == nil {
+					sources[key] = latest.Source
+					continue
+				}
+				if !errors.Is(err, ErrPromptTemplateVersionNotFound) {
+					return nil, err
+				}
+			}
+
+			source, err := defaultPromptTemplate(language.code, name)
+			if err != nil {
+				return nil, err
+			}
+			sources[key] = source
+		}
+	}
+	return sources, nil
+}
+
+// latestPromptTemplate fetches the latest version of a template
+func latestPromptTemplate(tx *gorm.DB, language, name string) (*models.PromptTemplate, error) {
+	var latest models.PromptTemplate
+	if err := tx.Where("language = ? AND name = ?", language, name).Order("version DESC").First(&latest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptTemplateVersionNotFound
+		}
+		return nil, fmt.Errorf("error loading prompt template %s: %w", promptTemplateKey(language, name), err)
+	}
+	return &latest, nil
+}
+
+// installTemplates parses the sources and swaps them in as the templates in use.
+// Nothing is swapped when a source does not parse. Cached results are purged
+// when a template in use changes.
+func (s *LLMService) installTemplates(sources map[string]string) error {
+	parsed := make(map[string]*template.Template, len(sources))
+	for name, source := range sources {
+		tmpl, err := parsePromptTemplate(name, source)
+		if err != nil {
+			return fmt.Errorf("error parsing template %s: %w", name, err)
+		}
+		parsed[name] = tmpl
+	}
+
+	s.templateMu.Lock()
+	changed := false
+	for name, tmpl := range parsed {
+		version := contentVersion([]byte(sources[name]))
+		if previous, ok := s.templateVersions[name]; ok && previous != version {
+			changed = true
+		}
+		s.templates[name] = tmpl
+		s.templateVersions[name] = version
+	}
+	s.templateMu.Unlock()
+
+	if changed {
+		log.Printf("Prompt templates changed, purging cached results")
+		s.cache.Purge()
+	}
+	return nil
+}
+
+// templateVersion returns the hash of a template in use, part of the cache key
+func (s *LLMService) templateVersion(name string) string {
+	s.templateMu.RLock()
+	defer s.templateMu.RUnlock()
+	return s.templateVersions[name]
+}
+
+// ReloadPromptTemplates reloads the templates in use from the database, picking
+// up versions saved by other instances
+func (s *LLMService) ReloadPromptTemplates() error {
+	return s.loadTemplates()
+}
+
+// ListPromptTemplates returns the version in use of every prompt template of a
+// language, or of every language when it is empty
+func (s *LLMService) ListPromptTemplates(language string) ([]models.PromptTemplateResponse, error) {
+	var templates []models.PromptTemplateResponse
+	for _, definition := range supportedLanguages {
+		if language != "" && definition.code != language {
+			continue
+		}
+		for _, name := range promptTemplateNames(definition.code) {
+			latest, err := latestPromptTemplate(database.DB, definition.code, name)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, promptTemplateResponse(latest, true, false))
+		}
+	}
+	return templates, nil
+}
+
+// GetPromptTemplate returns the version in use of a template, with its source
+func (s *LLMService) GetPromptTemplate(language, name string) (*models.PromptTemplateResponse, error) {
+	if !isPromptTemplate(language, name) {
+		return nil, ErrPromptTemplateNotFound
+	}
+	latest, err := latestPromptTemplate(database.DB, language, name)
+	if err != nil {
+		return nil, err
+	}
+	response := promptTemplateResponse(latest, true, true)
+	return &response, nil
+}
+
+// ListPromptTemplateVersions returns the versions of a template, newest first, without sources
+func (s *LLMService) ListPromptTemplateVersions(language, name string) ([]models.PromptTemplateResponse, error) {
+	if !isPromptTemplate(language, name) {
+		return nil, ErrPromptTemplateNotFound
+	}
+
+	var versions []models.PromptTemplate
+	if err := database.DB.Where("language = ? AND name = ?", language, name).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("error listing prompt template versions: %w", err)
+	}
+
+	responses := make([]models.PromptTemplateResponse, len(versions))
+	for i := range versions {
+		responses[i] = promptTemplateResponse(&versions[i], i == 0, false)
+	}
+	return responses, nil
+}
+
+// GetPromptTemplateVersion returns a single version of a template, with its source
+func (s *LLMService) GetPromptTemplateVersion(language, name string, version int) (*models.PromptTemplateResponse, error) {
+	if !isPromptTemplate(language, name) {
+		return nil, ErrPromptTemplateNotFound
+	}
+
+	stored, err := s.loadPromptTemplateVersion(language, name, version)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := latestPromptTemplate(database.DB, language, name)
+	if err != nil {
+		return nil, err
+	}
+	response := promptTemplateResponse(stored, stored.Version == latest.Version, true)
+	return &response, nil
+}
+
+// ValidatePromptTemplate parses a template source and renders it with sample
+// data, without saving it. Problems of the source are reported in the result;
+// the error is only set for unknown templates.
+func (s *LLMService) ValidatePromptTemplate(ctx context.Context, language, name string, req models.ValidatePromptTemplateRequest) (*models.PromptTemplateValidationResponse, error) {
+	if !isPromptTemplate(language, name) {
+		return nil, ErrPromptTemplateNotFound
+	}
+
+	tmpl, err := parsePromptTemplate(name, req.Source)
+	if err != nil {
+		return &models.PromptTemplateValidationResponse{Error: err.Error()}, nil
+	}
+
+	data := s.samplePromptData(ctx, language, name, req)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return &models.PromptTemplateValidationResponse{Error: err.Error()}, nil
+	}
+	rendered := buf.String()
+	if strings.TrimSpace(rendered) == "" {
+		return &models.PromptTemplateValidationResponse{Error: "the template renders an empty prompt"}, nil
+	}
+
+	return &models.PromptTemplateValidationResponse{
+		Valid:      true,
+		Rendered:   rendered,
+		Warnings:   promptTemplateWarnings(name, rendered, data),
+		RagEntries: data.RagEntries,
+	}, nil
+}
+
+// UpdatePromptTemplate validates a template source, saves it as the template's
+// new version and puts it in use
+func (s *LLMService) UpdatePromptTemplate(language, name, source, comment, userID string) (*models.PromptTemplateResponse, error) {
+	validation, err := s.ValidatePromptTemplate(context.Background(), language, name, models.ValidatePromptTemplateRequest{Source: source})
+	if err != nil {
+		return nil, err
+	}
+	if !validation.Valid {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPromptTemplate, validation.Error)
+	}
+	return s.savePromptTemplate(language, name, source, comment, userID)
+}
+
+// RestorePromptTemplateVersion puts a previous version of a template back in use.
+// The restore is recorded as a new version, so it can be undone.
+func (s *LLMService) RestorePromptTemplateVersion(language, name string, version int, userID string) (*models.PromptTemplateResponse, error) {
+	if !isPromptTemplate(language, name) {
+		return nil, ErrPromptTemplateNotFound
+	}
+
+	stored, err := s.loadPromptTemplateVersion(language, name, version)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parsePromptTemplate(name, stored.Source); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	return s.savePromptTemplate(language, name, stored.Source, fmt.Sprintf("Restored version %d", version), userID)
+}
+
+// savePromptTemplate records a source as the template's next version and swaps it in
+func (s *LLMService) savePromptTemplate(language, name, source, comment, userID string) (*models.PromptTemplateResponse, error) {
+	key := promptTemplateKey(language, name)
+	var saved models.PromptTemplate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		next := 1
+		latest, err := latestPromptTemplate(tx, language, name)
+		if err == nil {
+			next = latest.Version + 1
+		} else if !errors.Is(err, ErrPromptTemplateVersionNotFound) {
+			return err
+		}
+
+		saved = models.PromptTemplate{
+			Language:  language,
+			Name:      name,
+			Version:   next,
+			Source:    source,
+			Comment:   comment,
+			CreatedBy: userID,
+		}
+		return tx.Create(&saved).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving prompt template %s: %w", key, err)
+	}
+
+	if err := s.installTemplates(map[string]string{key: source}); err != nil {
+		return nil, err
+	}
+	log.Printf("[PROMPTS] Template %s version %d saved and in use", key, saved.Version)
+
+	response := promptTemplateResponse(&saved, true, true)
+	return &response, nil
+}
+
+// loadPromptTemplateVersion fetches one stored version of a template
+func (s *LLMService) loadPromptTemplateVersion(language, name string, version int) (*models.PromptTemplate, error) {
+	var stored models.PromptTemplate
+	if err := database.DB.Where("language = ? AND name = ? AND version = ?", language, name, version).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptTemplateVersionNotFound
+		}
+		return nil, fmt.Errorf("error loading prompt template version: %w", err)
+	}
+	return &stored, nil
+}
+
+// samplePromptData builds the data a template is dry-run rendered with, from
+// the language's samples completed with the validation request
+func (s *LLMService) samplePromptData(ctx context.Context, language, name string, req models.ValidatePromptTemplateRequest) TemplateData {
+	definition, _ := findLanguage(language)
+	baseForms := req.BaseForms
+	if len(baseForms) == 0 {
+		baseForms = definition.sampleVerbs
+	}
+
+	switch name {
+	case correctTemplate:
+		sentence := req.Sentence
+		if sentence == "" {
+			sentence = definition.sampleSentence
+		}
+		return TemplateData{Sentence: sentence}
+
+	case repairTemplate:
+		problems := req.Problems
+		if len(problems) == 0 {
+			problems = []string{fmt.Sprintf(definition.messages.missingVerb, baseForms[0])}
+		}
+		keysJSON, _ := json.Marshal(baseForms)
+		return TemplateData{Problems: problems, ExpectedKeysJSON: string(keysJSON)}
+
+	default:
+		sentence := req.Sentence
+		if sentence == "" {
+			// The subject pronoun of the sample sentence
+			sentence = strings.Fields(definition.sampleSentence)[0]
+		}
+		return s.prepareTemplateData(ctx, language, sentence, baseForms, name)
+	}
+}
+
+// promptTemplateWarnings points out rendered prompts that leave out the request
+// data or would be rejected by JSON mode
+func promptTemplateWarnings(name, rendered string, data TemplateData) []string {
+	var warnings []string
+	if name != repairTemplate && !strings.Contains(rendered, data.Sentence) {
+		warnings = append(warnings, "the prompt does not include the sentence ({{.Sentence}})")
+	}
+	if name != correctTemplate && name != repairTemplate && !strings.Contains(rendered, data.BaseFormsJSON) {
+		warnings = append(warnings, "the prompt does not include the verbs ({{.BaseFormsJSON}})")
+	}
+	if name == repairTemplate {
+		for _, problem := range data.Problems {
+			if !strings.Contains(rendered, problem) {
+				warnings = append(warnings, "the prompt does not list the problems ({{range .Problems}})")
+				break
+			}
+		}
+		if !strings.Contains(rendered, data.ExpectedKeysJSON) {
+			warnings = append(warnings, "the prompt does not include the expected keys ({{.ExpectedKeysJSON}})")
+		}
+	}
+	if strings.Contains(rendered, "<no value>") {
+		warnings = append(warnings, "the prompt shows <no value>: it names RAG entries that were not retrieved for these verbs; range over them instead")
+	}
+	if !strings.Contains(strings.ToLower(rendered), "json") {
+		warnings = append(warnings, "the prompt does not mention JSON; OpenAI-compatible backends reject JSON mode requests without it")
+	}
+	return warnings
+}
+
+// promptTemplateResponse converts a stored template version to its response
+func promptTemplateResponse(stored *models.PromptTemplate, active, withSource bool) models.PromptTemplateResponse {
+	response := models.PromptTemplateResponse{
+		Language:  stored.Language,
+		Name:      stored.Name,
+		Version:   stored.Version,
+		Active:    active,
+		Checksum:  contentVersion([]byte(stored.Source)),
+		Comment:   stored.Comment,
+		CreatedBy: stored.CreatedBy,
+		CreatedAt: stored.CreatedAt,
+	}
+	if withSource {
+		response.Source = stored.Source
+	}
+	return response
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/database"
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+// Names of the RAG knowledge versions that are not backups
+const (
+	RagVersionLive = "live" // The knowledge in use
+	RagVersionFile = "file" // The bundled file the knowledge falls back to
+)
+
+// ErrInvalidRagVersion is returned when a RAG knowledge version is neither
+// live, file nor a backup of the language
+var ErrInvalidRagVersion = errors.New("invalid RAG knowledge version")
+
+// maxRagSummaryPaths caps the paths listed in the summary of a recorded change
+const maxRagSummaryPaths = 10
+
+// DiffRagKnowledge compares two versions of the RAG knowledge of a language,
+// each named live, file or by a backup key
+func (s *LLMService) DiffRagKnowledge(language, from, to string) (*models.RagDiffResponse, error) {
+	ctx := context.Background()
+	fromKnowledge, err := s.ragKnowledgeVersion(ctx, language, from)
+	if err != nil {
+		return nil, err
+	}
+	toKnowledge, err := s.ragKnowledgeVersion(ctx, language, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diffRagKnowledge(fromKnowledge, toKnowledge)
+	return &models.RagDiffResponse{
+		Language: language,
+		From:     from,
+		To:       to,
+		Summary:  summarizeRagChanges(changes),
+		Changes:  changes,
+	}, nil
+}
+
+// ragKnowledgeVersion loads a named version of the RAG knowledge of a language
+func (s *LLMService) ragKnowledgeVersion(ctx context.Context, language, name string) (map[string]interface{}, error) {
+	switch name {
+	case "":
+		return nil, fmt.Errorf("%w: name live, file or a backup key", ErrInvalidRagVersion)
+	case RagVersionLive:
+		knowledge := s.GetRagKnowledge(language)
+		if knowledge == nil {
+			return nil, fmt.Errorf("no live RAG knowledge")
+		}
+		return knowledge, nil
+	case RagVersionFile:
+		return readRagFile(ragFile(language))
+	}
+
+	if s.ragStore == nil {
+		return nil, fmt.Errorf("%w: %s is not live or file, and no storage is enabled for backups", ErrInvalidRagVersion, name)
+	}
+	return s.ragStore.GetRagKnowledgeVersion(ctx, ragFile(language), name)
+}
+
+// ListRagKnowledgeChanges returns the recorded changes of the RAG knowledge of
+// a language, newest first
+func (s *LLMService) ListRagKnowledgeChanges(language string, page, limit int) ([]models.RagKnowledgeChange, int64, error) {
+	var changes []models.RagKnowledgeChange
+	var total int64
+
+	query := database.DB.Model(&models.RagKnowledgeChange{}).Where("language = ?", language)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count RAG knowledge changes: %w", err)
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&changes).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve RAG knowledge changes: %w", err)
+	}
+	return changes, total, nil
+}
+
+// recordRagChange records an admin change of the live RAG knowledge, from the
+// document before it to the one in use now; the caller holds ragEditMu. A
+// failure to record is logged without undoing the change.
+func (s *LLMService) recordRagChange(language, action, source, userID string, before *ragDocument) {
+	after := s.languageRag(language)
+	changes := diffRagKnowledge(before.data, after.data)
+	summary := summarizeRagChanges(changes)
+	change := models.RagKnowledgeChange{
+		Language:    language,
+		Action:      action,
+		Source:      source,
+		UserID:      userID,
+		FromVersion: before.version,
+		ToVersion:   after.version,
+		Added:       summary.Added,
+		Removed:     summary.Removed,
+		Changed:     summary.Changed,
+		Summary:     describeRagChanges(changes),
+	}
+	log.Printf("[RAG] %s knowledge %s by userId %s: %s", language, action, userID, change.Summary)
+
+	if database.DB == nil {
+		return
+	}
+	if err := database.DB.Create(&change).Error; err != nil {
+		log.Printf("[RAG] Failed to record %s knowledge %s by userId %s: %v", language, action, userID, err)
+	}
+}
+
+// diffRagKnowledge lists the differences between two RAG knowledge documents,
+// in key order. Objects are compared member by member; other values,
+// arrays included, are compared whole.
+func diffRagKnowledge(from, to map[string]interface{}) []models.RagChange {
+	changes := []models.RagChange{}
+	diffRagObjects("", from, to, &changes)
+	return changes
+}
+
+// diffRagObjects appends the differences between two objects at path
+func diffRagObjects(path string, from, to map[string]interface{}, changes *[]models.RagChange) {
+	keys := sortedKeys(from)
+	for _, key := range sortedKeys(to) {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := ragPointer(path, key)
+		oldValue, inFrom := from[key]
+		newValue, inTo := to[key]
+		switch {
+		case !inTo:
+			*changes = append(*changes, models.RagChange{Type: "removed", Path: keyPath, Old: oldValue})
+		case !inFrom:
+			*changes = append(*changes, models.RagChange{Type: "added", Path: keyPath, New: newValue})
+		default:
+			oldObject, oldIsObject := oldValue.(map[string]interface{})
+			newObject, newIsObject := newValue.(map[string]interface{})
+			if oldIsObject && newIsObject {
+				diffRagObjects(keyPath, oldObject, newObject, changes)
+			} else if !reflect.DeepEqual(oldValue, newValue) {
+				*changes = append(*changes, models.RagChange{Type: "changed", Path: keyPath, Old: oldValue, New: newValue})
+			}
+		}
+	}
+}
+
+// summarizeRagChanges counts changes by type
+func summarizeRagChanges(changes []models.RagChange) models.RagDiffSummary {
+	var summary models.RagDiffSummary
+	for _, change := range changes {
+		switch change.Type {
+		case "added":
+			summary.Added++
+		case "removed":
+			summary.Removed++
+		case "changed":
+			summary.Changed++
+		}
+	}
+	return summary
+}
+
+// describeRagChanges summarizes changes in a line, e.g. "1 added, 1 changed:
+// added /passato_prossimo/irregular_participles/correre, changed /imperfetto/description"
+func describeRagChanges(changes []models.RagChange) string {
+	if len(changes) == 0 {
+		return "no changes"
+	}
+
+	summary := summarizeRagChanges(changes)
+	var counts []string
+	for _, count := range []struct {
+		n    int
+		kind string
+	}{{summary.Added, "added"}, {summary.Removed, "removed"}, {summary.Changed, "changed"}} {
+		if count.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count.n, count.kind))
+		}
+	}
+
+	var paths []string
+	for i, change := range changes {
+		if i == maxRagSummaryPaths {
+			paths = append(paths, fmt.Sprintf("and %d more", len(changes)-i))
+			break
+		}
+		paths = append(paths, change.Type+" "+change.Path)
+	}
+	return strings.Join(counts, ", ") + ": " + strings.Join(paths, ", ")
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+var (
+	// ErrInvalidRagPatch is returned when a JSON Patch operation is malformed or
+	// names a path the RAG knowledge does not have
+	ErrInvalidRagPatch = errors.New("invalid RAG knowledge patch")
+	// ErrRagPatchTestFailed is returned when a test operation of a JSON Patch
+	// finds a different value, e.g. because another admin edited the knowledge
+	ErrRagPatchTestFailed = errors.New("RAG knowledge patch test failed")
+)
+
+// applyRagPatch applies a JSON Patch (RFC 6902) to a copy of a RAG knowledge
+// document. Operations apply in order and the patch is all or nothing.
+func applyRagPatch(knowledge map[string]interface{}, operations []models.RagPatchOperation) (map[string]interface{}, error) {
+	var doc interface{} = deepCopyJSON(knowledge)
+	for i, op := range operations {
+		var err error
+		if doc, err = applyRagPatchOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	patched, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: the RAG knowledge must stay an object", ErrInvalidRagPatch)
+	}
+	return patched, nil
+}
+
+// applyRagPatchOperation applies one operation to doc and returns the result
+func applyRagPatchOperation(doc interface{}, op models.RagPatchOperation) (interface{}, error) {
+	path, err := parseRagPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return ragPointerAdd(doc, path, deepCopyJSON(op.Value))
+	case "remove":
+		doc, _, err := ragPointerRemove(doc, path)
+		return doc, err
+	case "replace":
+		if len(path) == 0 {
+			return deepCopyJSON(op.Value), nil
+		}
+		doc, _, err := ragPointerRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return ragPointerAdd(doc, path, deepCopyJSON(op.Value))
+	case "move", "copy":
+		from, err := parseRagPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidRagPatch)
+		}
+		var value interface{}
+		if op.Op == "move" {
+			doc, value, err = ragPointerRemove(doc, from)
+		} else {
+			value, err = ragPointerGet(doc, from)
+			value = deepCopyJSON(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ragPointerAdd(doc, path, value)
+	case "test":
+		value, err := ragPointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, normalizeJSON(op.Value)) {
+			return nil, ErrRagPatchTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q (add, remove, replace, move, copy or test)", ErrInvalidRagPatch, op.Op)
+}
+
+// parseRagPointer splits a JSON Pointer (RFC 6901) into its unescaped tokens
+func parseRagPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidRagPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// ragPointerGet returns the value at path
+func ragPointerGet(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, ragPathError(path[:i+1], "does not exist")
+			}
+			doc = value
+		case []interface{}:
+			index, err := ragArrayIndex(path[:i+1], token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, ragPathError(path[:i+1], "is inside %s", jsonType(node))
+		}
+	}
+	return doc, nil
+}
+
+// ragPointerAdd sets the value at path, creating the member of an object,
+// inserting into an array (- appends) or replacing the whole document
+func ragPointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := ragPointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+		return doc, nil
+	case []interface{}:
+		index := len(node)
+		if token != "-" {
+			if index, err = ragArrayIndex(path, token, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node, nil)
+		copy(node[index+1:], node[index:])
+		node[index] = value
+		return ragPointerSet(doc, path[:len(path)-1], node)
+	}
+	return nil, ragPathError(path, "is inside %s", jsonType(parent))
+}
+
+// ragPointerRemove removes the value at path and returns it
+func ragPointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole RAG knowledge", ErrInvalidRagPatch)
+	}
+	parent, err := ragPointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[token]
+		if !ok {
+			return nil, nil, ragPathError(path, "does not exist")
+		}
+		delete(node, token)
+		return doc, value, nil
+	case []interface{}:
+		index, err := ragArrayIndex(path, token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		doc, err = ragPointerSet(doc, path[:len(path)-1], node)
+		return doc, value, err
+	}
+	return nil, nil, ragPathError(path, "is inside %s", jsonType(parent))
+}
+
+// ragPointerSet replaces the value at path, used to store arrays that grew or shrank
+func ragPointerSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := ragPointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		index, _ := strconv.Atoi(token)
+		node[index] = value
+	}
+	return doc, nil
+}
+
+// ragArrayIndex parses an array index of a path, up to last
+func ragArrayIndex(path []string, token string, last int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > last || (len(token) > 1 && token[0] == '0') {
+		return 0, ragPathError(path, "is not an index of the array")
+	}
+	return index, nil
+}
+
+// ragPathError reports a problem of a patch path
+func ragPathError(path []string, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidRagPatch, ragPointer("", path...), fmt.Sprintf(format, args...))
+}
+
+// deepCopyJSON copies a decoded JSON value
+func deepCopyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = deepCopyJSON(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// normalizeJSON converts a value to the types encoding/json decodes into, so
+// it compares equal to decoded documents
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/daniele/web-app-caa/internal/config"
+	"github.com/daniele/web-app-caa/pkg/ollama"
+)
+
+// Kinds of RAG entries
+const (
+	ragEntryScalar = "scalar" // A value of the section itself, such as its description: always injected
+	ragEntryVerb   = "verb"   // An entry of an irregular_* group, injected for the verbs it names
+	ragEntryRule   = "rule"   // A rule, injected for the verbs with the endings it names, or always
+)
+
+// ragEntry is a piece of a tense's RAG section that can be injected on its own:
+// a value of the section, or an entry of one of its groups (an irregular verb,
+// a conjugation rule)
+type ragEntry struct {
+	id      string // section/group/key, or section/group for values of the section
+	section string
+	group   string
+	key     string // Empty for values of the section
+	kind    string
+	value   interface{}
+	// lemmas are the verbs the entry is about: the key of a verb entry
+	// (Essere (to be) -> essere, ser / ir -> ser, ir), the model verbs of a rule
+	lemmas []string
+	// endings are the infinitive endings a rule applies to (-are verbs -> are)
+	endings []string
+}
+
+// text is what the entry is compared with by embeddings: the verbs it is about,
+// else its key
+func (e *ragEntry) text() string {
+	if len(e.lemmas) > 0 {
+		return strings.Join(e.lemmas, " ")
+	}
+	return strings.ToLower(e.key)
+}
+
+// ragIndex indexes the RAG knowledge of a language per verb and per rule, so a
+// prompt gets only the entries relevant to its verbs
+type ragIndex struct {
+	entries   []ragEntry
+	bySection map[string][]int // Entries of each tense's section, in index order
+	vectorsMu sync.Mutex       // Guards vectors, computed the first time embeddings need them
+	vectors   map[int][]float64
+}
+
+// newRagIndex indexes the sections of the language's tenses. Groups whose name
+// starts with irregular_ hold verb entries, the other groups rules.
+func newRagIndex(language string, knowledge map[string]interface{}) *ragIndex {
+	index := &ragIndex{bySection: make(map[string][]int), vectors: make(map[int][]float64)}
+	definition, ok := findLanguage(language)
+	if !ok {
+		return index
+	}
+
+	for _, tense := range definition.tenses {
+		section, ok := knowledge[tense.ragSection].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, group := range sortedKeys(section) {
+			entries, ok := section[group].(map[string]interface{})
+			if !ok {
+				index.add(ragEntry{
+					id:      tense.ragSection + "/" + group,
+					section: tense.ragSection,
+					group:   group,
+					kind:    ragEntryScalar,
+					value:   section[group],
+				})
+				continue
+			}
+
+			for _, key := range sortedKeys(entries) {
+				entry := ragEntry{
+					id:      tense.ragSection + "/" + group + "/" + key,
+					section: tense.ragSection,
+					group:   group,
+					key:     key,
+					value:   entries[key],
+				}
+				if strings.HasPrefix(group, "irregular_") {
+					entry.kind = ragEntryVerb
+					entry.lemmas = keyVerbs(key)
+				} else {
+					entry.kind = ragEntryRule
+					entry.lemmas, entry.endings = ruleVerbs(key)
+				}
+				index.add(entry)
+			}
+		}
+	}
+	return index
+}
+
+// add appends an entry to the index
+func (idx *ragIndex) add(entry ragEntry) {
+	idx.bySection[entry.section] = append(idx.bySection[entry.section], len(idx.entries))
+	idx.entries = append(idx.entries, entry)
+}
+
+// sortedKeys returns the keys of a map in order, so indexes and prompts are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyVerbs returns the verbs named by the key of a verb entry, without the
+// translation in parentheses: "Essere (to be)" is essere, "ser / ir" ser and ir
+func keyVerbs(key string) []string {
+	if i := strings.Index(key, "("); i >= 0 {
+		key = key[:i]
+	}
+	var verbs []string
+	for _, part := range strings.FieldsFunc(key, func(r rune) bool { return r == '/' || r == ',' }) {
+		if verb := strings.ToLower(strings.TrimSpace(part)); verb != "" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+// ruleVerbs returns the model verbs in parentheses and the endings of a rule
+// key: "-er/-ir verbs (comer, vivir)" gives comer, vivir and er, ir. Infixes
+// such as -isc- are not endings.
+func ruleVerbs(key string) ([]string, []string) {
+	var lemmas, endings []string
+	if match := parenthesesPattern.FindStringSubmatch(key); match != nil {
+		for _, part := range strings.Split(match[1], ",") {
+			if verb := strings.ToLower(strings.TrimSpace(part)); verb != "" {
+				lemmas = append(lemmas, verb)
+			}
+		}
+	}
+	for _, word := range strings.Fields(key) {
+		for _, part := range strings.Split(word, "/") {
+			if strings.HasPrefix(part, "-") && !strings.HasSuffix(part, "-") && len(part) > 1 {
+				endings = append(endings, strings.ToLower(part[1:]))
+			}
+		}
+	}
+	return lemmas, endings
+}
+
+// ragLemmas returns the forms a requested verb is looked up by: the verb
+// itself, lowercased, and its base form when it is reflexive (lavarsi is also
+// lavare, levantarse levantar) or has the English infinitive marker (to go)
+func ragLemmas(language, verb string) []string {
+	verb = strings.ToLower(strings.TrimSpace(verb))
+	if verb == "" {
+		return nil
+	}
+	lemmas := []string{verb}
+	switch language {
+	case LanguageItalian:
+		if base, ok := reflexiveBase(verb); ok {
+			lemmas = append(lemmas, base)
+		}
+	case LanguageSpanish:
+		if base, ok := strings.CutSuffix(verb, "se"); ok && len(base) > 2 && strings.HasSuffix(base, "r") {
+			lemmas = append(lemmas, base)
+		}
+	case LanguageEnglish:
+		if base, ok := strings.CutPrefix(verb, "to "); ok && base != "" {
+			lemmas = append(lemmas, strings.TrimSpace(base))
+		}
+	}
+	return lemmas
+}
+
+// matches tells whether a verb entry is about one of lemmas, or a rule applies
+// to one of them. Rules that name no ending apply to every verb.
+func (e *ragEntry) matches(lemmas []string) bool {
+	switch e.kind {
+	case ragEntryScalar:
+		return true
+	case ragEntryRule:
+		if len(e.endings) == 0 {
+			return true
+		}
+	}
+
+	for _, lemma := range lemmas {
+		for _, verb := range e.lemmas {
+			if lemma == verb {
+				return true
+			}
+		}
+		if e.kind == ragEntryRule {
+			for _, ending := range e.endings {
+				if strings.HasSuffix(lemma, ending) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ragSelection is the part of a tense's RAG section injected into a prompt
+type ragSelection struct {
+	// knowledge has the shape of the RAG knowledge, with the tense's section
+	// holding only the selected entries
+	knowledge map[string]interface{}
+	entries   []string // IDs of the selected entries
+	total     int      // Entries of the section
+}
+
+// retrieve selects the entries of a tense's section relevant to the requested
+// verbs: the values of the section, the rules that apply to them and the
+// irregular verbs they are or whose lemma they have. Verbs left without a verb
+// entry are matched by embeddings to the closest remaining entries when a
+// backend is configured, e.g. rivedere to vedere.
+func (idx *ragIndex) retrieve(ctx context.Context, retriever *ragRetriever, language, section string, baseForms []string) ragSelection {
+	candidates := idx.bySection[section]
+	selected := make(map[int]bool, len(candidates))
+	var unmatched []string
+	for _, verb := range baseForms {
+		lemmas := ragLemmas(language, verb)
+		if len(lemmas) == 0 {
+			continue
+		}
+		found := false
+		for _, i := range candidates {
+			entry := &idx.entries[i]
+			if entry.matches(lemmas) {
+				selected[i] = true
+				found = found || entry.kind == ragEntryVerb
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, lemmas[len(lemmas)-1])
+		}
+	}
+	// Values of the section and general rules go in even without verbs
+	for _, i := range candidates {
+		if idx.entries[i].matches(nil) {
+			selected[i] = true
+		}
+	}
+
+	if len(unmatched) > 0 && retriever.embedder != nil {
+		if err := idx.retrieveSimilar(ctx, retriever, candidates, selected, unmatched); err != nil {
+			log.Printf("[RAG] Embeddings unavailable, using exact and lemma matches only: %v", err)
+		}
+	}
+
+	selection := ragSelection{
+		knowledge: map[string]interface{}{section: idx.sectionOf(candidates, selected)},
+		total:     len(candidates),
+	}
+	for _, i := range candidates {
+		if selected[i] {
+			selection.entries = append(selection.entries, idx.entries[i].id)
+		}
+	}
+	return selection
+}
+
+// retrieveSimilar adds to selected, for each verb, the entries of candidates
+// most similar to it by embeddings
+func (idx *ragIndex) retrieveSimilar(ctx context.Context, retriever *ragRetriever, candidates []int, selected map[int]bool, verbs []string) error {
+	var pending []int
+	for _, i := range candidates {
+		if !selected[i] {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	vectors, err := idx.entryVectors(ctx, retriever.embedder, pending)
+	if err != nil {
+		return err
+	}
+	queries, err := retriever.embedder.Embed(ctx, verbs)
+	if err != nil {
+		return err
+	}
+
+	type scored struct {
+		entry int
+		score float64
+	}
+	for q, query := range queries {
+		var matches []scored
+		for _, i := range pending {
+			if score := cosineSimilarity(query, vectors[i]); score >= retriever.minScore {
+				matches = append(matches, scored{i, score})
+			}
+		}
+		sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+		for k, match := range matches {
+			if k >= retriever.topK {
+				break
+			}
+			selected[match.entry] = true
+			log.Printf("[RAG] %q matched %s by embeddings (%.2f)", verbs[q], idx.entries[match.entry].id, match.score)
+		}
+	}
+	return nil
+}
+
+// entryVectors returns the embeddings of entries, computing the missing ones
+func (idx *ragIndex) entryVectors(ctx context.Context, embedder RagEmbedder, entries []int) (map[int][]float64, error) {
+	idx.vectorsMu.Lock()
+	defer idx.vectorsMu.Unlock()
+
+	var missing []int
+	var texts []string
+	for _, i := range entries {
+		if _, ok := idx.vectors[i]; !ok {
+			missing = append(missing, i)
+			texts = append(texts, idx.entries[i].text())
+		}
+	}
+	if len(missing) > 0 {
+		computed, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range missing {
+			idx.vectors[i] = computed[j]
+		}
+	}
+
+	vectors := make(map[int][]float64, len(entries))
+	for _, i := range entries {
+		vectors[i] = idx.vectors[i]
+	}
+	return vectors, nil
+}
+
+// sectionOf rebuilds a section holding only the selected entries
+func (idx *ragIndex) sectionOf(candidates []int, selected map[int]bool) map[string]interface{} {
+	section := make(map[string]interface{})
+	for _, i := range candidates {
+		entry := &idx.entries[i]
+		if !selected[i] {
+			continue
+		}
+		if entry.kind == ragEntryScalar {
+			section[entry.group] = entry.value
+			continue
+		}
+		group, ok := section[entry.group].(map[string]interface{})
+		if !ok {
+			group = make(map[string]interface{})
+			section[entry.group] = group
+		}
+		group[entry.key] = entry.value
+	}
+	return section
+}
+
+// all selects every entry of a tense's section, as injected without retrieval
+func (idx *ragIndex) all(knowledge map[string]interface{}, section string) ragSelection {
+	selection := ragSelection{knowledge: knowledge, total: len(idx.bySection[section])}
+	for _, i := range idx.bySection[section] {
+		selection.entries = append(selection.entries, idx.entries[i].id)
+	}
+	return selection
+}
+
+// RagEmbedder computes embeddings of texts, to match verbs to the RAG entries
+// they have no exact or lemma match for
+type RagEmbedder interface {
+	// Name identifies the backend in logs and cache keys
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// ragRetriever holds the retrieval settings of the LLM service
+type ragRetriever struct {
+	enabled  bool
+	embedder RagEmbedder // nil without embeddings
+	topK     int
+	minScore float64
+}
+
+// newRagRetriever configures retrieval and its embeddings backend
+func newRagRetriever(cfg config.RAGConfig) *ragRetriever {
+	retriever := &ragRetriever{enabled: cfg.Retrieval, topK: max(cfg.TopK, 0), minScore: cfg.MinScore}
+	switch cfg.Embeddings {
+	case "", "none":
+	case "ollama":
+		retriever.embedder = &ollamaEmbedder{
+			host:  cfg.EmbeddingsHost,
+			model: cfg.EmbeddingsModel,
+			client: ollama.NewClient(&ollama.ClientConfig{
+				BaseURL: cfg.EmbeddingsHost,
+				Timeout: cfg.EmbeddingsTimeout,
+			}),
+		}
+	case "local":
+		retriever.embedder = localEmbedder{}
+	default:
+		log.Printf("[RAG] Unknown RAG_EMBEDDINGS %q, retrieving by exact and lemma matches only", cfg.Embeddings)
+	}
+	return retriever
+}
+
+// signature describes the settings that change which entries are selected,
+// part of the cache key
+func (r *ragRetriever) signature() string {
+	if !r.enabled {
+		return "all"
+	}
+	if r.embedder == nil {
+		return "lemma"
+	}
+	return fmt.Sprintf("%s:%d:%g", r.embedder.Name(), r.topK, r.minScore)
+}
+
+// ollamaEmbedder computes embeddings with an Ollama embedding model
+type ollamaEmbedder struct {
+	host   string
+	model  string
+	client ollama.Client
+}
+
+func (e *ollamaEmbedder) Name() string {
+	return fmt.Sprintf("ollama(%s)/%s", e.host, e.model)
+}
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector, err := e.client.Embeddings(ctx, e.model, text)
+		if err != nil {
+			return nil, fmt.Errorf("ollama embeddings failed: %w", err)
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// localEmbedderSize is the number of dimensions of the local embeddings
+const localEmbedderSize = 256
+
+// localEmbedder is a stand-in for an embedding model that needs no backend: it
+// hashes the character trigrams of each word, so verbs sharing most of their
+// letters (vedere, rivedere) are close
+type localEmbedder struct{}
+
+func (localEmbedder) Name() string {
+	return "local"
+}
+
+func (localEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector := make([]float64, localEmbedderSize)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+		for _, word := range words {
+			runes := []rune(" " + word + " ")
+			for j := 0; j+3 <= len(runes); j++ {
+				h := fnv.New32a()
+				h.Write([]byte(string(runes[j : j+3])))
+				vector[h.Sum32()%localEmbedderSize]++
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// cosineSimilarity compares two embeddings; vectors of different sizes or
+// without length are not similar
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/daniele/web-app-caa/internal/models"
+)
+
+// ErrInvalidRagKnowledge is returned when a RAG knowledge write would leave a
+// document that does not match the schema
+var ErrInvalidRagKnowledge = errors.New("invalid RAG knowledge")
+
+// RagValidationError lists the problems that made a RAG knowledge write invalid
+type RagValidationError struct {
+	Problems []models.RagProblem
+}
+
+func (e *RagValidationError) Error() string {
+	first := e.Problems[0]
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("%v: %s: %s", ErrInvalidRagKnowledge, first.Path, first.Message)
+	}
+	return fmt.Sprintf("%v: %s: %s (and %d more problems)", ErrInvalidRagKnowledge, first.Path, first.Message, len(e.Problems)-1)
+}
+
+func (e *RagValidationError) Unwrap() error {
+	return ErrInvalidRagKnowledge
+}
+
+// Shapes of the groups of a tense section and of their entries
+const (
+	ragText         = "text"
+	ragEntries      = "entries"
+	ragTextOrEntry  = "text_or_entry"
+	ragObject       = "object"
+	ragTextOrObject = "text_or_object"
+)
+
+// ragGroupSchemas describes the groups a tense section may hold. A section is
+// made of some of them; description is the only one every section needs.
+var ragGroupSchemas = map[string]models.RagGroupSchema{
+	"description": {
+		Type: ragText, Required: true,
+		Description: "When the tense is used, shown with the tense in /tenses",
+	},
+	"rule": {
+		Type:        ragText,
+		Description: "How the tense is formed",
+	},
+	"regular_rule": {
+		Type: ragTextOrEntry, Fields: []string{"rule", "example"}, RequiredFields: []string{"rule"},
+		Description: "How regular verbs form the tense",
+	},
+	"regular_participles": {
+		Type:        ragText,
+		Description: "Endings of the regular past participles",
+	},
+	"endings": {
+		Type: ragText, Forms: true,
+		Description: "Endings of the six persons",
+	},
+	"irregular_example": {
+		Type:        ragText,
+		Description: "Example sentence with irregular forms",
+	},
+	"general_rules": {
+		Type: ragEntries, Entry: ragObject, Forms: true,
+		Fields: []string{"conjugation", "example", "notes"}, RequiredFields: []string{"conjugation"},
+		Description: "Conjugation of each regular class, keyed by ending and model verb, e.g. \"-are verbs (parlare)\"",
+	},
+	"regular_endings": {
+		Type: ragEntries, Entry: ragObject, Forms: true,
+		Fields: []string{"endings", "example", "notes"}, RequiredFields: []string{"endings"},
+		Description: "Endings of each regular class, keyed by ending and model verb, e.g. \"-are verbs (parlare)\"",
+	},
+	"auxiliary_choice": {
+		Type: ragEntries, Entry: ragObject,
+		Fields: []string{"rule", "example"}, RequiredFields: []string{"rule"},
+		Description: "When each auxiliary verb is used, keyed by auxiliary",
+	},
+	"stem_changes": {
+		Type: ragEntries, Entry: ragText,
+		Description: "Stem changing verbs, keyed by change and model verb, e.g. \"e -> ie (querer)\"",
+	},
+	"irregular_verbs": {
+		Type: ragEntries, Entry: ragTextOrObject, Forms: true,
+		Fields: []string{"conjugation", "example", "notes"}, RequiredFields: []string{"conjugation"},
+		Description: "Forms of the six persons of each irregular verb, keyed by infinitive",
+	},
+	"irregular_participles": {
+		Type: ragEntries, Entry: ragText,
+		Description: "Past participle of each irregular verb, keyed by infinitive",
+	},
+	"irregular_roots": {
+		Type: ragEntries, Entry: ragText,
+		Description: "Root of each irregular verb, keyed by infinitive",
+	},
+}
+
+// ragSchema describes the RAG knowledge document of a language
+func ragSchema(language string) models.RagSchemaResponse {
+	definition, _ := findLanguage(language)
+	schema := models.RagSchemaResponse{Language: language, Groups: ragGroupSchemas}
+	for _, tense := range definition.tenses {
+		schema.Sections = append(schema.Sections, tense.ragSection)
+	}
+	return schema
+}
+
+// validateRagKnowledge checks a RAG knowledge document against the schema of
+// its language: one object per tense section, made of known groups of the
+// right shape. In the languages the rule-based conjugator reads, forms and
+// endings must list the six persons.
+func validateRagKnowledge(language string, knowledge map[string]interface{}) []models.RagProblem {
+	definition, ok := findLanguage(language)
+	if !ok {
+		return []models.RagProblem{{Path: "", Message: fmt.Sprintf("unsupported language %q", language)}}
+	}
+
+	v := &ragValidator{forms: definition.rules}
+	sections := make(map[string]bool, len(definition.tenses))
+	for _, tense := range definition.tenses {
+		sections[tense.ragSection] = true
+		value, ok := knowledge[tense.ragSection]
+		if !ok {
+			v.problem(ragPointer("", tense.ragSection), "missing section of tense %s", tense.id)
+			continue
+		}
+		v.section(ragPointer("", tense.ragSection), value)
+	}
+	for _, name := range sortedKeys(knowledge) {
+		if !sections[name] {
+			v.problem(ragPointer("", name), "unknown section; the %s sections are %s", language, strings.Join(ragSchema(language).Sections, ", "))
+		}
+	}
+	return v.problems
+}
+
+// ragValidator collects the problems of a RAG knowledge document
+type ragValidator struct {
+	forms    bool // Check that forms and endings list six persons
+	problems []models.RagProblem
+}
+
+func (v *ragValidator) problem(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, models.RagProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// section checks a tense section
+func (v *ragValidator) section(path string, value interface{}) {
+	section, ok := value.(map[string]interface{})
+	if !ok {
+		v.problem(path, "must be an object, not %s", jsonType(value))
+		return
+	}
+
+	for _, name := range sortedKeys(ragGroupSchemas) {
+		if _, ok := section[name]; !ok && ragGroupSchemas[name].Required {
+			v.problem(ragPointer(path, name), "missing %s", name)
+		}
+	}
+	for _, name := range sortedKeys(section) {
+		groupPath := ragPointer(path, name)
+		schema, ok := ragGroupSchemas[name]
+		if !ok {
+			v.problem(groupPath, "unknown group; the groups are %s", strings.Join(sortedKeys(ragGroupSchemas), ", "))
+			continue
+		}
+		v.group(groupPath, schema, section[name])
+	}
+}
+
+// group checks a group of a section against its schema
+func (v *ragValidator) group(path string, schema models.RagGroupSchema, value interface{}) {
+	switch schema.Type {
+	case ragText:
+		v.text(path, value, schema.Forms)
+
+	case ragTextOrEntry:
+		if _, ok := value.(string); ok {
+			v.text(path, value, false)
+			return
+		}
+		v.object(path, value, schema, "a string or an object")
+
+	case ragEntries:
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			v.problem(path, "must be an object of entries, not %s", jsonType(value))
+			return
+		}
+		for _, key := range sortedKeys(entries) {
+			entryPath := ragPointer(path, key)
+			if strings.TrimSpace(key) == "" {
+				v.problem(entryPath, "entries need a name")
+				continue
+			}
+			v.entry(entryPath, schema, entries[key])
+		}
+	}
+}
+
+// entry checks an entry of an entries group
+func (v *ragValidator) entry(path string, schema models.RagGroupSchema, value interface{}) {
+	switch schema.Entry {
+	case ragText:
+		v.text(path, value, schema.Forms)
+	case ragObject:
+		v.object(path, value, schema, "an object")
+	case ragTextOrObject:
+		if _, ok := value.(string); ok {
+			v.text(path, value, schema.Forms)
+			return
+		}
+		v.object(path, value, schema, "a string or an object")
+	}
+}
+
+// object checks an object entry: the fields of the schema, all strings, with
+// the required ones present. The first required field holds the forms.
+func (v *ragValidator) object(path string, value interface{}, schema models.RagGroupSchema, expected string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.problem(path, "must be %s, not %s", expected, jsonType(value))
+		return
+	}
+
+	for _, field := range schema.RequiredFields {
+		if _, ok := object[field]; !ok {
+			v.problem(ragPointer(path, field), "missing %s", field)
+		}
+	}
+	for _, field := range sortedKeys(object) {
+		fieldPath := ragPointer(path, field)
+		known := false
+		for _, name := range schema.Fields {
+			known = known || name == field
+		}
+		if !known {
+			v.problem(fieldPath, "unknown field; the fields are %s", strings.Join(schema.Fields, ", "))
+			continue
+		}
+		v.text(fieldPath, object[field], schema.Forms && len(schema.RequiredFields) > 0 && field == schema.RequiredFields[0])
+	}
+}
+
+// text checks a string value, and that it lists six forms when forms is set
+func (v *ragValidator) text(path string, value interface{}, forms bool) {
+	text, ok := value.(string)
+	if !ok {
+		v.problem(path, "must be a string, not %s", jsonType(value))
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		v.problem(path, "must not be empty")
+		return
+	}
+	if forms && v.forms {
+		if count := len(strings.Split(text, ",")); count != 6 {
+			v.problem(path, "must list the forms of the six persons separated by commas, found %d", count)
+		}
+	}
+}
+
+// ragPointer appends tokens to a JSON Pointer, escaping them (RFC 6901)
+func ragPointer(base string, tokens ...string) string {
+	var pointer strings.Builder
+	pointer.WriteString(base)
+	for _, token := range tokens {
+		pointer.WriteString("/")
+		pointer.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return pointer.String()
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
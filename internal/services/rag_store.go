@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daniele/web-app-caa/internal/config"
+)
+
+// ErrRagStorageDisabled is returned by RAG knowledge operations that need a
+// storage when none is configured
+var ErrRagStorageDisabled = errors.New("RAG knowledge storage is not enabled")
+
+// ragBackupTimestamp is the layout of the timestamp in backup keys; it sorts
+// in time order, and the microseconds keep backups made within a second apart
+const ragBackupTimestamp = "20060102_150405.000000"
+
+// RagStore persists the RAG knowledge files of the languages and their
+// timestamped backups. S3StorageService stores them in the configured bucket;
+// LocalRagStore keeps them in a local directory. Versions are the knowledge
+// file and its backups, named by key.
+type RagStore interface {
+	Name() string
+	GetRagKnowledge(ctx context.Context, file string) (map[string]interface{}, error)
+	PutRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error
+	BackupRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error
+	ListRagKnowledgeVersions(ctx context.Context, file string) ([]S3Object, error)
+	// GetRagKnowledgeVersion reads a version of a knowledge file, failing with
+	// ErrInvalidRagVersion for keys that are not one of its versions
+	GetRagKnowledgeVersion(ctx context.Context, file, key string) (map[string]interface{}, error)
+	// PruneRagKnowledgeBackups deletes the oldest backups of a knowledge file
+	// beyond keep and returns how many were deleted
+	PruneRagKnowledgeBackups(ctx context.Context, file string, keep int) (int, error)
+	CheckHealth(ctx context.Context) error
+}
+
+// NewRagStore creates the configured RAG knowledge storage, nil when none is.
+// Without a backend configured S3 is used when enabled, else the local directory.
+func NewRagStore(cfg *config.Config) RagStore {
+	backend := cfg.RagStorage.Backend
+	if backend == "" {
+		backend = "local"
+		if cfg.S3.Enabled {
+			backend = "s3"
+		}
+	}
+
+	switch backend {
+	case "s3":
+		s3Storage := NewS3StorageService(cfg)
+		if !s3Storage.IsEnabled() {
+			log.Printf("[RAG] Warning: S3 storage is not available, RAG knowledge edits only change memory")
+			return nil
+		}
+		return s3Storage
+	case "local":
+		return NewLocalRagStore(cfg.RagStorage.LocalDir)
+	case "none":
+		log.Printf("[RAG] RAG knowledge storage is disabled, edits only change memory")
+		return nil
+	}
+	log.Printf("[RAG] Warning: unknown RAG_STORAGE %q (s3, local or none), RAG knowledge edits only change memory", backend)
+	return nil
+}
+
+// expiredRagBackups returns the backup keys beyond the newest keep; the
+// timestamp in the keys orders them
+func expiredRagBackups(keys []string, keep int) []string {
+	if keep <= 0 || len(keys) <= keep {
+		return nil
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	return sorted[keep:]
+}
+
+// LocalRagStore is a RagStore backed by a local directory. Knowledge files are
+// stored by name and backups below backups/, with keys relative to the directory
+// (backups/rag_knowledge.es_20240829_143052.123456.json).
+type LocalRagStore struct {
+	dir string
+}
+
+// NewLocalRagStore creates a RAG knowledge store that writes below dir
+func NewLocalRagStore(dir string) *LocalRagStore {
+	if err := os.MkdirAll(filepath.Join(dir, "backups"), 0755); err != nil {
+		log.Printf("[RAG] Warning: Failed to create RAG knowledge directory %s: %v", dir, err)
+	}
+	log.Printf("[RAG] Storing RAG knowledge in %s", dir)
+	return &LocalRagStore{dir: dir}
+}
+
+// Name describes the store in logs and messages
+func (s *LocalRagStore) Name() string {
+	return "local directory " + s.dir
+}
+
+// GetRagKnowledge reads a knowledge file from the directory
+func (s *LocalRagStore) GetRagKnowledge(ctx context.Context, file string) (map[string]interface{}, error) {
+	return s.read(file)
+}
+
+// PutRagKnowledge writes a knowledge file to the directory
+func (s *LocalRagStore) PutRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error {
+	if err := s.write(file, knowledge, true); err != nil {
+		return err
+	}
+	log.Printf("[RAG] Saved RAG knowledge to %s", filepath.Join(s.dir, file))
+	return nil
+}
+
+// BackupRagKnowledge writes a timestamped backup of a knowledge file; an
+// existing backup is never overwritten
+func (s *LocalRagStore) BackupRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error {
+	key := s.backupPrefix(file) + time.Now().Format(ragBackupTimestamp) + ".json"
+	if err := s.write(key, knowledge, false); err != nil {
+		return err
+	}
+	log.Printf("[RAG] Created RAG knowledge backup %s", key)
+	return nil
+}
+
+// ListRagKnowledgeVersions lists a knowledge file in the directory and its backups
+func (s *LocalRagStore) ListRagKnowledgeVersions(ctx context.Context, file string) ([]S3Object, error) {
+	var objects []S3Object
+	if info, err := os.Stat(filepath.Join(s.dir, file)); err == nil {
+		objects = append(objects, S3Object{Key: file, LastModified: info.ModTime(), Size: info.Size()})
+	}
+
+	keys, err := s.backupKeys(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		info, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(key)))
+		if err != nil {
+			continue
+		}
+		objects = append(objects, S3Object{Key: key, LastModified: info.ModTime(), Size: info.Size()})
+	}
+	return objects, nil
+}
+
+// GetRagKnowledgeVersion reads a knowledge file or one of its backups
+func (s *LocalRagStore) GetRagKnowledgeVersion(ctx context.Context, file, key string) (map[string]interface{}, error) {
+	if !s.isKnowledgeVersion(file, key) {
+		return nil, fmt.Errorf("%w: %s is not a backup of %s", ErrInvalidRagVersion, key, file)
+	}
+	return s.read(key)
+}
+
+// PruneRagKnowledgeBackups deletes the oldest backups of a knowledge file beyond keep
+func (s *LocalRagStore) PruneRagKnowledgeBackups(ctx context.Context, file string, keep int) (int, error) {
+	keys, err := s.backupKeys(file)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, key := range expiredRagBackups(keys, keep) {
+		if err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key))); err != nil {
+			return pruned, fmt.Errorf("error deleting backup %s: %w", key, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// CheckHealth verifies that the directory is writable
+func (s *LocalRagStore) CheckHealth(ctx context.Context) error {
+	probe, err := os.CreateTemp(s.dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", s.dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// read decodes the JSON file at key
+func (s *LocalRagStore) read(key string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading RAG knowledge: %w", err)
+	}
+
+	var knowledge map[string]interface{}
+	if err := json.Unmarshal(content, &knowledge); err != nil {
+		return nil, fmt.Errorf("error parsing RAG knowledge JSON: %w", err)
+	}
+	return knowledge, nil
+}
+
+// write encodes knowledge to the JSON file at key, failing when the file
+// exists unless overwrite is set
+func (s *LocalRagStore) write(key string, knowledge map[string]interface{}, overwrite bool) error {
+	content, err := json.MarshalIndent(knowledge, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling knowledge to JSON: %w", err)
+	}
+
+	target := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("error creating RAG knowledge directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a partial file
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("error writing RAG knowledge: %w", err)
+	}
+	if !overwrite {
+		// Linking fails when the target exists, where renaming would replace it
+		defer os.Remove(tmp)
+		if err := os.Link(tmp, target); err != nil {
+			return fmt.Errorf("error writing RAG knowledge %s: %w", key, err)
+		}
+		return nil
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing RAG knowledge: %w", err)
+	}
+	return nil
+}
+
+// backupKeys lists the keys of the backups of a knowledge file
+func (s *LocalRagStore) backupKeys(file string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "backups"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing RAG knowledge backups: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		key := path.Join("backups", entry.Name())
+		if !entry.IsDir() && s.isKnowledgeVersion(file, key) && key != file {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// backupPrefix returns the key prefix of the backups of a knowledge file, e.g.
+// backups/rag_knowledge.es_ for rag_knowledge.es.json
+func (s *LocalRagStore) backupPrefix(file string) string {
+	return "backups/" + strings.TrimSuffix(file, ".json") + "_"
+}
+
+// isKnowledgeVersion reports whether a key is a knowledge file or one of its
+// backups, and stays inside the directory
+func (s *LocalRagStore) isKnowledgeVersion(file, key string) bool {
+	if key == file {
+		return true
+	}
+	name, ok := strings.CutPrefix(key, s.backupPrefix(file))
+	return ok && strings.HasSuffix(name, ".json") && !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
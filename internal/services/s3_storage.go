@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/daniele/web-app-caa/internal/config"
 )
 
@@ -23,7 +26,8 @@ type S3StorageService struct {
 	enabled    bool
 }
 
-// S3Object represents an S3 object with metadata
+// S3Object represents a stored object with metadata, in S3 or, for RAG
+// knowledge, a local directory
 type S3Object struct {
 	Key          string
 	LastModified time.Time
@@ -78,18 +82,23 @@ func NewS3StorageService(cfg *config.Config) *S3StorageService {
 	return service
 }
 
+// Name describes the store in logs and messages
+func (s *S3StorageService) Name() string {
+	return "S3"
+}
+
 // IsEnabled returns whether S3 storage is enabled
 func (s *S3StorageService) IsEnabled() bool {
 	return s.enabled
 }
 
-// GetRagKnowledge retrieves the RAG knowledge JSON from S3
-func (s *S3StorageService) GetRagKnowledge(ctx context.Context) (map[string]interface{}, error) {
+// GetRagKnowledge retrieves a RAG knowledge JSON file from S3
+func (s *S3StorageService) GetRagKnowledge(ctx context.Context, file string) (map[string]interface{}, error) {
 	if !s.enabled {
 		return nil, fmt.Errorf("S3 storage is not enabled")
 	}
 
-	key := s.getKnowledgeKey()
+	key := s.getKnowledgeKey(file)
 
 	log.Printf("Fetching RAG knowledge from S3: bucket=%s, key=%s", s.bucketName, key)
 
@@ -119,13 +128,13 @@ func (s *S3StorageService) GetRagKnowledge(ctx context.Context) (map[string]inte
 	return knowledge, nil
 }
 
-// PutRagKnowledge uploads RAG knowledge JSON to S3
-func (s *S3StorageService) PutRagKnowledge(ctx context.Context, knowledge map[string]interface{}) error {
+// PutRagKnowledge uploads a RAG knowledge JSON file to S3
+func (s *S3StorageService) PutRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error {
 	if !s.enabled {
 		return fmt.Errorf("S3 storage is not enabled")
 	}
 
-	key := s.getKnowledgeKey()
+	key := s.getKnowledgeKey(file)
 
 	// Convert to JSON
 	content, err := json.MarshalIndent(knowledge, "", "  ")
@@ -151,16 +160,13 @@ func (s *S3StorageService) PutRagKnowledge(ctx context.Context, knowledge map[st
 	return nil
 }
 
-// ListRagKnowledgeVersions lists all versions of RAG knowledge files in S3
-func (s *S3StorageService) ListRagKnowledgeVersions(ctx context.Context) ([]S3Object, error) {
+// ListRagKnowledgeVersions lists a RAG knowledge file in S3 and its backups
+func (s *S3StorageService) ListRagKnowledgeVersions(ctx context.Context, file string) ([]S3Object, error) {
 	if !s.enabled {
 		return nil, fmt.Errorf("S3 storage is not enabled")
 	}
 
-	prefix := s.keyPrefix
-	if prefix != "" && prefix[len(prefix)-1] != '/' {
-		prefix += "/"
-	}
+	prefix := s.getPrefix()
 
 	log.Printf("Listing RAG knowledge versions from S3: bucket=%s, prefix=%s", s.bucketName, prefix)
 
@@ -175,6 +181,9 @@ func (s *S3StorageService) ListRagKnowledgeVersions(ctx context.Context) ([]S3Ob
 
 	var objects []S3Object
 	for _, obj := range result.Contents {
+		if !s.isKnowledgeVersion(file, *obj.Key) {
+			continue
+		}
 		objects = append(objects, S3Object{
 			Key:          *obj.Key,
 			LastModified: *obj.LastModified,
@@ -186,15 +195,15 @@ func (s *S3StorageService) ListRagKnowledgeVersions(ctx context.Context) ([]S3Ob
 	return objects, nil
 }
 
-// BackupRagKnowledge creates a timestamped backup of the current RAG knowledge
-func (s *S3StorageService) BackupRagKnowledge(ctx context.Context, knowledge map[string]interface{}) error {
+// BackupRagKnowledge creates a timestamped backup of a RAG knowledge file
+func (s *S3StorageService) BackupRagKnowledge(ctx context.Context, file string, knowledge map[string]interface{}) error {
 	if !s.enabled {
 		return fmt.Errorf("S3 storage is not enabled")
 	}
 
 	// Create backup key with timestamp
-	timestamp := time.Now().Format("20060102_150405")
-	backupKey := s.getBackupKey(timestamp)
+	timestamp := time.Now().Format(ragBackupTimestamp)
+	backupKey := s.getBackupKey(file, timestamp)
 
 	// Convert to JSON
 	content, err := json.MarshalIndent(knowledge, "", "  ")
@@ -219,18 +228,21 @@ func (s *S3StorageService) BackupRagKnowledge(ctx context.Context, knowledge map
 	return nil
 }
 
-// RestoreRagKnowledge restores RAG knowledge from a specific backup
-func (s *S3StorageService) RestoreRagKnowledge(ctx context.Context, backupKey string) (map[string]interface{}, error) {
+// GetRagKnowledgeVersion reads a RAG knowledge file or one of its backups from S3
+func (s *S3StorageService) GetRagKnowledgeVersion(ctx context.Context, file, key string) (map[string]interface{}, error) {
 	if !s.enabled {
 		return nil, fmt.Errorf("S3 storage is not enabled")
 	}
+	if !s.isKnowledgeVersion(file, key) {
+		return nil, fmt.Errorf("%w: %s is not a backup of %s", ErrInvalidRagVersion, key, file)
+	}
 
-	log.Printf("Restoring RAG knowledge from backup: bucket=%s, key=%s", s.bucketName, backupKey)
+	log.Printf("Fetching RAG knowledge version from S3: bucket=%s, key=%s", s.bucketName, key)
 
-	// Get backup object from S3
+	// Get version object from S3
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(backupKey),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error getting backup from S3: %w", err)
@@ -249,10 +261,38 @@ func (s *S3StorageService) RestoreRagKnowledge(ctx context.Context, backupKey st
 		return nil, fmt.Errorf("error parsing backup JSON: %w", err)
 	}
 
-	log.Printf("Successfully restored RAG knowledge from backup (%d bytes)", len(content))
+	log.Printf("Successfully retrieved RAG knowledge version from S3 (%d bytes)", len(content))
 	return knowledge, nil
 }
 
+// PruneRagKnowledgeBackups deletes the oldest backups of a RAG knowledge file
+// in S3 beyond keep
+func (s *S3StorageService) PruneRagKnowledgeBackups(ctx context.Context, file string, keep int) (int, error) {
+	versions, err := s.ListRagKnowledgeVersions(ctx, file)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	for _, version := range versions {
+		if version.Key != s.getKnowledgeKey(file) {
+			keys = append(keys, version.Key)
+		}
+	}
+
+	pruned := 0
+	for _, key := range expiredRagBackups(keys, keep) {
+		if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucketName),
+			Key:    aws.String(key),
+		}); err != nil {
+			return pruned, fmt.Errorf("error deleting backup %s from S3: %w", key, err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
 // CheckHealth verifies S3 connectivity and bucket access
 func (s *S3StorageService) CheckHealth(ctx context.Context) error {
 	if !s.enabled {
@@ -271,28 +311,94 @@ func (s *S3StorageService) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
-// getKnowledgeKey returns the S3 key for the main RAG knowledge file
-func (s *S3StorageService) getKnowledgeKey() string {
-	if s.keyPrefix == "" {
-		return "rag_knowledge.json"
-	}
-
+// getPrefix returns the configured key prefix, ending with a slash when set
+func (s *S3StorageService) getPrefix() string {
 	prefix := s.keyPrefix
-	if prefix[len(prefix)-1] != '/' {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
 		prefix += "/"
 	}
-	return prefix + "rag_knowledge.json"
+	return prefix
+}
+
+// getKnowledgeKey returns the S3 key for a RAG knowledge file
+func (s *S3StorageService) getKnowledgeKey(file string) string {
+	return s.getPrefix() + file
+}
+
+// getBackupPrefix returns the S3 key prefix of the backups of a RAG knowledge
+// file, e.g. backups/rag_knowledge.es_ for rag_knowledge.es.json
+func (s *S3StorageService) getBackupPrefix(file string) string {
+	return fmt.Sprintf("%sbackups/%s_", s.getPrefix(), strings.TrimSuffix(file, ".json"))
+}
+
+// getBackupKey returns the S3 key for a backup of a RAG knowledge file with timestamp
+func (s *S3StorageService) getBackupKey(file, timestamp string) string {
+	return fmt.Sprintf("%s%s.json", s.getBackupPrefix(file), timestamp)
+}
+
+// isKnowledgeVersion reports whether an S3 key is a RAG knowledge file or one
+// of its backups
+func (s *S3StorageService) isKnowledgeVersion(file, key string) bool {
+	return key == s.getKnowledgeKey(file) || strings.HasPrefix(key, s.getBackupPrefix(file))
+}
+
+// PutImage uploads a processed symbol image to S3
+func (s *S3StorageService) PutImage(ctx context.Context, key string, content []byte, contentType string) error {
+	if !s.enabled {
+		return fmt.Errorf("S3 storage is not enabled")
+	}
+
+	objectKey := s.getImageKey(key)
+	log.Printf("Uploading image to S3: bucket=%s, key=%s (%d bytes)", s.bucketName, objectKey, len(content))
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucketName),
+		Key:          aws.String(objectKey),
+		Body:         bytes.NewReader(content),
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String("public, max-age=31536000, immutable"),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading image to S3: %w", err)
+	}
+	return nil
+}
+
+// GetImage downloads a symbol image from S3 and returns its content and content type
+func (s *S3StorageService) GetImage(ctx context.Context, key string) ([]byte, string, error) {
+	if !s.enabled {
+		return nil, "", fmt.Errorf("S3 storage is not enabled")
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(s.getImageKey(key)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", ErrImageNotFound
+		}
+		return nil, "", fmt.Errorf("error getting image from S3: %w", err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading image from S3: %w", err)
+	}
+	return content, aws.ToString(result.ContentType), nil
 }
 
-// getBackupKey returns the S3 key for a backup file with timestamp
-func (s *S3StorageService) getBackupKey(timestamp string) string {
+// getImageKey returns the S3 key for a symbol image
+func (s *S3StorageService) getImageKey(key string) string {
 	if s.keyPrefix == "" {
-		return fmt.Sprintf("backups/rag_knowledge_%s.json", timestamp)
+		return "images/" + key
 	}
 
 	prefix := s.keyPrefix
 	if prefix[len(prefix)-1] != '/' {
 		prefix += "/"
 	}
-	return fmt.Sprintf("%sbackups/rag_knowledge_%s.json", prefix, timestamp)
+	return prefix + "images/" + key
 }
@@ -2,8 +2,10 @@ package ollama
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -11,8 +13,10 @@ import (
 
 // Client represents the Ollama client interface
 type Client interface {
-	Chat(model, prompt string, options ...ChatOption) (*Response, error)
-	SimpleChat(model, prompt string) (string, error)
+	Chat(ctx context.Context, model, prompt string, options ...ChatOption) (*Response, error)
+	ChatStream(ctx context.Context, model, prompt string, onChunk func(*Response) error, options ...ChatOption) (*Response, error)
+	SimpleChat(ctx context.Context, model, prompt string) (string, error)
+	Embeddings(ctx context.Context, model, prompt string) ([]float64, error)
 }
 
 // Request represents the request structure for Ollama API based on the official documentation
@@ -21,6 +25,13 @@ type Request struct {
 	Messages []Message `json:"messages"`
 	Stream   bool      `json:"stream"`
 	Format   string    `json:"format,omitempty"` // Optional field for JSON response format
+	Options  *Options  `json:"options,omitempty"`
+}
+
+// Options holds the model parameters of a request
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"` // Maximum number of tokens to generate
 }
 
 // Message represents a message in the conversation
@@ -41,6 +52,18 @@ type Response struct {
 	PromptEvalDuration int       `json:"prompt_eval_duration"`
 	EvalCount          int       `json:"eval_count"`
 	EvalDuration       int64     `json:"eval_duration"`
+	Error              string    `json:"error,omitempty"` // Set when a stream fails after it started
+}
+
+// EmbeddingsRequest represents the request structure of the Ollama embeddings API
+type EmbeddingsRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// EmbeddingsResponse represents the response structure of the Ollama embeddings API
+type EmbeddingsResponse struct {
+	Embedding []float64 `json:"embedding"`
 }
 
 // ClientImpl handles interactions with Ollama API
@@ -65,9 +88,66 @@ type ClientConfig struct {
 	Timeout time.Duration
 }
 
-// Chat sends a chat request to Ollama and returns the response
-func (c *ClientImpl) Chat(model, prompt string, options ...ChatOption) (*Response, error) {
-	// Build the request with default values
+// Chat sends a chat request to Ollama and returns the response. The request is
+// cancelled when ctx is done.
+func (c *ClientImpl) Chat(ctx context.Context, model, prompt string, options ...ChatOption) (*Response, error) {
+	httpResp, err := c.send(ctx, newRequest(model, prompt, options))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(httpResp)
+
+	// Decode the response
+	var ollamaResp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("error decoding ollama response: %w", err)
+	}
+
+	return &ollamaResp, nil
+}
+
+// ChatStream sends a streaming chat request to Ollama. onChunk is called with each
+// partial response as it arrives and may stop the stream by returning an error.
+// The returned response holds the whole message and the final statistics.
+func (c *ClientImpl) ChatStream(ctx context.Context, model, prompt string, onChunk func(*Response) error, options ...ChatOption) (*Response, error) {
+	req := newRequest(model, prompt, options)
+	req.Stream = true
+
+	httpResp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(httpResp)
+
+	// Ollama streams one JSON object per line, the last one has done set
+	var content bytes.Buffer
+	decoder := json.NewDecoder(httpResp.Body)
+	for {
+		var chunk Response
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("ollama stream ended before completion")
+			}
+			return nil, fmt.Errorf("error decoding ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama stream error: %s", chunk.Error)
+		}
+
+		content.WriteString(chunk.Message.Content)
+		if err := onChunk(&chunk); err != nil {
+			return nil, err
+		}
+
+		if chunk.Done {
+			chunk.Message.Content = content.String()
+			return &chunk, nil
+		}
+	}
+}
+
+// newRequest builds a chat request with default values and applies options
+func newRequest(model, prompt string, options []ChatOption) Request {
 	req := Request{
 		Model: model,
 		Messages: []Message{
@@ -83,7 +163,37 @@ func (c *ClientImpl) Chat(model, prompt string, options ...ChatOption) (*Respons
 	for _, option := range options {
 		option(&req)
 	}
+	return req
+}
+
+// Embeddings returns the embedding of prompt computed by an embedding model. The
+// request is cancelled when ctx is done.
+func (c *ClientImpl) Embeddings(ctx context.Context, model, prompt string) ([]float64, error) {
+	httpResp, err := c.post(ctx, "/api/embeddings", EmbeddingsRequest{Model: model, Prompt: prompt})
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(httpResp)
+
+	var embeddingsResp EmbeddingsResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&embeddingsResp); err != nil {
+		return nil, fmt.Errorf("error decoding ollama embeddings response: %w", err)
+	}
+	if len(embeddingsResp.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama returned an empty embedding")
+	}
+
+	return embeddingsResp.Embedding, nil
+}
+
+// send posts a chat request and checks the HTTP status; the caller closes the body
+func (c *ClientImpl) send(ctx context.Context, req Request) (*http.Response, error) {
+	return c.post(ctx, "/api/chat", req)
+}
 
+// post sends a request to an API path and checks the HTTP status; the caller
+// closes the body
+func (c *ClientImpl) post(ctx context.Context, path string, req interface{}) (*http.Response, error) {
 	// Marshal the request to JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
@@ -91,8 +201,8 @@ func (c *ClientImpl) Chat(model, prompt string, options ...ChatOption) (*Respons
 	}
 
 	// Create HTTP request
-	url := fmt.Sprintf("%s/api/chat", c.BaseURL)
-	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+	url := c.BaseURL + path
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating HTTP request: %w", err)
 	}
@@ -104,24 +214,21 @@ func (c *ClientImpl) Chat(model, prompt string, options ...ChatOption) (*Respons
 	if err != nil {
 		return nil, fmt.Errorf("error making ollama request: %w", err)
 	}
-	defer func() {
-		if err := httpResp.Body.Close(); err != nil {
-			log.Printf("Warning: failed to close response body: %v", err)
-		}
-	}()
 
 	// Check for HTTP errors
 	if httpResp.StatusCode != http.StatusOK {
+		closeBody(httpResp)
 		return nil, fmt.Errorf("ollama API error (status %d)", httpResp.StatusCode)
 	}
 
-	// Decode the response
-	var ollamaResp Response
-	if err := json.NewDecoder(httpResp.Body).Decode(&ollamaResp); err != nil {
-		return nil, fmt.Errorf("error decoding ollama response: %w", err)
-	}
+	return httpResp, nil
+}
 
-	return &ollamaResp, nil
+// closeBody closes a response body, logging failures
+func closeBody(httpResp *http.Response) {
+	if err := httpResp.Body.Close(); err != nil {
+		log.Printf("Warning: failed to close response body: %v", err)
+	}
 }
 
 // ChatOption is a function type for configuring chat requests
@@ -141,6 +248,13 @@ func WithStreaming(stream bool) ChatOption {
 	}
 }
 
+// WithOptions sets model parameters such as the temperature
+func WithOptions(opts Options) ChatOption {
+	return func(req *Request) {
+		req.Options = &opts
+	}
+}
+
 // WithMessages allows setting custom messages for conversation context
 func WithMessages(messages []Message) ChatOption {
 	return func(req *Request) {
@@ -161,8 +275,8 @@ func WithSystemMessage(systemPrompt string) ChatOption {
 }
 
 // SimpleChat is a convenience function for basic chat interactions
-func (c *ClientImpl) SimpleChat(model, prompt string) (string, error) {
-	resp, err := c.Chat(model, prompt, WithJSONFormat())
+func (c *ClientImpl) SimpleChat(ctx context.Context, model, prompt string) (string, error) {
+	resp, err := c.Chat(ctx, model, prompt, WithJSONFormat())
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,25 @@
 // web-app-CAA/static/script/api.js
 // API communication functions
 
+// Latest grid revision (ETag) from the server, sent back as If-Match on writes
+// so edits based on a stale grid are rejected instead of overwriting others
+let gridRevision = null;
+
+function rememberGridRevision(response) {
+    const etag = response.headers.get('ETag');
+    if (etag) {
+        gridRevision = etag;
+    }
+}
+
+function gridRevisionHeaders() {
+    return gridRevision ? { 'If-Match': gridRevision } : {};
+}
+
+function isGridRevisionConflict(response) {
+    return response.status === 409 || response.status === 412 || response.status === 428;
+}
+
 // --- DATABASE COMMUNICATION ---
 async function loadGridFromDB(retries = 3, delay = 200) {
     const token = localStorage.getItem('jwt_token');
@@ -25,6 +44,7 @@ async function loadGridFromDB(retries = 3, delay = 200) {
                 throw new Error(`HTTP error! status: ${response.status}`);
             }
             
+            rememberGridRevision(response);
             const data = await response.json();
             console.log('Grid data loaded from DB.');
             return data;
@@ -53,15 +73,22 @@ async function saveGridToDB() {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
-                'Authorization': `Bearer ${token}`
+                'Authorization': `Bearer ${token}`,
+                ...gridRevisionHeaders()
             },
             body: JSON.stringify(categories),
         });
         
+        if (isGridRevisionConflict(response)) {
+            alert('The grid was changed in another session. It will be reloaded; please apply your changes again.');
+            window.location.reload();
+            return;
+        }
         if (!response.ok) {
             throw new Error(`HTTP error! status: ${response.status}`);
         }
         
+        rememberGridRevision(response);
         console.log('Grid state saved to DB.');
     } catch (error) {
         console.error('Failed to save grid state:', error);
@@ -69,6 +96,50 @@ async function saveGridToDB() {
     }
 }
 
+// Moves an item to position on parentCategory; the server renumbers the siblings.
+// Returns false when the move was rejected.
+async function moveItemInDB(itemId, parentCategory, position = null) {
+    const token = localStorage.getItem('jwt_token');
+    if (!token) return false;
+    
+    try {
+        const body = { parentCategory };
+        if (position !== null) {
+            body.position = position;
+        }
+        const response = await fetch(`${API_BASE_URL}/api/grid/item/${itemId}/move`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${token}`,
+                ...gridRevisionHeaders()
+            },
+            body: JSON.stringify(body),
+        });
+        
+        if (isGridRevisionConflict(response)) {
+            alert('The grid was changed in another session. It will be reloaded; please apply your changes again.');
+            window.location.reload();
+            return false;
+        }
+        if (response.status === 422) {
+            alert('This move would make a category open itself. It was not saved.');
+            return false;
+        }
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        
+        rememberGridRevision(response);
+        console.log('Item moved in DB.');
+        return true;
+    } catch (error) {
+        console.error('Failed to move item:', error);
+        alert('Failed to save changes. Check server connection.');
+        return false;
+    }
+}
+
 async function searchArasaacAPI(query) {
     if (!query || query.trim().length === 0) {
         return [];
@@ -101,7 +172,8 @@ async function deleteItemFromDB(itemId, categoryTarget = null) {
         method: 'DELETE',
         headers: {
             'Content-Type': 'application/json',
-            'Authorization': `Bearer ${token}`
+            'Authorization': `Bearer ${token}`,
+            ...gridRevisionHeaders()
         },
         body: JSON.stringify(body)
     });
@@ -110,6 +182,7 @@ async function deleteItemFromDB(itemId, categoryTarget = null) {
         throw new Error(`Failed to delete item: ${response.status}`);
     }
     
+    rememberGridRevision(response);
     return response.json();
 }
 
@@ -123,7 +196,8 @@ async function updateItemVisibility(itemId, isVisible) {
         method: 'PATCH',
         headers: {
             'Content-Type': 'application/json',
-            'Authorization': `Bearer ${token}`
+            'Authorization': `Bearer ${token}`,
+            ...gridRevisionHeaders()
         },
         body: JSON.stringify({ visible: isVisible })
     });
@@ -132,6 +206,7 @@ async function updateItemVisibility(itemId, isVisible) {
         throw new Error(`Failed to update item visibility: ${response.status}`);
     }
     
+    rememberGridRevision(response);
     return response.json();
 }
 
@@ -202,14 +277,36 @@ async function uploadCustomImage(file) {
     return response.json();
 }
 
+// Subscribe to grid changes made on other devices (Server-Sent Events).
+// onChange is called with the event when the server reports a newer revision.
+function subscribeToGridEvents(onChange) {
+    const token = localStorage.getItem('jwt_token');
+    if (!token || typeof EventSource === 'undefined') return null;
+    
+    const source = new EventSource(`${API_BASE_URL}/api/grid/events?token=${encodeURIComponent(token)}`);
+    const handleEvent = (message) => {
+        const event = JSON.parse(message.data);
+        if (!gridRevision) return;
+        const known = parseInt(gridRevision.replace(/\D/g, ''), 10) || 0;
+        if (event.revision > known) {
+            onChange(event);
+        }
+    };
+    source.addEventListener('ready', handleEvent);
+    source.addEventListener('grid', handleEvent);
+    return source;
+}
+
 // Export functions for use in other modules
 if (typeof window !== 'undefined') {
     window.loadGridFromDB = loadGridFromDB;
     window.saveGridToDB = saveGridToDB;
+    window.moveItemInDB = moveItemInDB;
     window.searchArasaacAPI = searchArasaacAPI;
     window.deleteItemFromDB = deleteItemFromDB;
     window.updateItemVisibility = updateItemVisibility;
     window.completeUserSetup = completeUserSetup;
     window.validateEditorPassword = validateEditorPassword;
     window.uploadCustomImage = uploadCustomImage;
+    window.subscribeToGridEvents = subscribeToGridEvents;
 }
@@ -84,8 +84,8 @@ function handleDrop(e) {
     // Update DOM order
     dom.symbolGrid.insertBefore(draggedCell, targetCell);
     
-    // Save changes
-    saveGridToDB();
+    // Save changes; the server renumbers the other items of the page
+    moveItemInDB(draggedItemId, currentCategory, dropIdx);
 }
 
 function handleDragEnd() {
@@ -129,9 +129,9 @@ async function handleTrashDrop(e) {
     const { item, parentKey } = found;
     const isCategory = item.type === 'category';
     
-    let confirmationMessage = `Sei sicuro di voler eliminare in modo permanente "${item.label}"?`;
+    let confirmationMessage = `Sei sicuro di voler eliminare "${item.label}"? Potrai ripristinarlo dal cestino.`;
     if (isCategory) {
-        confirmationMessage += "\n\nATTENZIONE: Verranno eliminati anche tutti i simboli contenuti in questa categoria.";
+        confirmationMessage += "\n\nATTENZIONE: Verranno eliminati anche tutti i simboli e le sottocategorie contenuti in questa categoria.";
     }
     
     if (!confirm(confirmationMessage)) return;
@@ -258,7 +258,8 @@ async function executeCopyOrMove(targetKey) {
                 method: 'POST',
                 headers: { 
                     'Content-Type': 'application/json', 
-                    'Authorization': `Bearer ${authToken}` 
+                    'Authorization': `Bearer ${authToken}`,
+                    ...gridRevisionHeaders()
                 },
                 body: JSON.stringify({ 
                     item: itemDataForCopy, 
@@ -270,6 +271,7 @@ async function executeCopyOrMove(targetKey) {
                 throw new Error('Server responded with an error.');
             }
             
+            rememberGridRevision(response);
             const newItemWithUUID = await response.json();
             
             // Add the copied item with backend-generated UUID to UI
@@ -283,13 +285,19 @@ async function executeCopyOrMove(targetKey) {
             return;
         }
     } else if (contextState.action === 'move') {
+        if (!await moveItemInDB(contextState.symbolId, targetKey)) {
+            clearContextMenuState();
+            return;
+        }
         categories[found.parentKey] = categories[found.parentKey].filter(i => i.id !== contextState.symbolId);
         categories[targetKey].push(itemToProcess);
     }
     
     renderSymbols();
     closeModal(dom.categorySelectionModal);
-    saveGridToDB();
+    if (contextState.action === 'copy') {
+        saveGridToDB();
+    }
     clearContextMenuState();
 }
 
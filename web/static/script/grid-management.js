@@ -166,7 +166,8 @@ async function confirmEditItem(itemType) {
                 method: 'POST',
                 headers: { 
                     'Content-Type': 'application/json', 
-                    'Authorization': `Bearer ${authToken}` 
+                    'Authorization': `Bearer ${authToken}`,
+                    ...gridRevisionHeaders()
                 },
                 body: JSON.stringify({ 
                     item: newItemData, 
@@ -178,6 +179,7 @@ async function confirmEditItem(itemType) {
                 throw new Error('Server responded with an error.');
             }
             
+            rememberGridRevision(response);
             const result = await response.json();
             
             // Add the item with backend-generated UUID to UI
@@ -44,6 +44,17 @@ async function initializeApplication() {
         // Initial render
         renderSymbols();
         
+        // Keep the grid in sync with edits made on other devices
+        subscribeToGridEvents(async (event) => {
+            console.log('Grid changed on another device:', event.summary);
+            const updatedData = await loadGridFromDB();
+            if (updatedData) {
+                setCategories(updatedData);
+                processOriginalSymbolForms();
+                renderSymbols();
+            }
+        });
+        
         // Handle first login tutorial
         await handleFirstLoginTutorial();
         
@@ -12,21 +12,32 @@ document.addEventListener('DOMContentLoaded', () => {
     const editorPassword = urlParams.get('editorPassword');
     const isNewUser = username && password && editorPassword;
 
-    const gridOptions = [
+    // Built-in templates, shown when the template list cannot be loaded
+    const fallbackOptions = [
         { id: 'simplified', name: 'Semplificata', description: 'Meno opzioni, ideale per chi si avvicina per la prima volta alla CAA.', icon: 'https://api.arasaac.org/api/pictograms/32434' },
         { id: 'default', name: 'Standard', description: 'Una selezione bilanciata di categorie e simboli per iniziare.', icon: 'https://api.arasaac.org/api/pictograms/32436' },
         { id: 'empty', name: 'Avanzata', description: 'Inizia da zero e costruisci la tua griglia personalizzata.', icon: 'https://api.arasaac.org/api/pictograms/3046' }
     ];
 
-    gridOptions.forEach(option => {
+    function renderOption(option) {
         const optionEl = document.createElement('div');
         optionEl.className = 'grid-option';
         optionEl.dataset.gridId = option.id;
-        optionEl.innerHTML = `
-            <img src="${option.icon}" alt="${option.name}">
-            <h3>${option.name}</h3>
-            <p class="description">${option.description}</p>
-        `;
+
+        if (option.icon) {
+            const img = document.createElement('img');
+            img.src = option.icon;
+            img.alt = option.name;
+            optionEl.appendChild(img);
+        }
+        const title = document.createElement('h3');
+        title.textContent = option.name;
+        optionEl.appendChild(title);
+        const description = document.createElement('p');
+        description.className = 'description';
+        description.textContent = option.description;
+        optionEl.appendChild(description);
+
         optionEl.addEventListener('click', () => {
             document.querySelectorAll('.grid-option').forEach(el => el.classList.remove('selected'));
             optionEl.classList.add('selected');
@@ -34,7 +45,26 @@ document.addEventListener('DOMContentLoaded', () => {
             confirmBtn.disabled = false;
         });
         optionsContainer.appendChild(optionEl);
-    });
+    }
+
+    async function loadGridOptions() {
+        try {
+            const response = await fetch(`${API_BASE_URL}/api/grid/templates`);
+            if (!response.ok) {
+                throw new Error(`HTTP error! status: ${response.status}`);
+            }
+            const { templates } = await response.json();
+            if (templates && templates.length > 0) {
+                // Built-in templates keep their slug so older servers and clients agree on the grid type
+                return templates.map(t => ({ id: t.slug || t.id, name: t.name, description: t.description, icon: t.icon }));
+            }
+        } catch (error) {
+            console.error('Failed to load grid templates:', error);
+        }
+        return fallbackOptions;
+    }
+
+    loadGridOptions().then(options => options.forEach(renderOption));
 
     confirmBtn.addEventListener('click', async () => {
         if (!selectedOption) return;